@@ -0,0 +1,276 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"pomodoro-api/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BootstrapAdmins 将 ADMIN_EMAILS 中列出的用户设为管理员（启动时调用）
+func BootstrapAdmins() {
+	for _, email := range utils.GetEnvList("ADMIN_EMAILS") {
+		result := database.DB.Model(&models.User{}).
+			Where("email = ? AND role <> ?", email, models.RoleAdmin).
+			Update("role", models.RoleAdmin)
+		if result.RowsAffected > 0 {
+			log.Printf("已将 %s 设为管理员", email)
+		}
+	}
+}
+
+// findTargetUser 查找被管理的用户，不存在时返回 404
+func findTargetUser(c *gin.Context) (*models.User, bool) {
+	var user models.User
+	if err := database.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return nil, false
+	}
+	return &user, true
+}
+
+// recordAdminAudit 记录管理操作
+func recordAdminAudit(c *gin.Context, action string, target *models.User, detail string) {
+	actorID := c.GetUint("user_id")
+	recordAudit(c, models.AuditEvent{
+		ActorID:    &actorID,
+		Action:     action,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(target.ID), 10),
+		Detail:     detail,
+	})
+}
+
+// AdminGetUsers 查询用户列表（支持按用户名/邮箱搜索）
+func AdminGetUsers(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := database.DB.Model(&models.User{})
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		like := "%" + q + "%"
+		query = query.Where("username LIKE ? OR email LIKE ?", like, like)
+	}
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+	if disabled := c.Query("disabled"); disabled != "" {
+		query = query.Where("disabled = ?", disabled == "true")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var users []models.User
+	query.Order("id ASC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&users)
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"users":     users,
+	})
+}
+
+// AdminGetUserStats 查看用户的统计数据
+func AdminGetUserStats(c *gin.Context) {
+	user, ok := findTargetUser(c)
+	if !ok {
+		return
+	}
+
+	var totalWords int
+	database.DB.Model(&models.WordRecord{}).
+		Where("user_id = ?", user.ID).
+		Select("COALESCE(SUM(word_count), 0)").
+		Scan(&totalWords)
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":        user,
+		"stats":       buildStats(user.ID, -1),
+		"total_words": totalWords,
+	})
+}
+
+// AdminSetUserDisabled 停用或启用账号
+func AdminSetUserDisabled(c *gin.Context) {
+	user, ok := findTargetUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Disabled *bool `json:"disabled" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if user.ID == c.GetUint("user_id") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不能停用自己的账号"})
+		return
+	}
+
+	database.DB.Model(user).Update("disabled", *input.Disabled)
+	recordAdminAudit(c, "admin.user_disable", user, "disabled="+strconv.FormatBool(*input.Disabled))
+
+	c.JSON(http.StatusOK, user)
+}
+
+// AdminSetUserRole 修改用户角色
+func AdminSetUserRole(c *gin.Context) {
+	user, ok := findTargetUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !models.IsValidRole(input.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的角色"})
+		return
+	}
+	if user.ID == c.GetUint("user_id") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不能修改自己的角色"})
+		return
+	}
+
+	previous := user.Role
+	database.DB.Model(user).Update("role", input.Role)
+	recordAdminAudit(c, "admin.user_role", user, "role="+previous+"->"+input.Role)
+
+	c.JSON(http.StatusOK, user)
+}
+
+// AdminResetPassword 重置用户密码，未指定新密码时生成临时密码
+func AdminResetPassword(c *gin.Context) {
+	user, ok := findTargetUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	password := input.Password
+	generated := password == ""
+	if generated {
+		temp, err := utils.GenerateRandomToken(9)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "临时密码生成失败"})
+			return
+		}
+		password = temp
+	} else if len(password) < 6 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "密码至少6位"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
+		return
+	}
+
+	// 同时让该用户已登录的会话失效
+	database.DB.Model(user).Updates(map[string]interface{}{"password": string(hashedPassword), "password_changed_at": time.Now()})
+	unlockAccount(user.ID)
+	recordAdminAudit(c, "admin.password_reset", user, "generated="+strconv.FormatBool(generated))
+
+	response := gin.H{"message": "密码已重置"}
+	if generated {
+		response["temporary_password"] = password
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// AdminUnlockUser 解除登录失败锁定
+func AdminUnlockUser(c *gin.Context) {
+	user, ok := findTargetUser(c)
+	if !ok {
+		return
+	}
+
+	unlockAccount(user.ID)
+	recordAdminAudit(c, "account.unlock", user, "via=admin")
+
+	c.JSON(http.StatusOK, gin.H{"message": "账号已解锁"})
+}
+
+// AdminSetLeaderboardHidden 在公开排行榜中隐藏或显示用户
+func AdminSetLeaderboardHidden(c *gin.Context) {
+	user, ok := findTargetUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Hidden *bool `json:"hidden" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	database.DB.Model(user).Update("leaderboard_hidden", *input.Hidden)
+	recordAdminAudit(c, "admin.leaderboard_hide", user, "hidden="+strconv.FormatBool(*input.Hidden))
+
+	c.JSON(http.StatusOK, user)
+}
+
+// AdminDeletePomodoro 删除违规的番茄钟记录（从排行榜中移除）
+func AdminDeletePomodoro(c *gin.Context) {
+	var pomodoro models.Pomodoro
+	if err := database.DB.First(&pomodoro, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "番茄钟不存在"})
+		return
+	}
+
+	database.DB.Delete(&pomodoro)
+
+	recordChange(c, "admin.pomodoro_delete", "pomodoro", pomodoro.ID, pomodoro.UserID, pomodoro, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// AdminDeleteWordRecord 删除违规的单词记录（从排行榜中移除）
+func AdminDeleteWordRecord(c *gin.Context) {
+	var record models.WordRecord
+	if err := database.DB.First(&record, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "记录不存在"})
+		return
+	}
+
+	database.DB.Delete(&record)
+
+	recordChange(c, "admin.word_record_delete", "word_record", record.ID, record.UserID, record, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
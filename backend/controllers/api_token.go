@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"pomodoro-api/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAPITokens 获取个人访问令牌列表
+func GetAPITokens(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var tokens []models.APIToken
+	database.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens)
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// CreateAPIToken 创建个人访问令牌（明文只在创建时返回一次）
+func CreateAPIToken(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		Name          string   `json:"name" binding:"required,max=50"`
+		Scopes        []string `json:"scopes" binding:"required,min=1"`
+		ExpiresInDays int      `json:"expires_in_days"` // 0 表示永不过期
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, scope := range input.Scopes {
+		if !models.IsValidAPITokenScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的权限范围: " + scope})
+			return
+		}
+	}
+	if input.ExpiresInDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "过期天数不能为负数"})
+		return
+	}
+
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "令牌生成失败"})
+		return
+	}
+	plain := models.APITokenPrefix + secret
+
+	token := models.APIToken{
+		UserID:    userID,
+		Name:      input.Name,
+		Prefix:    plain[:len(models.APITokenPrefix)+6],
+		TokenHash: utils.HashToken(plain),
+		Scopes:    strings.Join(input.Scopes, " "),
+	}
+	if input.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, input.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := database.DB.Create(&token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败"})
+		return
+	}
+
+	recordAudit(c, models.AuditEvent{
+		ActorID:    &userID,
+		Action:     "api_token.create",
+		TargetType: "api_token",
+		TargetID:   strconv.FormatUint(uint64(token.ID), 10),
+		Detail:     "name=" + token.Name + " scopes=" + token.Scopes,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":     plain,
+		"api_token": token,
+		"message":   "请妥善保存令牌，它只会显示这一次",
+	})
+}
+
+// RevokeAPIToken 吊销个人访问令牌
+func RevokeAPIToken(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	tokenID := c.Param("id")
+
+	var token models.APIToken
+	if err := database.DB.Where("id = ? AND user_id = ?", tokenID, userID).First(&token).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "令牌不存在"})
+		return
+	}
+
+	database.DB.Delete(&token)
+
+	recordAudit(c, models.AuditEvent{
+		ActorID:    &userID,
+		Action:     "api_token.revoke",
+		TargetType: "api_token",
+		TargetID:   strconv.FormatUint(uint64(token.ID), 10),
+		Detail:     "name=" + token.Name,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "令牌已吊销"})
+}
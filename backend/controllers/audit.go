@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// recordAudit 写入一条审计事件，IP 取自当前请求
+// 未指定 OwnerID 时，目标为用户则归属该用户，否则归属操作者本人（管理操作除外）
+func recordAudit(c *gin.Context, event models.AuditEvent) {
+	if c != nil {
+		event.IP = c.ClientIP()
+	}
+	if event.OwnerID == nil {
+		if event.TargetType == "user" {
+			if id, err := strconv.ParseUint(event.TargetID, 10, 64); err == nil {
+				ownerID := uint(id)
+				event.OwnerID = &ownerID
+			}
+		} else if !strings.HasPrefix(event.Action, "admin.") {
+			event.OwnerID = event.ActorID
+		}
+	}
+	if err := database.DB.Create(&event).Error; err != nil {
+		log.Printf("写入审计事件失败: action=%s, err=%v", event.Action, err)
+	}
+}
+
+// recordChange 记录一次数据变更，附带变更前后的快照（删除时 after 为 nil）
+func recordChange(c *gin.Context, action, targetType string, targetID, ownerID uint, before, after interface{}) {
+	actorID := c.GetUint("user_id")
+	recordAudit(c, models.AuditEvent{
+		ActorID:    &actorID,
+		OwnerID:    &ownerID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   strconv.FormatUint(uint64(targetID), 10),
+		Before:     auditSnapshot(before),
+		After:      auditSnapshot(after),
+	})
+}
+
+// auditSnapshot 把数据序列化为 JSON 快照，敏感字段由模型的 json:"-" 排除
+func auditSnapshot(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("审计快照序列化失败: %v", err)
+		return nil
+	}
+	return data
+}
+
+// auditPage 解析分页参数
+func auditPage(c *gin.Context) (int, int) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 200 {
+		pageSize = 50
+	}
+	return page, pageSize
+}
+
+// filterAudit 按动作前缀、目标和时间范围过滤
+func filterAudit(c *gin.Context, query *gorm.DB) *gorm.DB {
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action LIKE ? ESCAPE '\\'", escapeLike(action)+"%")
+	}
+	if targetType := c.Query("target_type"); targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+	if targetID := c.Query("target_id"); targetID != "" {
+		query = query.Where("target_id = ?", targetID)
+	}
+	if from, err := time.ParseInLocation("2006-01-02", c.Query("from"), time.Local); err == nil {
+		query = query.Where("created_at >= ?", from)
+	}
+	if to, err := time.ParseInLocation("2006-01-02", c.Query("to"), time.Local); err == nil {
+		query = query.Where("created_at < ?", to.AddDate(0, 0, 1))
+	}
+	return query
+}
+
+// respondAuditEvents 分页返回审计事件，按时间倒序
+func respondAuditEvents(c *gin.Context, query *gorm.DB) {
+	page, pageSize := auditPage(c)
+
+	var total int64
+	query.Count(&total)
+
+	var events []models.AuditEvent
+	query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&events)
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"events":    events,
+	})
+}
+
+// GetAuditEvents 获取与当前用户账号相关的审计事件
+func GetAuditEvents(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	query := database.DB.Model(&models.AuditEvent{}).Where("owner_id = ?", userID)
+	respondAuditEvents(c, filterAudit(c, query))
+}
+
+// AdminGetAuditEvents 管理员查询审计事件，可按操作者、账号、动作、目标和时间过滤
+func AdminGetAuditEvents(c *gin.Context) {
+	query := database.DB.Model(&models.AuditEvent{})
+	if actorID := c.Query("actor_id"); actorID != "" {
+		query = query.Where("actor_id = ?", actorID)
+	}
+	if ownerID := c.Query("owner_id"); ownerID != "" {
+		query = query.Where("owner_id = ?", ownerID)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		query = query.Where("ip = ?", ip)
+	}
+	respondAuditEvents(c, filterAudit(c, query))
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"net/http"
+	"net/url"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuditActionFilterMatchesLiterally(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+
+	r := gin.New()
+	r.Use(withUser(user.ID))
+	r.GET("/api/audit", GetAuditEvents)
+
+	for _, action := range []string{"login.success", "login.lockout", "loginXsuccess", "login_oidc", "category.delete"} {
+		database.DB.Create(&models.AuditEvent{OwnerID: &user.ID, Action: action})
+	}
+
+	tests := []struct {
+		action string
+		want   int64
+	}{
+		{"login.", 2},
+		{"login", 4},
+		{"login_", 1}, // _ 不匹配任意字符
+		{"%success", 0},
+		{`login\`, 0},
+		{"category.delete", 1},
+	}
+	for _, tt := range tests {
+		w := performRequest(r, http.MethodGet, "/api/audit?action="+url.QueryEscape(tt.action), nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("action=%q 状态码 = %d", tt.action, w.Code)
+		}
+		var resp struct {
+			Total int64 `json:"total"`
+		}
+		decodeJSON(t, w, &resp)
+		if resp.Total != tt.want {
+			t.Errorf("action=%q 匹配 %d 条, want %d", tt.action, resp.Total, tt.want)
+		}
+	}
+}
@@ -1,13 +1,20 @@
 package controllers
 
 import (
+	"log"
 	"net/http"
 	"pomodoro-api/database"
+	"pomodoro-api/middleware"
 	"pomodoro-api/models"
 	"pomodoro-api/utils"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 // Register 用户注册
@@ -22,6 +29,11 @@ func Register(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// 登录时含 @ 的账号按邮箱查找，用户名不能包含 @
+	if strings.Contains(input.Username, "@") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "用户名不能包含 @"})
+		return
+	}
 
 	// 加密密码
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
@@ -41,9 +53,19 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	createDefaultUserData(user.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "注册成功",
+		"user":    user,
+	})
+}
+
+// createDefaultUserData 为新用户创建默认设置和分类
+func createDefaultUserData(userID uint) {
 	// 创建默认设置
 	setting := models.Setting{
-		UserID:              user.ID,
+		UserID:              userID,
 		DefaultDuration:     1500,
 		ShortBreak:          300,
 		LongBreak:           900,
@@ -54,25 +76,44 @@ func Register(c *gin.Context) {
 
 	// 创建默认分类
 	defaultCategories := []models.Category{
-		{UserID: user.ID, Name: "学习", Color: "#FF6B6B"},
-		{UserID: user.ID, Name: "工作", Color: "#4ECDC4"},
-		{UserID: user.ID, Name: "运动", Color: "#95E1D3"},
+		{UserID: userID, Name: "学习", Color: "#FF6B6B"},
+		{UserID: userID, Name: "工作", Color: "#4ECDC4"},
+		{UserID: userID, Name: "运动", Color: "#95E1D3"},
 	}
 	for _, cat := range defaultCategories {
 		database.DB.Create(&cat)
 	}
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "注册成功",
-		"user":    user,
+// dummyPasswordHash 用于账号不存在时执行一次等价的 bcrypt 比较，避免通过响应时间判断账号是否存在
+var (
+	dummyPasswordHash     []byte
+	dummyPasswordHashOnce sync.Once
+)
+
+func getDummyPasswordHash() []byte {
+	dummyPasswordHashOnce.Do(func() {
+		dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("pomodoro-dummy-password"), bcrypt.DefaultCost)
 	})
+	return dummyPasswordHash
 }
 
-// Login 用户登录
+// whereAccount 按账号查找用户：包含 @ 时按邮箱，否则按用户名
+// 避免某个用户的用户名恰好是另一个用户的邮箱时匹配到错误的账号
+func whereAccount(identifier string) *gorm.DB {
+	if strings.Contains(identifier, "@") {
+		return database.DB.Where("email = ?", identifier)
+	}
+	return database.DB.Where("username = ?", identifier)
+}
+
+// Login 用户登录（支持用户名或邮箱）
 func Login(c *gin.Context) {
 	var input struct {
-		Email    string `json:"email" binding:"required"`
+		Account  string `json:"account"` // 用户名或邮箱
+		Email    string `json:"email"`   // 兼容旧版前端
 		Password string `json:"password" binding:"required"`
+		Mode     string `json:"mode"` // cookie: 令牌写入 HttpOnly Cookie，不在响应中返回
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -80,18 +121,47 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	identifier := strings.TrimSpace(input.Account)
+	if identifier == "" {
+		identifier = strings.TrimSpace(input.Email)
+	}
+	if identifier == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请输入用户名或邮箱"})
+		return
+	}
+
 	var user models.User
-	if err := database.DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+	found := whereAccount(identifier).First(&user).Error == nil
+
+	// 账号处于锁定期内时不再校验密码
+	if lock := getLoginLock(&user, found, identifier); lock.locked(time.Now()) {
+		respondLocked(c, lock)
 		return
 	}
 
-	// 验证密码
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "密码错误"})
+	// 无论账号是否存在都执行 bcrypt 比较，统一响应时间
+	passwordHash := getDummyPasswordHash()
+	if found {
+		passwordHash = []byte(user.Password)
+	}
+	passwordErr := bcrypt.CompareHashAndPassword(passwordHash, []byte(input.Password))
+
+	if !found || passwordErr != nil {
+		recordLoginAudit(c, "login.failure", &user, found, identifier, "method=password")
+		handleLoginFailure(c, &user, found, identifier)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "账号或密码错误"})
 		return
 	}
 
+	if user.Disabled {
+		recordLoginAudit(c, "login.failure", &user, found, identifier, "method=password reason=disabled")
+		c.JSON(http.StatusForbidden, gin.H{"error": "账号已被停用，请联系管理员"})
+		return
+	}
+
+	// 登录成功，清除该账号的失败记录（停用的账号不清除）
+	clearFailedLogins(user.ID)
+
 	// 生成 JWT
 	token, err := utils.GenerateToken(user.ID)
 	if err != nil {
@@ -99,10 +169,44 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	if input.Mode == "cookie" {
+		if !respondCookieSession(c, token, &user) {
+			return
+		}
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"token": token,
+			"user":  user,
+		})
+	}
+	// 确认已把会话交给客户端后再记录登录成功
+	recordLoginAudit(c, "login.success", &user, found, identifier, "method=password")
+}
+
+// respondCookieSession 以 Cookie 认证模式完成登录，只返回 CSRF 令牌，失败时返回 false
+func respondCookieSession(c *gin.Context, token string, user *models.User) bool {
+	if !middleware.CookieAuthEnabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "未启用 Cookie 登录"})
+		return false
+	}
+
+	csrfToken, err := middleware.SetSessionCookies(c, token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token 生成失败"})
+		return false
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-		"user":  user,
+		"csrf_token": csrfToken,
+		"user":       user,
 	})
+	return true
+}
+
+// Logout 退出登录，清除 Cookie 认证模式下的登录 Cookie
+func Logout(c *gin.Context) {
+	middleware.ClearSessionCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "已退出登录"})
 }
 
 // GetProfile 获取用户信息
@@ -117,3 +221,40 @@ func GetProfile(c *gin.Context) {
 
 	c.JSON(http.StatusOK, user)
 }
+
+// recordFailedLogin 记录一次登录失败
+func recordFailedLogin(c *gin.Context, user *models.User, found bool, identifier string) {
+	attempt := models.LoginAttempt{
+		Identifier: strings.ToLower(identifier),
+		IP:         c.ClientIP(),
+	}
+	if found {
+		attempt.UserID = &user.ID
+	}
+	if err := database.DB.Create(&attempt).Error; err != nil {
+		log.Printf("记录登录失败信息出错: %v", err)
+	}
+}
+
+// recordLoginAudit 记录登录成功或失败，账号不存在时以输入的账号名作为目标
+func recordLoginAudit(c *gin.Context, action string, user *models.User, found bool, identifier, detail string) {
+	event := models.AuditEvent{
+		Action:     action,
+		TargetType: "identifier",
+		TargetID:   strings.ToLower(identifier),
+		Detail:     detail,
+	}
+	if found {
+		event.TargetType = "user"
+		event.TargetID = strconv.FormatUint(uint64(user.ID), 10)
+		if action == "login.success" {
+			event.ActorID = &user.ID
+		}
+	}
+	recordAudit(c, event)
+}
+
+// clearFailedLogins 清除账号的登录失败记录
+func clearFailedLogins(userID uint) {
+	database.DB.Where("user_id = ?", userID).Delete(&models.LoginAttempt{})
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// createLoginUser 创建可用密码登录的用户，并记录若干登录失败
+func createLoginUser(t *testing.T, username, password string) *models.User {
+	t.Helper()
+	user := createTestUser(t, username, username+"@example.com")
+	hash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	database.DB.Model(user).Update("password", string(hash))
+	for i := 0; i < 2; i++ {
+		database.DB.Create(&models.LoginAttempt{UserID: &user.ID, Identifier: username})
+	}
+	return user
+}
+
+// loginAuditCount 返回指定动作的审计事件数
+func loginAuditCount(action string) int64 {
+	var n int64
+	database.DB.Model(&models.AuditEvent{}).Where("action = ?", action).Count(&n)
+	return n
+}
+
+func TestLoginSuccessClearsFailures(t *testing.T) {
+	setupTestDB(t)
+	user := createLoginUser(t, "alice", "secret123")
+
+	r := gin.New()
+	r.POST("/api/auth/login", Login)
+
+	w := performRequest(r, http.MethodPost, "/api/auth/login", gin.H{"account": "alice", "password": "secret123"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("登录状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+	if n := failedLoginCount(user.ID); n != 0 {
+		t.Errorf("登录成功后仍有 %d 条失败记录", n)
+	}
+	if n := loginAuditCount("login.success"); n != 1 {
+		t.Errorf("login.success 事件数 = %d, want 1", n)
+	}
+}
+
+func TestLoginDisabledAccountKeepsFailures(t *testing.T) {
+	setupTestDB(t)
+	user := createLoginUser(t, "alice", "secret123")
+	database.DB.Model(user).Update("disabled", true)
+
+	r := gin.New()
+	r.POST("/api/auth/login", Login)
+
+	w := performRequest(r, http.MethodPost, "/api/auth/login", gin.H{"account": "alice", "password": "secret123"})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("停用账号登录状态码 = %d, want 403", w.Code)
+	}
+	if n := failedLoginCount(user.ID); n != 2 {
+		t.Errorf("停用账号的失败记录不应被清除, 剩余 %d 条", n)
+	}
+	if n := loginAuditCount("login.success"); n != 0 {
+		t.Errorf("停用账号不应记录 login.success")
+	}
+}
+
+func TestCookieLoginFailureIsNotAuditedAsSuccess(t *testing.T) {
+	setupTestDB(t)
+	t.Setenv("AUTH_COOKIE_MODE", "false")
+	createLoginUser(t, "alice", "secret123")
+
+	r := gin.New()
+	r.POST("/api/auth/login", Login)
+
+	w := performRequest(r, http.MethodPost, "/api/auth/login", gin.H{"account": "alice", "password": "secret123", "mode": "cookie"})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("未启用 Cookie 登录时状态码 = %d, want 400", w.Code)
+	}
+	if n := loginAuditCount("login.success"); n != 0 {
+		t.Errorf("没有建立会话时不应记录 login.success, got %d", n)
+	}
+}
@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"pomodoro-api/utils"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 日历订阅默认包含最近多少天的番茄钟
+var calendarFeedDays = utils.GetEnvInt("CALENDAR_FEED_DAYS", 90)
+
+// calendarFeedURL 返回订阅地址
+func calendarFeedURL(token string) string {
+	return strings.TrimRight(utils.GetEnv("PUBLIC_BASE_URL", "http://localhost:8080"), "/") + "/api/calendar/" + token + ".ics"
+}
+
+// GetCalendarFeed 查看日历订阅状态（密钥只在生成时显示）
+func GetCalendarFeed(c *gin.Context) {
+	var feed models.CalendarFeed
+	if err := database.DB.Where("user_id = ?", c.GetUint("user_id")).First(&feed).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"enabled": true, "feed": feed})
+}
+
+// RotateCalendarFeed 生成新的订阅地址，旧地址立即失效
+func RotateCalendarFeed(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "密钥生成失败"})
+		return
+	}
+	plain := models.CalendarFeedPrefix + secret
+
+	var feed models.CalendarFeed
+	database.DB.Where("user_id = ?", userID).First(&feed)
+	feed.UserID = userID
+	feed.Prefix = plain[:len(models.CalendarFeedPrefix)+6]
+	feed.TokenHash = utils.HashToken(plain)
+	feed.RotatedAt = time.Now()
+	feed.LastUsedAt = nil
+	if err := database.DB.Save(&feed).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存失败"})
+		return
+	}
+
+	recordAudit(c, models.AuditEvent{
+		ActorID:    &userID,
+		Action:     "calendar_feed.rotate",
+		TargetType: "calendar_feed",
+		TargetID:   strconv.FormatUint(uint64(feed.ID), 10),
+		Detail:     "prefix=" + feed.Prefix,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":     calendarFeedURL(plain),
+		"feed":    feed,
+		"message": "请妥善保存订阅地址，它只会显示这一次",
+	})
+}
+
+// DeleteCalendarFeed 关闭日历订阅
+func DeleteCalendarFeed(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var feed models.CalendarFeed
+	if err := database.DB.Where("user_id = ?", userID).First(&feed).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未开启日历订阅"})
+		return
+	}
+
+	// 彻底删除，之后可以重新开启
+	database.DB.Unscoped().Delete(&feed)
+	recordAudit(c, models.AuditEvent{
+		ActorID:    &userID,
+		Action:     "calendar_feed.delete",
+		TargetType: "calendar_feed",
+		TargetID:   strconv.FormatUint(uint64(feed.ID), 10),
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "已关闭日历订阅"})
+}
+
+// GetCalendarICS 以 iCalendar（RFC 5545）格式输出已完成的番茄钟
+// 地址中的密钥即凭证，不需要登录；默认包含最近 CALENDAR_FEED_DAYS 天，可用 from、to 指定
+func GetCalendarICS(c *gin.Context) {
+	token, ok := strings.CutSuffix(c.Param("file"), ".ics")
+	if !ok || !strings.HasPrefix(token, models.CalendarFeedPrefix) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "订阅地址无效"})
+		return
+	}
+
+	var feed models.CalendarFeed
+	if err := database.DB.Preload("User").Where("token_hash = ?", utils.HashToken(token)).First(&feed).Error; err != nil || feed.User.Disabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "订阅地址无效"})
+		return
+	}
+
+	start, end, ok := parseDateRange(c, calendarFeedDays)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	database.DB.Model(&feed).UpdateColumn("last_used_at", now)
+
+	c.Header("Content-Type", "text/calendar; charset=utf-8")
+	c.Header("Content-Disposition", `inline; filename="pomodoro.ics"`)
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Status(http.StatusOK)
+
+	w := c.Writer
+	writeLine := func(name, value string) {
+		w.WriteString(icsFold(name + ":" + value))
+	}
+
+	writeLine("BEGIN", "VCALENDAR")
+	writeLine("VERSION", "2.0")
+	writeLine("PRODID", "-//Pomodoro//Focus Sessions//ZH")
+	writeLine("CALSCALE", "GREGORIAN")
+	writeLine("METHOD", "PUBLISH")
+	writeLine("NAME", icsEscape(feed.User.Username+" 的番茄钟"))
+	writeLine("X-WR-CALNAME", icsEscape(feed.User.Username+" 的番茄钟"))
+	writeLine("REFRESH-INTERVAL;VALUE=DURATION", "PT1H")
+	writeLine("X-PUBLISHED-TTL", "PT1H")
+
+	host := "pomodoro"
+	if u, err := url.Parse(utils.GetEnv("PUBLIC_BASE_URL", "")); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+	stamp := icsTime(now)
+
+	query := exportPomodoroQuery(feed.UserID, &start, &end).
+		Where("pomodoros.completed = ? AND pomodoros.completed_at IS NOT NULL", true)
+	err := eachRow(query, func(p *exportPomodoro) error {
+		summary := p.Category
+		note := strings.TrimSpace(strings.SplitN(p.Note, "\n", 2)[0])
+		if note != "" {
+			summary += " - " + note
+		}
+
+		description := []string{fmt.Sprintf("时长 %d 分钟", p.Duration/60)}
+		if p.Task != nil {
+			description = append(description, "任务: "+*p.Task)
+		}
+		if p.Tags != nil {
+			description = append(description, "标签: "+*p.Tags)
+		}
+		if p.FocusScore != nil {
+			description = append(description, fmt.Sprintf("专注评分: %d/5", *p.FocusScore))
+		}
+		if p.Note != "" {
+			description = append(description, "", p.Note)
+		}
+
+		writeLine("BEGIN", "VEVENT")
+		writeLine("UID", fmt.Sprintf("pomodoro-%d@%s", p.ID, host))
+		writeLine("DTSTAMP", stamp)
+		writeLine("DTSTART", icsTime(p.StartedAt))
+		writeLine("DTEND", icsTime(*p.CompletedAt))
+		writeLine("SUMMARY", icsEscape(summary))
+		writeLine("DESCRIPTION", icsEscape(strings.Join(description, "\n")))
+		if p.Category != "" {
+			writeLine("CATEGORIES", icsEscape(p.Category))
+		}
+		if color := cssColorName(p.CategoryColor); color != "" {
+			writeLine("COLOR", color)
+		}
+		writeLine("END", "VEVENT")
+		return nil
+	})
+	writeLine("END", "VCALENDAR")
+
+	// 响应已开始写入，出错时只能记录日志
+	if err != nil {
+		log.Printf("生成日历订阅失败: user_id=%d, err=%v", feed.UserID, err)
+	}
+}
+
+// icsTime 格式化为 UTC 时间
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// icsEscape 转义 TEXT 类型的值
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", "").Replace(s)
+}
+
+// icsFold 把一行折叠为不超过 75 字节的多行，不拆开 UTF-8 字符，行尾为 CRLF
+func icsFold(line string) string {
+	var b strings.Builder
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// 续行开头的空格占一个字节
+		limit = 74
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// cssColors COLOR 属性（RFC 7986）只接受 CSS 颜色名，分类颜色取最接近的一个
+var cssColors = []struct {
+	name    string
+	r, g, b int
+}{
+	{"black", 0, 0, 0}, {"gray", 128, 128, 128}, {"silver", 192, 192, 192}, {"white", 255, 255, 255},
+	{"maroon", 128, 0, 0}, {"red", 255, 0, 0}, {"tomato", 255, 99, 71}, {"salmon", 250, 128, 114},
+	{"orange", 255, 165, 0}, {"gold", 255, 215, 0}, {"yellow", 255, 255, 0}, {"olive", 128, 128, 0},
+	{"green", 0, 128, 0}, {"limegreen", 50, 205, 50}, {"mediumaquamarine", 102, 205, 170}, {"teal", 0, 128, 128},
+	{"turquoise", 64, 224, 208}, {"skyblue", 135, 206, 235}, {"dodgerblue", 30, 144, 255}, {"blue", 0, 0, 255},
+	{"navy", 0, 0, 128}, {"slateblue", 106, 90, 205}, {"purple", 128, 0, 128}, {"orchid", 218, 112, 214},
+	{"hotpink", 255, 105, 180}, {"pink", 255, 192, 203}, {"brown", 165, 42, 42}, {"chocolate", 210, 105, 30},
+}
+
+// cssColorName 返回与 #RRGGBB 最接近的 CSS 颜色名，无法解析时返回空
+func cssColorName(hex string) string {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) != 6 {
+		return ""
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return ""
+	}
+	r, g, b := int(v>>16), int(v>>8&0xff), int(v&0xff)
+
+	best, bestDist := "", -1
+	for _, color := range cssColors {
+		dist := (r-color.r)*(r-color.r) + (g-color.g)*(g-color.g) + (b-color.b)*(b-color.b)
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = color.name, dist
+		}
+	}
+	return best
+}
@@ -0,0 +1,428 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"pomodoro-api/utils"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 每个用户最多的日历匹配规则数
+var maxCalendarRules = utils.GetEnvInt("MAX_CALENDAR_RULES", 50)
+
+// 未指定日期范围时导入从今天开始多少天的事件
+const calendarImportDays = 14
+
+// 日历事件的导入状态
+const (
+	calendarEventOK        = "ok"
+	calendarEventDuplicate = "duplicate" // 之前已导入过
+	calendarEventSkipped   = "skipped"
+)
+
+// CalendarRuleInput 关键字匹配规则
+type CalendarRuleInput struct {
+	Keyword    string `json:"keyword" binding:"required,max=50"`
+	CategoryID uint   `json:"category_id" binding:"required"`
+}
+
+// CalendarImportEvent 日历中的一个事件及其导入结果
+type CalendarImportEvent struct {
+	UID        string    `json:"uid"`
+	Summary    string    `json:"summary"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Date       string    `json:"date"`
+	CategoryID *uint     `json:"category_id"`
+	Estimate   int       `json:"estimated_pomodoros"`
+	Status     string    `json:"status"`
+	Reason     string    `json:"reason,omitempty"`
+	TaskID     uint      `json:"task_id,omitempty"`
+
+	key string
+}
+
+// CalendarImportReport 日历导入预览或结果
+type CalendarImportReport struct {
+	Committed bool                  `json:"committed"`
+	From      string                `json:"from"`
+	To        string                `json:"to"`
+	Imported  int                   `json:"imported"` // 预览时为可导入的事件数
+	Skipped   int                   `json:"skipped"`
+	Dates     []string              `json:"dates"` // 涉及的计划日期
+	Events    []CalendarImportEvent `json:"events"`
+}
+
+// loadCalendarRules 按顺序加载用户的匹配规则
+func loadCalendarRules(userID uint) []models.CalendarRule {
+	rules := []models.CalendarRule{}
+	database.DB.Where("user_id = ?", userID).Order("position, id").Find(&rules)
+	return rules
+}
+
+// matchCalendarRule 返回第一条关键字出现在事件标题或分类中的规则对应的分类
+func matchCalendarRule(rules []models.CalendarRule, event utils.ICalEvent) *uint {
+	fields := append([]string{event.Summary}, event.Categories...)
+	for i := range rules {
+		keyword := strings.ToLower(rules[i].Keyword)
+		for _, field := range fields {
+			if strings.Contains(strings.ToLower(field), keyword) {
+				return &rules[i].CategoryID
+			}
+		}
+	}
+	return nil
+}
+
+// GetCalendarImportSettings 获取日历导入的订阅地址和匹配规则
+func GetCalendarImportSettings(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var source models.CalendarImportSource
+	database.DB.Where("user_id = ?", userID).First(&source)
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":              source.URL,
+		"last_imported_at": source.LastImportedAt,
+		"rules":            loadCalendarRules(userID),
+	})
+}
+
+// SaveCalendarImportSettings 保存订阅地址和匹配规则
+// 规则整体替换，按数组顺序匹配；url 为空字符串表示清除，不传则不修改
+func SaveCalendarImportSettings(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		URL   *string             `json:"url" binding:"omitempty,max=500"`
+		Rules []CalendarRuleInput `json:"rules" binding:"dive"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(input.Rules) > maxCalendarRules {
+		rejectLimit(c, "规则数量", len(input.Rules), maxCalendarRules)
+		return
+	}
+	if input.URL != nil && *input.URL != "" {
+		if _, err := utils.ParseFetchURL(*input.URL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	rules := make([]models.CalendarRule, 0, len(input.Rules))
+	for i, rule := range input.Rules {
+		keyword := strings.TrimSpace(rule.Keyword)
+		if keyword == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "关键字不能为空"})
+			return
+		}
+		if err := checkTaskCategory(userID, rule.CategoryID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		rules = append(rules, models.CalendarRule{UserID: userID, Keyword: keyword, CategoryID: rule.CategoryID, Position: i})
+	}
+
+	var source models.CalendarImportSource
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if input.URL != nil {
+			tx.Where("user_id = ?", userID).First(&source)
+			source.UserID = userID
+			source.URL = strings.TrimSpace(*input.URL)
+			if err := tx.Save(&source).Error; err != nil {
+				return err
+			}
+		}
+		if input.Rules == nil {
+			return nil
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.CalendarRule{}).Error; err != nil {
+			return err
+		}
+		if len(rules) == 0 {
+			return nil
+		}
+		return tx.Create(&rules).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存失败"})
+		return
+	}
+
+	GetCalendarImportSettings(c)
+}
+
+// readCalendarData 读取上传的 .ics 文件，或从请求中的 url、已保存的订阅地址下载
+func readCalendarData(c *gin.Context, userID uint) ([]byte, bool) {
+	maxBytes := int64(utils.GetEnvInt("MAX_IMPORT_BYTES", 5<<20))
+
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请上传 .ics 文件"})
+			return nil, false
+		}
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "读取文件失败"})
+			return nil, false
+		}
+		defer file.Close()
+		data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+		if err != nil || int64(len(data)) > maxBytes {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "读取文件失败或文件过大"})
+			return nil, false
+		}
+		return data, true
+	}
+
+	var input struct {
+		URL string `json:"url" binding:"max=500"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+	}
+	if input.URL == "" {
+		var source models.CalendarImportSource
+		database.DB.Where("user_id = ?", userID).First(&source)
+		input.URL = source.URL
+	}
+	if input.URL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请上传 .ics 文件或提供订阅地址"})
+		return nil, false
+	}
+
+	fetcher := utils.NewSafeFetcher(maxBytes)
+	fetcher.AllowPrivate = utils.GetEnv("CALENDAR_FETCH_ALLOW_PRIVATE", "false") == "true"
+	ctx, cancel := context.WithTimeout(c.Request.Context(), fetcher.Timeout)
+	defer cancel()
+	data, err := fetcher.Fetch(ctx, input.URL)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, utils.ErrForbiddenAddress) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return data, true
+}
+
+// calendarImportRange 解析导入的日期范围，默认从今天开始 calendarImportDays 天
+func calendarImportRange(c *gin.Context) (start, end time.Time, ok bool) {
+	if c.Query("from") == "" && c.Query("to") == "" {
+		now := time.Now()
+		start = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+		return start, start.AddDate(0, 0, calendarImportDays), true
+	}
+	return parseDateRange(c, calendarImportDays)
+}
+
+// ImportCalendar 把 .ics 中的事件导入为对应日期计划中的任务
+// 按关键字规则匹配分类，未匹配的事件默认跳过（include_unmatched=true 时导入为无分类任务）；
+// 预估番茄数按事件时长和默认番茄时长四舍五入，并累加到当天计划的目标番茄数，
+// 打卡统计中的今日目标随之按计划目标折算；
+// 日期按 timezone 参数（默认服务器时区）计算；dry_run=true 时只返回预览
+func ImportCalendar(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	commit := c.Query("dry_run") != "true"
+	includeUnmatched := c.Query("include_unmatched") == "true"
+
+	start, end, ok := calendarImportRange(c)
+	if !ok {
+		return
+	}
+	loc := time.Local
+	if tz := c.Query("timezone"); tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的时区"})
+			return
+		}
+		loc = l
+	}
+
+	data, ok := readCalendarData(c, userID)
+	if !ok {
+		return
+	}
+	events, err := utils.ParseICalEvents(data, start, end, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var setting models.Setting
+	database.DB.Where("user_id = ?", userID).First(&setting)
+	pomodoroLength := setting.DefaultDuration
+	if pomodoroLength <= 0 {
+		pomodoroLength = 1500
+	}
+	rules := loadCalendarRules(userID)
+
+	keys := make([]string, 0, len(events))
+	for _, event := range events {
+		keys = append(keys, event.Key())
+	}
+	imported := map[string]bool{}
+	if len(keys) > 0 {
+		var existing []string
+		database.DB.Model(&models.CalendarEventLink{}).Where("user_id = ? AND event_key IN ?", userID, keys).Pluck("event_key", &existing)
+		for _, key := range existing {
+			imported[key] = true
+		}
+	}
+
+	report := CalendarImportReport{
+		Committed: commit,
+		From:      start.Format("2006-01-02"),
+		To:        end.AddDate(0, 0, -1).Format("2006-01-02"),
+		Dates:     []string{},
+		Events:    make([]CalendarImportEvent, 0, len(events)),
+	}
+	dates := map[string]bool{}
+	for _, event := range events {
+		item := CalendarImportEvent{
+			UID:     event.UID,
+			Summary: event.Summary,
+			Start:   event.Start,
+			End:     event.End,
+			Date:    event.Start.In(loc).Format("2006-01-02"),
+			Status:  calendarEventOK,
+			key:     event.Key(),
+		}
+		item.CategoryID = matchCalendarRule(rules, event)
+		seconds := int(event.End.Sub(event.Start).Seconds())
+		item.Estimate = min(max((seconds+pomodoroLength/2)/pomodoroLength, 1), 100)
+
+		switch {
+		case imported[item.key]:
+			item.Status, item.Reason = calendarEventDuplicate, "之前已导入"
+		case strings.EqualFold(event.Status, "CANCELLED"):
+			item.Status, item.Reason = calendarEventSkipped, "事件已取消"
+		case event.AllDay:
+			item.Status, item.Reason = calendarEventSkipped, "全天事件"
+		case item.CategoryID == nil && !includeUnmatched:
+			item.Status, item.Reason = calendarEventSkipped, "没有匹配的规则"
+		}
+
+		if item.Status == calendarEventOK {
+			imported[item.key] = true
+			report.Imported++
+			if !dates[item.Date] {
+				dates[item.Date] = true
+				report.Dates = append(report.Dates, item.Date)
+			}
+		} else {
+			report.Skipped++
+		}
+		report.Events = append(report.Events, item)
+	}
+
+	if !commit || report.Imported == 0 {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	var count int64
+	database.DB.Model(&models.Task{}).Where("user_id = ?", userID).Count(&count)
+	if total := int(count) + report.Imported; total > maxTasksPerUser {
+		rejectLimit(c, "任务数量", total, maxTasksPerUser)
+		return
+	}
+
+	var maxPosition int
+	database.DB.Model(&models.Task{}).Where("user_id = ?", userID).Select("COALESCE(MAX(position), 0)").Scan(&maxPosition)
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		plans := map[string]*models.DailyPlan{}
+		for i := range report.Events {
+			item := &report.Events[i]
+			if item.Status != calendarEventOK {
+				continue
+			}
+
+			title := strings.TrimSpace(item.Summary)
+			if title == "" {
+				title = "（无标题）"
+			}
+			if utf8.RuneCountInString(title) > 100 {
+				title = string([]rune(title)[:100])
+			}
+			note := events[i].Description
+			if utf8.RuneCountInString(note) > maxNoteLength {
+				note = string([]rune(note)[:maxNoteLength])
+			}
+			maxPosition++
+			task := models.Task{
+				UserID:             userID,
+				CategoryID:         item.CategoryID,
+				Title:              title,
+				Note:               note,
+				EstimatedPomodoros: item.Estimate,
+				DueDate:            &item.Date,
+				Status:             models.TaskStatusTodo,
+				Position:           maxPosition,
+			}
+			if err := tx.Create(&task).Error; err != nil {
+				return err
+			}
+			item.TaskID = task.ID
+
+			plan, ok := plans[item.Date]
+			if !ok {
+				plan = &models.DailyPlan{}
+				if err := tx.Where("user_id = ? AND date = ?", userID, item.Date).First(plan).Error; err != nil {
+					*plan = models.DailyPlan{UserID: userID, Date: item.Date}
+				}
+				plans[item.Date] = plan
+			}
+			plan.TargetPomodoros = min(plan.TargetPomodoros+item.Estimate, 100)
+			if err := tx.Omit("Tasks").Save(plan).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(plan).Association("Tasks").Append(&task); err != nil {
+				return err
+			}
+
+			link := models.CalendarEventLink{UserID: userID, EventKey: item.key, TaskID: task.ID, Date: item.Date}
+			if err := tx.Create(&link).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		return tx.Model(&models.CalendarImportSource{}).Where("user_id = ?", userID).Update("last_imported_at", now).Error
+	})
+	if err != nil {
+		log.Printf("导入日历失败: user_id=%d, err=%v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "导入失败"})
+		return
+	}
+
+	recordChange(c, "calendar.import", "task", 0, userID, nil, gin.H{
+		"from":     report.From,
+		"to":       report.To,
+		"imported": report.Imported,
+		"skipped":  report.Skipped,
+		"dates":    report.Dates,
+	})
+
+	c.JSON(http.StatusOK, report)
+}
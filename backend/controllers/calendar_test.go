@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"net/http"
+	"net/url"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestICSEscape(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"普通文本", "普通文本"},
+		{`a\b`, `a\\b`},
+		{"a;b,c", `a\;b\,c`},
+		{"第一行\n第二行", `第一行\n第二行`},
+		{"第一行\r\n第二行", `第一行\n第二行`},
+		{"多余的\r回车", "多余的回车"},
+		{`\;`, `\\\;`},
+	}
+	for _, tt := range tests {
+		if got := icsEscape(tt.in); got != tt.want {
+			t.Errorf("icsEscape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestICSFold(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"短行", "SUMMARY:专注"},
+		{"正好 75 字节", "SUMMARY:" + strings.Repeat("a", 67)},
+		{"76 字节", "SUMMARY:" + strings.Repeat("a", 68)},
+		{"很长的 ASCII", "DESCRIPTION:" + strings.Repeat("abcdefghij", 30)},
+		{"中文", "SUMMARY:" + strings.Repeat("番茄", 60)},
+		// 三字节字符跨过第 75 字节
+		{"多字节字符在边界", "SUMMARY:" + strings.Repeat("a", 66) + strings.Repeat("钟", 20)},
+		{"四字节字符", "SUMMARY:" + strings.Repeat("🍅", 40)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folded := icsFold(tt.line)
+			if !strings.HasSuffix(folded, "\r\n") {
+				t.Fatalf("折叠结果没有以 CRLF 结尾: %q", folded)
+			}
+			lines := strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n")
+			for i, l := range lines {
+				if len(l) > 75 {
+					t.Errorf("第 %d 行 %d 字节，超过 75 字节", i+1, len(l))
+				}
+				if i > 0 && !strings.HasPrefix(l, " ") {
+					t.Errorf("续行 %q 没有以空格开头", l)
+				}
+				if !utf8.ValidString(l) {
+					t.Errorf("第 %d 行拆开了 UTF-8 字符: %q", i+1, l)
+				}
+				if strings.Contains(l, "\n") {
+					t.Errorf("第 %d 行含有换行符", i+1)
+				}
+			}
+			if len(tt.line) <= 75 && len(lines) != 1 {
+				t.Errorf("不超过 75 字节的行不应折叠，得到 %d 行", len(lines))
+			}
+			if unfolded := strings.ReplaceAll(strings.TrimSuffix(folded, "\r\n"), "\r\n ", ""); unfolded != tt.line {
+				t.Errorf("展开后 = %q, want %q", unfolded, tt.line)
+			}
+		})
+	}
+}
+
+func TestGetCalendarICS(t *testing.T) {
+	setupTestDB(t)
+	t.Setenv("PUBLIC_BASE_URL", "https://pomodoro.example.com")
+	user := createTestUser(t, "alice", "alice@example.com")
+
+	var category models.Category
+	database.DB.Where("user_id = ?", user.ID).First(&category)
+	database.DB.Model(&category).Update("color", "#ff0000")
+
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	end := start.Add(25 * time.Minute)
+	note := "整理周报, 顺便; 回复邮件\n" + strings.Repeat("很长的备注", 20)
+	pomodoro := models.Pomodoro{
+		UserID: user.ID, CategoryID: category.ID, Duration: 1500, PlannedDuration: 1500,
+		Completed: true, StartedAt: start, CompletedAt: &end, Note: note,
+	}
+	database.DB.Create(&pomodoro)
+	// 放弃的番茄钟不输出
+	database.DB.Create(&models.Pomodoro{
+		UserID: user.ID, CategoryID: category.ID, Duration: 300, PlannedDuration: 1500,
+		StartedAt: start.Add(time.Hour),
+	})
+
+	r := gin.New()
+	r.POST("/api/calendar/feed", withUser(user.ID), RotateCalendarFeed)
+	r.GET("/api/calendar/:file", GetCalendarICS)
+
+	var rotated struct {
+		URL string `json:"url"`
+	}
+	decodeJSON(t, performRequest(r, http.MethodPost, "/api/calendar/feed", nil), &rotated)
+	feedURL, err := url.Parse(rotated.URL)
+	if err != nil || !strings.HasPrefix(feedURL.Path, "/api/calendar/cal_") {
+		t.Fatalf("订阅地址 = %q", rotated.URL)
+	}
+
+	w := performRequest(r, http.MethodGet, feedURL.Path+"?from=2026-03-01&to=2026-03-03", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/calendar; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	body := w.Body.String()
+	if !strings.HasSuffix(body, "\r\n") {
+		t.Error("响应没有以 CRLF 结尾")
+	}
+	if strings.Contains(strings.ReplaceAll(body, "\r\n", ""), "\n") {
+		t.Error("响应中有不带 CR 的换行")
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("行超过 75 字节: %q", line)
+		}
+	}
+
+	unfolded := strings.ReplaceAll(body, "\r\n ", "")
+	want := []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"X-WR-CALNAME:alice 的番茄钟\r\n",
+		"UID:pomodoro-" + strconv.FormatUint(uint64(pomodoro.ID), 10) + "@pomodoro.example.com\r\n",
+		"DTSTART:20260302T090000Z\r\n",
+		"DTEND:20260302T092500Z\r\n",
+		"SUMMARY:" + category.Name + ` - 整理周报\, 顺便\; 回复邮件` + "\r\n",
+		`DESCRIPTION:时长 25 分钟\n\n整理周报\, 顺便\; 回复邮件\n` + strings.Repeat("很长的备注", 20) + "\r\n",
+		"COLOR:red\r\n",
+		"END:VEVENT\r\nEND:VCALENDAR\r\n",
+	}
+	for _, s := range want {
+		if !strings.Contains(unfolded, s) {
+			t.Errorf("响应中缺少 %q\n%s", s, unfolded)
+		}
+	}
+	if n := strings.Count(unfolded, "BEGIN:VEVENT"); n != 1 {
+		t.Errorf("输出 %d 个事件, want 1", n)
+	}
+
+	var feed models.CalendarFeed
+	database.DB.Where("user_id = ?", user.ID).First(&feed)
+	if feed.LastUsedAt == nil {
+		t.Error("访问后应记录 last_used_at")
+	}
+
+	// 密钥错误、缺少后缀或用户已停用时返回 404
+	for _, path := range []string{"/api/calendar/cal_wrong.ics", strings.TrimSuffix(feedURL.Path, ".ics")} {
+		if w := performRequest(r, http.MethodGet, path, nil); w.Code != http.StatusNotFound {
+			t.Errorf("%s 状态码 = %d, want 404", path, w.Code)
+		}
+	}
+	database.DB.Model(user).Update("disabled", true)
+	if w := performRequest(r, http.MethodGet, feedURL.Path, nil); w.Code != http.StatusNotFound {
+		t.Errorf("停用用户的订阅状态码 = %d, want 404", w.Code)
+	}
+}
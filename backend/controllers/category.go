@@ -1,21 +1,28 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"pomodoro-api/database"
 	"pomodoro-api/models"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-// GetCategories 获取所有分类
+// GetCategories 获取所有分类，format=tree 时返回嵌套的分类树，include_archived=true 时包含已归档分类
 func GetCategories(c *gin.Context) {
 	userID := c.GetUint("user_id")
 
-	var categories []models.Category
-	database.DB.Where("user_id = ?", userID).Find(&categories)
+	tree := loadCategoryTree(userID)
+	includeArchived := c.Query("include_archived") == "true"
+	if c.Query("format") == "tree" {
+		c.JSON(http.StatusOK, tree.nested(includeArchived))
+		return
+	}
 
-	c.JSON(http.StatusOK, categories)
+	c.JSON(http.StatusOK, tree.flat(includeArchived))
 }
 
 // CreateCategory 创建分类
@@ -23,9 +30,10 @@ func CreateCategory(c *gin.Context) {
 	userID := c.GetUint("user_id")
 
 	var input struct {
-		Name  string `json:"name" binding:"required"`
-		Color string `json:"color"`
-		Icon  string `json:"icon"`
+		Name     string `json:"name" binding:"required,max=30"`
+		Color    string `json:"color" binding:"max=20"`
+		Icon     string `json:"icon" binding:"max=50"`
+		ParentID *uint  `json:"parent_id"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -33,11 +41,29 @@ func CreateCategory(c *gin.Context) {
 		return
 	}
 
+	// 检查分类数量上限（已归档的分类不计入）
+	var count int64
+	database.DB.Model(&models.Category{}).Where("user_id = ? AND archived = ?", userID, false).Count(&count)
+	if count >= int64(maxCategoriesPerUser) {
+		rejectLimit(c, "分类数量", int(count)+1, maxCategoriesPerUser)
+		return
+	}
+
+	if input.ParentID != nil && *input.ParentID != 0 {
+		if err := loadCategoryTree(userID).checkCategoryParent(0, *input.ParentID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		input.ParentID = nil
+	}
+
 	category := models.Category{
-		UserID: userID,
-		Name:   input.Name,
-		Color:  input.Color,
-		Icon:   input.Icon,
+		UserID:   userID,
+		Name:     input.Name,
+		Color:    input.Color,
+		Icon:     input.Icon,
+		ParentID: input.ParentID,
 	}
 
 	if err := database.DB.Create(&category).Error; err != nil {
@@ -59,10 +85,13 @@ func UpdateCategory(c *gin.Context) {
 		return
 	}
 
+	before := category
+
 	var input struct {
-		Name  string `json:"name"`
-		Color string `json:"color"`
-		Icon  string `json:"icon"`
+		Name     string `json:"name" binding:"max=30"`
+		Color    string `json:"color" binding:"max=20"`
+		Icon     string `json:"icon" binding:"max=50"`
+		ParentID *uint  `json:"parent_id"` // 不传表示不修改，0 表示移动到顶级
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -78,12 +107,26 @@ func UpdateCategory(c *gin.Context) {
 	}
 	category.Icon = input.Icon
 
+	if input.ParentID != nil {
+		if err := loadCategoryTree(userID).checkCategoryParent(category.ID, *input.ParentID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if *input.ParentID == 0 {
+			category.ParentID = nil
+		} else {
+			category.ParentID = input.ParentID
+		}
+	}
+
 	database.DB.Save(&category)
+	recordChange(c, "category.update", "category", category.ID, userID, before, category)
 
 	c.JSON(http.StatusOK, category)
 }
 
 // DeleteCategory 删除分类
+// 带 reassign_to 参数时先把番茄钟和子分类转移到目标分类；否则分类下有数据时拒绝删除
 func DeleteCategory(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	categoryID := c.Param("id")
@@ -94,15 +137,173 @@ func DeleteCategory(c *gin.Context) {
 		return
 	}
 
+	if reassignTo := c.Query("reassign_to"); reassignTo != "" {
+		targetID, err := strconv.ParseUint(reassignTo, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "目标分类无效"})
+			return
+		}
+		respondMergeCategory(c, &category, uint(targetID))
+		return
+	}
+
 	// 检查是否有番茄钟记录
 	var count int64
-	database.DB.Model(&models.Pomodoro{}).Where("category_id = ?", categoryID).Count(&count)
+	database.DB.Model(&models.Pomodoro{}).Where("user_id = ? AND category_id = ?", userID, category.ID).Count(&count)
 	if count > 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "该分类下还有番茄钟记录，无法删除"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该分类下还有番茄钟记录，请指定 reassign_to 转移后删除，或归档该分类"})
 		return
 	}
 
-	database.DB.Delete(&category)
+	// 检查是否有子分类
+	database.DB.Model(&models.Category{}).Where("user_id = ? AND parent_id = ?", userID, category.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该分类下还有子分类，无法删除"})
+		return
+	}
+
+	// 清空任务的分类和删除分类在同一事务中完成，避免任务引用已删除的分类
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Task{}).
+			Where("user_id = ? AND category_id = ?", userID, category.ID).
+			Update("category_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&category).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+		return
+	}
+	recordChange(c, "category.delete", "category", category.ID, userID, category, nil)
 
 	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 }
+
+// MergeCategory 把分类合并到另一个分类：番茄钟和子分类全部转移后删除原分类
+func MergeCategory(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var category models.Category
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&category).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "分类不存在"})
+		return
+	}
+
+	var input struct {
+		TargetID uint `json:"target_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondMergeCategory(c, &category, input.TargetID)
+}
+
+// respondMergeCategory 执行合并并记录审计
+func respondMergeCategory(c *gin.Context, source *models.Category, targetID uint) {
+	userID := c.GetUint("user_id")
+
+	moved, err := mergeCategory(userID, source, targetID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actorID := userID
+	recordAudit(c, models.AuditEvent{
+		ActorID:    &actorID,
+		OwnerID:    &actorID,
+		Action:     "category.merge",
+		TargetType: "category",
+		TargetID:   strconv.FormatUint(uint64(source.ID), 10),
+		Detail:     "target_id=" + strconv.FormatUint(uint64(targetID), 10) + " pomodoros=" + strconv.FormatInt(moved, 10),
+		Before:     auditSnapshot(source),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "合并成功",
+		"target_id":       targetID,
+		"moved_pomodoros": moved,
+	})
+}
+
+// mergeCategory 在一个事务中把 source 的番茄钟、子分类和任务转移到 targetID，然后删除 source
+func mergeCategory(userID uint, source *models.Category, targetID uint) (int64, error) {
+	tree := loadCategoryTree(userID)
+	if _, ok := tree.byID[targetID]; !ok {
+		return 0, errors.New("目标分类不存在")
+	}
+	if targetID == source.ID || tree.isDescendant(targetID, source.ID) {
+		return 0, errors.New("不能合并到自身或其子分类")
+	}
+	for _, child := range tree.children[source.ID] {
+		if err := tree.checkCategoryParent(child, targetID); err != nil {
+			return 0, err
+		}
+	}
+
+	var moved int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Pomodoro{}).
+			Where("user_id = ? AND category_id = ?", userID, source.ID).
+			Update("category_id", targetID)
+		if result.Error != nil {
+			return result.Error
+		}
+		moved = result.RowsAffected
+
+		if err := tx.Model(&models.Category{}).
+			Where("user_id = ? AND parent_id = ?", userID, source.ID).
+			Update("parent_id", targetID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Task{}).
+			Where("user_id = ? AND category_id = ?", userID, source.ID).
+			Update("category_id", targetID).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(source).Error
+	})
+	if err != nil {
+		return 0, errors.New("合并失败")
+	}
+	return moved, nil
+}
+
+// ArchiveCategory 归档或取消归档分类（包括全部子分类）
+// 取消归档时同时恢复上级分类，保证该分类能在列表中显示
+func ArchiveCategory(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		Archived *bool `json:"archived" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tree := loadCategoryTree(userID)
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	category, ok := tree.byID[uint(id)]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "分类不存在"})
+		return
+	}
+
+	ids := append([]uint{category.ID}, tree.descendants(category.ID)...)
+	if !*input.Archived {
+		ids = append(tree.ancestors(category.ID), tree.descendants(category.ID)...)
+	}
+	database.DB.Model(&models.Category{}).Where("user_id = ? AND id IN ?", userID, ids).Update("archived", *input.Archived)
+
+	before := *category
+	category.Archived = *input.Archived
+	recordChange(c, "category.archive", "category", category.ID, userID, before, category)
+
+	c.JSON(http.StatusOK, category)
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestDeleteCategoryClearsTasksInTransaction(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+
+	r := gin.New()
+	r.Use(withUser(user.ID))
+	r.DELETE("/api/categories/:id", DeleteCategory)
+
+	category := models.Category{UserID: user.ID, Name: "阅读", Color: "#3b82f6"}
+	database.DB.Create(&category)
+	task := models.Task{UserID: user.ID, Title: "读论文", CategoryID: &category.ID, EstimatedPomodoros: 1}
+	database.DB.Create(&task)
+	path := fmt.Sprintf("/api/categories/%d", category.ID)
+
+	// 删除分类失败时任务的分类不变
+	failDelete := func(db *gorm.DB) {
+		if db.Statement.Table == "categories" {
+			db.AddError(errors.New("disk I/O error"))
+		}
+	}
+	if err := database.DB.Callback().Delete().Before("gorm:delete").Register("test:fail_category", failDelete); err != nil {
+		t.Fatal(err)
+	}
+	w := performRequest(r, http.MethodDelete, path, nil)
+	database.DB.Callback().Delete().Remove("test:fail_category")
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("删除失败时状态码 = %d, want 500", w.Code)
+	}
+	database.DB.First(&task, task.ID)
+	if task.CategoryID == nil || *task.CategoryID != category.ID {
+		t.Errorf("删除失败后任务分类 = %v, want %d", task.CategoryID, category.ID)
+	}
+
+	if w := performRequest(r, http.MethodDelete, path, nil); w.Code != http.StatusOK {
+		t.Fatalf("删除状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+	database.DB.First(&task, task.ID)
+	if task.CategoryID != nil {
+		t.Errorf("删除分类后任务分类 = %d, want nil", *task.CategoryID)
+	}
+	if err := database.DB.First(&models.Category{}, category.ID).Error; err == nil {
+		t.Error("分类未删除")
+	}
+}
@@ -0,0 +1,260 @@
+package controllers
+
+import (
+	"errors"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"pomodoro-api/utils"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxCategoryDepth 分类最多嵌套的层数（顶级分类为第 1 层）
+var maxCategoryDepth = utils.GetEnvInt("MAX_CATEGORY_DEPTH", 5)
+
+// categoryTree 用户的分类层级关系
+type categoryTree struct {
+	byID     map[uint]*models.Category
+	children map[uint][]uint // 0 表示顶级
+	order    []uint
+}
+
+// loadCategoryTree 加载用户的全部分类
+func loadCategoryTree(userID uint) *categoryTree {
+	var categories []models.Category
+	database.DB.Where("user_id = ?", userID).Order("id ASC").Find(&categories)
+	return newCategoryTree(categories)
+}
+
+func newCategoryTree(categories []models.Category) *categoryTree {
+	tree := &categoryTree{
+		byID:     make(map[uint]*models.Category, len(categories)),
+		children: make(map[uint][]uint),
+	}
+	for i := range categories {
+		tree.byID[categories[i].ID] = &categories[i]
+		tree.order = append(tree.order, categories[i].ID)
+	}
+	for _, id := range tree.order {
+		parent := tree.parentOf(id)
+		tree.children[parent] = append(tree.children[parent], id)
+	}
+	return tree
+}
+
+// parentOf 返回上级分类 ID，上级不存在（已删除）时视为顶级
+func (t *categoryTree) parentOf(id uint) uint {
+	category, ok := t.byID[id]
+	if !ok || category.ParentID == nil {
+		return 0
+	}
+	if _, ok := t.byID[*category.ParentID]; !ok {
+		return 0
+	}
+	return *category.ParentID
+}
+
+// ancestors 返回从顶级分类到自身的 ID 链
+func (t *categoryTree) ancestors(id uint) []uint {
+	var chain []uint
+	for cur := id; cur != 0 && len(chain) <= len(t.byID); cur = t.parentOf(cur) {
+		chain = append([]uint{cur}, chain...)
+	}
+	return chain
+}
+
+// depth 返回分类所在层级，顶级为 0
+func (t *categoryTree) depth(id uint) int {
+	return len(t.ancestors(id)) - 1
+}
+
+// ancestorAt 返回分类在指定层级上的祖先，分类本身更浅时返回自身
+func (t *categoryTree) ancestorAt(id uint, level int) uint {
+	chain := t.ancestors(id)
+	if level >= len(chain) {
+		return id
+	}
+	return chain[level]
+}
+
+// path 返回分类的完整路径
+func (t *categoryTree) path(id uint) string {
+	var names []string
+	for _, cid := range t.ancestors(id) {
+		names = append(names, t.byID[cid].Name)
+	}
+	return strings.Join(names, " > ")
+}
+
+// isDescendant 判断 id 是否为 ancestor 的子孙分类
+func (t *categoryTree) isDescendant(id, ancestor uint) bool {
+	for _, cid := range t.ancestors(id) {
+		if cid == ancestor && cid != id {
+			return true
+		}
+	}
+	return false
+}
+
+// height 返回分类下子树的层数，没有子分类时为 0
+func (t *categoryTree) height(id uint) int {
+	h := 0
+	for _, child := range t.children[id] {
+		if ch := t.height(child) + 1; ch > h {
+			h = ch
+		}
+	}
+	return h
+}
+
+// descendants 返回分类下所有子孙分类的 ID
+func (t *categoryTree) descendants(id uint) []uint {
+	var ids []uint
+	for _, child := range t.children[id] {
+		ids = append(ids, child)
+		ids = append(ids, t.descendants(child)...)
+	}
+	return ids
+}
+
+// flat 返回带完整路径的分类列表，includeArchived 为 false 时不含已归档分类
+func (t *categoryTree) flat(includeArchived bool) []models.Category {
+	categories := make([]models.Category, 0, len(t.order))
+	for _, id := range t.order {
+		if !includeArchived && t.byID[id].Archived {
+			continue
+		}
+		category := *t.byID[id]
+		category.Path = t.path(id)
+		categories = append(categories, category)
+	}
+	return categories
+}
+
+// nested 返回嵌套的分类树，includeArchived 为 false 时不含已归档分类及其子分类
+func (t *categoryTree) nested(includeArchived bool) []models.Category {
+	var build func(parent uint) []models.Category
+	build = func(parent uint) []models.Category {
+		nodes := make([]models.Category, 0, len(t.children[parent]))
+		for _, id := range t.children[parent] {
+			if !includeArchived && t.byID[id].Archived {
+				continue
+			}
+			node := *t.byID[id]
+			node.Path = t.path(id)
+			node.Children = build(id)
+			nodes = append(nodes, node)
+		}
+		return nodes
+	}
+	return build(0)
+}
+
+// checkCategoryParent 校验把分类移动到 parentID 下是否合法（categoryID 为 0 表示新建）
+func (t *categoryTree) checkCategoryParent(categoryID, parentID uint) error {
+	if parentID == 0 {
+		if categoryID != 0 && t.height(categoryID)+1 > maxCategoryDepth {
+			return errors.New("分类层级过深")
+		}
+		return nil
+	}
+	parent, ok := t.byID[parentID]
+	if !ok {
+		return errors.New("上级分类不存在")
+	}
+	if categoryID == 0 && parent.Archived {
+		return errors.New("上级分类已归档")
+	}
+	if categoryID != 0 && (parentID == categoryID || t.isDescendant(parentID, categoryID)) {
+		return errors.New("不能把分类移动到自身或其子分类下")
+	}
+
+	levels := t.depth(parentID) + 2
+	if categoryID != 0 {
+		levels += t.height(categoryID)
+	}
+	if levels > maxCategoryDepth {
+		return errors.New("分类层级过深")
+	}
+	return nil
+}
+
+// categoryLevel 解析统计的汇总层级参数，未指定或无效时返回 -1
+func categoryLevel(raw string) int {
+	level, err := strconv.Atoi(raw)
+	if err != nil || level < 0 {
+		return -1
+	}
+	return level
+}
+
+// aggregateCategoryStats 汇总各分类时长
+// level < 0 时返回每个分类自身的时长，并在 total_* 中包含子分类的汇总；
+// level >= 0 时把时长汇总到该层级的祖先分类（比该层级浅的分类按自身统计）
+func aggregateCategoryStats(userID uint, level int) []CategoryStats {
+	type result struct {
+		CategoryID uint
+		Duration   int
+		Count      int64
+	}
+
+	var results []result
+	database.DB.Model(&models.Pomodoro{}).
+		Select("category_id, SUM(duration) as duration, COUNT(*) as count").
+		Where("user_id = ? AND completed = ?", userID, true).
+		Group("category_id").
+		Scan(&results)
+
+	tree := loadCategoryTree(userID)
+	statsMap := make(map[uint]*CategoryStats)
+	get := func(id uint) *CategoryStats {
+		if s, ok := statsMap[id]; ok {
+			return s
+		}
+		category := tree.byID[id]
+		s := &CategoryStats{
+			ID:       category.ID,
+			Name:     category.Name,
+			Color:    category.Color,
+			ParentID: category.ParentID,
+			Depth:    tree.depth(id),
+			Path:     tree.path(id),
+		}
+		statsMap[id] = s
+		return s
+	}
+
+	for _, r := range results {
+		if _, ok := tree.byID[r.CategoryID]; !ok {
+			continue
+		}
+
+		if level >= 0 {
+			s := get(tree.ancestorAt(r.CategoryID, level))
+			s.Duration += r.Duration
+			s.Count += r.Count
+			s.TotalDuration += r.Duration
+			s.TotalCount += r.Count
+			continue
+		}
+
+		s := get(r.CategoryID)
+		s.Duration += r.Duration
+		s.Count += r.Count
+		for _, id := range tree.ancestors(r.CategoryID) {
+			a := get(id)
+			a.TotalDuration += r.Duration
+			a.TotalCount += r.Count
+		}
+	}
+
+	stats := make([]CategoryStats, 0, len(statsMap))
+	for _, s := range statsMap {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Path < stats[j].Path
+	})
+	return stats
+}
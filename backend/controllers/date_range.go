@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxDateRangeDays 日期范围查询最多跨越的天数
+const maxDateRangeDays = 366
+
+// parseDateRange 解析 from、to 查询参数（YYYY-MM-DD，本地时间，含两端），
+// 未指定 to 时为今天，未指定 from 时为 to 之前 defaultDays 天
+// 返回的 end 为 to 次日零点，便于使用 [start, end) 查询
+func parseDateRange(c *gin.Context, defaultDays int) (start, end time.Time, ok bool) {
+	now := time.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if raw := c.Query("to"); raw != "" {
+		t, err := time.ParseInLocation("2006-01-02", raw, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "日期格式应为 YYYY-MM-DD"})
+			return start, end, false
+		}
+		to = t
+	}
+
+	from := to.AddDate(0, 0, -(defaultDays - 1))
+	if raw := c.Query("from"); raw != "" {
+		t, err := time.ParseInLocation("2006-01-02", raw, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "日期格式应为 YYYY-MM-DD"})
+			return start, end, false
+		}
+		from = t
+	}
+
+	if from.After(to) || to.Sub(from) > maxDateRangeDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "日期范围无效（最多一年）"})
+		return start, end, false
+	}
+	return from, to.AddDate(0, 0, 1), true
+}
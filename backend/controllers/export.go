@@ -0,0 +1,304 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// exportFlushRows 每写入多少行刷新一次响应
+const exportFlushRows = 200
+
+// exportPomodoro 导出的番茄钟记录
+type exportPomodoro struct {
+	ID              uint       `json:"id"`
+	StartedAt       time.Time  `json:"started_at"`
+	CompletedAt     *time.Time `json:"completed_at"`
+	Duration        int        `json:"duration"`
+	PlannedDuration int        `json:"planned_duration"`
+	Completed       bool       `json:"completed"`
+	CategoryID      uint       `json:"category_id"`
+	Category        string     `json:"category"`
+	CategoryColor   string     `json:"category_color"`
+	Task            *string    `json:"task"`
+	Tags            *string    `json:"tags"` // 分号分隔
+	Note            string     `json:"note"`
+	Source          string     `json:"source"`
+	FocusScore      *int       `json:"focus_score"`
+	Energy          *int       `json:"energy"`
+	Mood            *int       `json:"mood"`
+}
+
+// exportWordRecord 导出的单词记录
+type exportWordRecord struct {
+	Date      string `json:"date"`
+	WordCount int    `json:"word_count"`
+	Note      string `json:"note"`
+}
+
+// dateOnly 去掉读出日期中的时间部分
+func dateOnly(s string) string {
+	return strings.SplitN(s, "T", 2)[0]
+}
+
+// eachRow 逐行读取查询结果，不把全部数据加载到内存
+func eachRow[T any](query *gorm.DB, fn func(*T) error) error {
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row T
+		if err := database.DB.ScanRows(rows, &row); err != nil {
+			return err
+		}
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// exportPomodoroQuery 带分类、任务和标签名称的番茄钟查询
+func exportPomodoroQuery(userID uint, start, end *time.Time) *gorm.DB {
+	query := database.DB.Table("pomodoros").
+		Select("pomodoros.id, pomodoros.started_at, pomodoros.completed_at, pomodoros.duration, pomodoros.planned_duration, "+
+			"pomodoros.completed, pomodoros.category_id, categories.name AS category, categories.color AS category_color, "+
+			"tasks.title AS task, "+
+			"(SELECT GROUP_CONCAT(tags.name, ';') FROM pomodoro_tags JOIN tags ON tags.id = pomodoro_tags.tag_id "+
+			"WHERE pomodoro_tags.pomodoro_id = pomodoros.id) AS tags, "+
+			"pomodoros.note, pomodoros.source, pomodoros.focus_score, pomodoros.energy, pomodoros.mood").
+		Joins("LEFT JOIN categories ON categories.id = pomodoros.category_id").
+		Joins("LEFT JOIN tasks ON tasks.id = pomodoros.task_id AND tasks.deleted_at IS NULL").
+		Where("pomodoros.user_id = ? AND pomodoros.deleted_at IS NULL", userID)
+	if start != nil {
+		query = query.Where("pomodoros.started_at >= ? AND pomodoros.started_at < ?", *start, *end)
+	}
+	return query.Order("pomodoros.started_at ASC, pomodoros.id ASC")
+}
+
+// exportWordQuery 单词记录查询
+func exportWordQuery(userID uint, start, end *time.Time) *gorm.DB {
+	query := database.DB.Model(&models.WordRecord{}).
+		Select("date, word_count, note").
+		Where("user_id = ?", userID)
+	if start != nil {
+		query = query.Where("date >= ? AND date < ?", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	}
+	return query.Order("date ASC")
+}
+
+// ExportData 导出用户数据
+// format=json 导出全部数据（设置、分类、番茄钟、单词记录），用于备份；
+// format=csv 按 type（pomodoros、categories、words）导出单张表，便于在表格软件中使用
+// from、to 只筛选番茄钟和单词记录
+func ExportData(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format 只能是 json 或 csv"})
+		return
+	}
+	kind := c.DefaultQuery("type", "pomodoros")
+	if format == "csv" && kind != "pomodoros" && kind != "categories" && kind != "words" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type 只能是 pomodoros、categories 或 words"})
+		return
+	}
+
+	var start, end *time.Time
+	if c.Query("from") != "" || c.Query("to") != "" {
+		from, to, ok := parseDateRange(c, maxDateRangeDays)
+		if !ok {
+			return
+		}
+		start, end = &from, &to
+	}
+
+	name := "pomodoro-export-" + time.Now().Format("20060102")
+	var err error
+	if format == "json" {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, name))
+		c.Status(http.StatusOK)
+		err = exportJSON(c, userID, start, end)
+	} else {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.csv"`, name, kind))
+		c.Status(http.StatusOK)
+		err = exportCSV(c, userID, kind, start, end)
+	}
+	// 响应已开始写入，出错时只能记录日志
+	if err != nil {
+		log.Printf("导出数据失败: user_id=%d, format=%s, err=%v", userID, format, err)
+	}
+}
+
+// exportJSON 以 JSON 对象流式写出全部数据
+func exportJSON(c *gin.Context, userID uint, start, end *time.Time) error {
+	w := c.Writer
+	enc := json.NewEncoder(w)
+
+	var user models.User
+	database.DB.First(&user, userID)
+	var setting models.Setting
+	database.DB.Where("user_id = ?", userID).First(&setting)
+
+	header, err := json.Marshal(gin.H{
+		"exported_at": time.Now(),
+		"username":    user.Username,
+		"email":       user.Email,
+	})
+	if err != nil {
+		return err
+	}
+	// 去掉结尾的 }，后面继续追加字段
+	w.Write(header[:len(header)-1])
+
+	w.WriteString(`,"settings":`)
+	enc.Encode(setting)
+	w.WriteString(`,"categories":`)
+	enc.Encode(loadCategoryTree(userID).flat(true))
+
+	writeArray := func(key string, each func(func(v interface{}) error) error) error {
+		w.WriteString(`,"` + key + `":[`)
+		n := 0
+		err := each(func(v interface{}) error {
+			if n > 0 {
+				w.WriteString(",")
+			}
+			n++
+			if n%exportFlushRows == 0 {
+				w.Flush()
+			}
+			return enc.Encode(v)
+		})
+		w.WriteString("]")
+		return err
+	}
+
+	if err := writeArray("pomodoros", func(write func(v interface{}) error) error {
+		return eachRow(exportPomodoroQuery(userID, start, end), func(p *exportPomodoro) error {
+			return write(p)
+		})
+	}); err != nil {
+		return err
+	}
+	if err := writeArray("word_records", func(write func(v interface{}) error) error {
+		return eachRow(exportWordQuery(userID, start, end), func(r *exportWordRecord) error {
+			r.Date = dateOnly(r.Date)
+			return write(r)
+		})
+	}); err != nil {
+		return err
+	}
+
+	w.WriteString("}\n")
+	return nil
+}
+
+// csvFormulaPrefixes 表格软件会把以这些字符开头的单元格当作公式
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// csvSafe 用户输入的单元格以公式字符开头时加上单引号，防止 CSV 公式注入
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune(csvFormulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// csvUnsafe 还原 csvSafe 加上的单引号，导入本应用导出的 CSV 时使用
+func csvUnsafe(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune(csvFormulaPrefixes, rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+// exportCSV 流式写出单张表
+func exportCSV(c *gin.Context, userID uint, kind string, start, end *time.Time) error {
+	// UTF-8 BOM，避免 Excel 打开中文乱码
+	c.Writer.WriteString("\ufeff")
+	w := csv.NewWriter(c.Writer)
+	n := 0
+	write := func(record []string) error {
+		if err := w.Write(record); err != nil {
+			return err
+		}
+		if n++; n%exportFlushRows == 0 {
+			w.Flush()
+			c.Writer.Flush()
+		}
+		return nil
+	}
+	formatTime := func(t time.Time) string {
+		return t.Local().Format("2006-01-02 15:04:05")
+	}
+	optional := func(v *int) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.Itoa(*v)
+	}
+
+	var err error
+	switch kind {
+	case "pomodoros":
+		write([]string{"id", "started_at", "completed_at", "duration", "planned_duration", "completed",
+			"category", "task", "tags", "note", "source", "focus_score", "energy", "mood"})
+		err = eachRow(exportPomodoroQuery(userID, start, end), func(p *exportPomodoro) error {
+			completedAt, task, tags := "", "", ""
+			if p.CompletedAt != nil {
+				completedAt = formatTime(*p.CompletedAt)
+			}
+			if p.Task != nil {
+				task = *p.Task
+			}
+			if p.Tags != nil {
+				tags = *p.Tags
+			}
+			return write([]string{
+				strconv.FormatUint(uint64(p.ID), 10), formatTime(p.StartedAt), completedAt,
+				strconv.Itoa(p.Duration), strconv.Itoa(p.PlannedDuration), strconv.FormatBool(p.Completed),
+				csvSafe(p.Category), csvSafe(task), csvSafe(tags), csvSafe(p.Note), p.Source,
+				optional(p.FocusScore), optional(p.Energy), optional(p.Mood),
+			})
+		})
+	case "categories":
+		write([]string{"id", "name", "path", "color", "icon", "parent_id", "archived"})
+		for _, category := range loadCategoryTree(userID).flat(true) {
+			parentID := ""
+			if category.ParentID != nil {
+				parentID = strconv.FormatUint(uint64(*category.ParentID), 10)
+			}
+			write([]string{
+				strconv.FormatUint(uint64(category.ID), 10), csvSafe(category.Name), csvSafe(category.Path),
+				csvSafe(category.Color), csvSafe(category.Icon), parentID, strconv.FormatBool(category.Archived),
+			})
+		}
+	case "words":
+		write([]string{"date", "word_count", "note"})
+		err = eachRow(exportWordQuery(userID, start, end), func(r *exportWordRecord) error {
+			return write([]string{dateOnly(r.Date), strconv.Itoa(r.WordCount), csvSafe(r.Note)})
+		})
+	}
+
+	w.Flush()
+	if err != nil {
+		return err
+	}
+	return w.Error()
+}
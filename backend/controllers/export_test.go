@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestExportCSVEscapesFormulas(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+
+	r := gin.New()
+	r.Use(withUser(user.ID))
+	r.GET("/api/export", ExportData)
+
+	category := models.Category{UserID: user.ID, Name: "=cmd|' /C calc'!A0", Color: "#ff0000"}
+	database.DB.Create(&category)
+	task := models.Task{UserID: user.ID, Title: "@SUM(1+1)", EstimatedPomodoros: 1}
+	database.DB.Create(&task)
+	deleted := models.Task{UserID: user.ID, Title: "已删除的任务", EstimatedPomodoros: 1}
+	database.DB.Create(&deleted)
+	database.DB.Delete(&deleted)
+
+	start := time.Now().Add(-time.Hour)
+	end := start.Add(25 * time.Minute)
+	database.DB.Create(&models.Pomodoro{
+		UserID: user.ID, CategoryID: category.ID, TaskID: &task.ID, Duration: 1500, PlannedDuration: 1500,
+		Completed: true, StartedAt: start, CompletedAt: &end, Note: "=HYPERLINK(\"http://evil\")",
+	})
+	start2 := end.Add(5 * time.Minute)
+	end2 := start2.Add(25 * time.Minute)
+	database.DB.Create(&models.Pomodoro{
+		UserID: user.ID, CategoryID: category.ID, TaskID: &deleted.ID, Duration: 1500, PlannedDuration: 1500,
+		Completed: true, StartedAt: start2, CompletedAt: &end2, Note: "-1 普通备注",
+	})
+
+	w := performRequest(r, http.MethodGet, "/api/export?format=csv&type=pomodoros", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("导出状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(w.Body.String(), "\ufeff"))).ReadAll()
+	if err != nil {
+		t.Fatalf("解析 CSV 失败: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("导出 %d 行, 应为表头加 2 行", len(records))
+	}
+	col := map[string]int{}
+	for i, name := range records[0] {
+		col[name] = i
+	}
+
+	first, second := records[1], records[2]
+	checks := []struct {
+		name, got, want string
+	}{
+		{"category", first[col["category"]], "'" + category.Name},
+		{"task", first[col["task"]], "'@SUM(1+1)"},
+		{"note", first[col["note"]], "'=HYPERLINK(\"http://evil\")"},
+		{"note", second[col["note"]], "'-1 普通备注"},
+		{"已删除的任务", second[col["task"]], ""},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %q, want %q", c.name, c.got, c.want)
+		}
+	}
+
+	// 导入时去掉导出加上的单引号
+	for _, cell := range []string{first[col["note"]], second[col["note"]], "'普通文本", "'"} {
+		if got := csvSafe(csvUnsafe(cell)); got != cell {
+			t.Errorf("csvSafe(csvUnsafe(%q)) = %q", cell, got)
+		}
+	}
+	if got := csvUnsafe(first[col["note"]]); got != "=HYPERLINK(\"http://evil\")" {
+		t.Errorf("csvUnsafe 未还原备注: %q", got)
+	}
+}
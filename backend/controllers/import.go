@@ -0,0 +1,605 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"pomodoro-api/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 单次导入最多的行数
+var maxImportRows = utils.GetEnvInt("MAX_IMPORT_ROWS", 5000)
+
+// importDefaultCategory 没有分类列或分类为空时使用的分类
+const importDefaultCategory = "导入"
+
+// 导入行的状态
+const (
+	importRowOK        = "ok"
+	importRowDuplicate = "duplicate" // 与已有记录或文件中前面的行重复
+	importRowOverlap   = "overlap"   // 与已有记录时间重叠
+	importRowInvalid   = "invalid"
+)
+
+// importPreset 常见应用导出格式的列映射
+// 字段：started_at、start_date、start_time、ended_at、end_date、end_time、duration、
+// category、note、tags、completed；开始时间可以是一列，也可以拆成日期和时间两列
+type importPreset struct {
+	Mapping      map[string]string
+	TagSeparator string
+}
+
+var importPresets = map[string]importPreset{
+	// 本应用导出的 CSV
+	"pomodoro": {
+		Mapping: map[string]string{
+			"started_at": "started_at", "ended_at": "completed_at", "duration": "duration",
+			"category": "category", "note": "note", "tags": "tags", "completed": "completed",
+		},
+		TagSeparator: ";",
+	},
+	// Toggl Track 详细报表
+	"toggl": {
+		Mapping: map[string]string{
+			"start_date": "Start date", "start_time": "Start time", "end_date": "End date", "end_time": "End time",
+			"duration": "Duration", "category": "Project", "note": "Description", "tags": "Tags",
+		},
+		TagSeparator: ",",
+	},
+	// Clockify 详细报表
+	"clockify": {
+		Mapping: map[string]string{
+			"start_date": "Start Date", "start_time": "Start Time", "end_date": "End Date", "end_time": "End Time",
+			"duration": "Duration (h)", "category": "Project", "note": "Description", "tags": "Tags",
+		},
+		TagSeparator: ",",
+	},
+	// 通用格式，可通过 mapping 参数覆盖列名
+	"generic": {
+		Mapping: map[string]string{
+			"started_at": "start", "ended_at": "end", "duration": "duration",
+			"category": "category", "note": "note", "tags": "tags",
+		},
+		TagSeparator: ";",
+	},
+}
+
+// importTimeLayouts 支持的日期时间格式
+var importTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"01/02/2006 15:04:05",
+	"01/02/2006 15:04",
+	"01/02/2006 03:04:05 PM",
+	"01/02/2006 03:04 PM",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+}
+
+// ImportRow 导入文件中的一行及其校验结果
+type ImportRow struct {
+	Line      int       `json:"line"` // 文件中的行号（含表头）
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	Duration  int       `json:"duration"`
+	Category  string    `json:"category"`
+	Tags      []string  `json:"tags,omitempty"`
+	Note      string    `json:"note,omitempty"`
+}
+
+// ImportReport 导入预览或结果
+type ImportReport struct {
+	Preset        string      `json:"preset"`
+	Committed     bool        `json:"committed"`
+	Total         int         `json:"total"`
+	Imported      int         `json:"imported"` // 预览时为可导入的行数
+	Skipped       int         `json:"skipped"`
+	NewCategories []string    `json:"new_categories"`
+	Rows          []ImportRow `json:"rows"` // 预览时为全部行，导入后只包含跳过的行
+}
+
+// parseImportTime 按支持的格式解析时间
+func parseImportTime(raw string, loc *time.Location) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	for _, layout := range importTimeLayouts {
+		if t, err := time.ParseInLocation(layout, raw, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法识别的时间: %s", raw)
+}
+
+// parseImportDuration 解析时长：h:mm:ss、h:mm、25m 等 Go 时长格式或秒数
+func parseImportDuration(raw string) (int, error) {
+	raw = strings.TrimSpace(raw)
+	if parts := strings.Split(raw, ":"); len(parts) == 2 || len(parts) == 3 {
+		seconds := 0
+		for _, p := range parts {
+			n, err := strconv.Atoi(p)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("无法识别的时长: %s", raw)
+			}
+			seconds = seconds*60 + n
+		}
+		if len(parts) == 2 {
+			seconds *= 60
+		}
+		return seconds, nil
+	}
+	if n, err := strconv.Atoi(raw); err == nil && n >= 0 {
+		return n, nil
+	}
+	if d, err := time.ParseDuration(raw); err == nil && d >= 0 {
+		return int(d.Seconds()), nil
+	}
+	return 0, fmt.Errorf("无法识别的时长: %s", raw)
+}
+
+// importRequest 解析后的导入请求
+type importRequest struct {
+	preset          string
+	mapping         map[string]string
+	tagSeparator    string
+	loc             *time.Location
+	defaultCategory string
+	file            io.ReadCloser
+}
+
+// parseImportRequest 读取上传的文件和导入选项
+func parseImportRequest(c *gin.Context) (*importRequest, bool) {
+	req := &importRequest{preset: c.DefaultPostForm("preset", "generic"), loc: time.Local}
+
+	preset, ok := importPresets[req.preset]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的导入格式"})
+		return nil, false
+	}
+	req.mapping = make(map[string]string, len(preset.Mapping))
+	for field, column := range preset.Mapping {
+		req.mapping[field] = column
+	}
+	req.tagSeparator = c.DefaultPostForm("tag_separator", preset.TagSeparator)
+
+	// mapping 为 JSON 对象，覆盖预设中的列名，列名为空表示不使用该字段
+	if raw := c.PostForm("mapping"); raw != "" {
+		var mapping map[string]string
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mapping 格式错误"})
+			return nil, false
+		}
+		for field, column := range mapping {
+			if column == "" {
+				delete(req.mapping, field)
+			} else {
+				req.mapping[field] = column
+			}
+		}
+	}
+
+	if tz := c.PostForm("timezone"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的时区"})
+			return nil, false
+		}
+		req.loc = loc
+	}
+
+	req.defaultCategory = strings.TrimSpace(c.DefaultPostForm("default_category", importDefaultCategory))
+	if req.defaultCategory == "" || utf8.RuneCountInString(req.defaultCategory) > 30 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "默认分类名称无效"})
+		return nil, false
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请上传 CSV 文件"})
+		return nil, false
+	}
+	if req.file, err = file.Open(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取文件失败"})
+		return nil, false
+	}
+	return req, true
+}
+
+// readImportRows 读取并校验 CSV 中的每一行，不访问数据库
+func readImportRows(req *importRequest) ([]ImportRow, error) {
+	reader := csv.NewReader(req.file)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("无法读取表头")
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	index := make(map[string]int)
+	for field, column := range req.mapping {
+		if i, ok := columns[strings.ToLower(column)]; ok {
+			index[field] = i
+		}
+	}
+	_, hasStart := index["started_at"]
+	_, hasStartDate := index["start_date"]
+	_, hasEnd := index["ended_at"]
+	_, hasEndDate := index["end_date"]
+	_, hasDuration := index["duration"]
+	if !hasStart && !hasStartDate {
+		return nil, errors.New("找不到开始时间列")
+	}
+	if !hasEnd && !hasEndDate && !hasDuration {
+		return nil, errors.New("找不到结束时间或时长列")
+	}
+
+	var rows []ImportRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if len(rows) >= maxImportRows {
+			return nil, fmt.Errorf("导入行数超出限制（最多 %d）", maxImportRows)
+		}
+		row := ImportRow{Line: line, Status: importRowOK}
+		if err != nil {
+			row.Status, row.Error = importRowInvalid, "CSV 格式错误"
+			rows = append(rows, row)
+			continue
+		}
+
+		get := func(field string) string {
+			if i, ok := index[field]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if err := fillImportRow(&row, get, req); err != nil {
+			row.Status, row.Error = importRowInvalid, err.Error()
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// fillImportRow 解析一行的各个字段
+func fillImportRow(row *ImportRow, get func(string) string, req *importRequest) error {
+	if completed := strings.ToLower(get("completed")); completed == "false" || completed == "0" {
+		return errors.New("未完成的番茄钟")
+	}
+
+	joinDateTime := func(full, date, clock string) string {
+		if v := get(full); v != "" {
+			return v
+		}
+		return strings.TrimSpace(get(date) + " " + get(clock))
+	}
+
+	var err error
+	start := joinDateTime("started_at", "start_date", "start_time")
+	if start == "" {
+		return errors.New("缺少开始时间")
+	}
+	if row.StartedAt, err = parseImportTime(start, req.loc); err != nil {
+		return err
+	}
+
+	if end := joinDateTime("ended_at", "end_date", "end_time"); end != "" {
+		if row.EndedAt, err = parseImportTime(end, req.loc); err != nil {
+			return err
+		}
+	} else if raw := get("duration"); raw != "" {
+		seconds, err := parseImportDuration(raw)
+		if err != nil {
+			return err
+		}
+		row.EndedAt = row.StartedAt.Add(time.Duration(seconds) * time.Second)
+	} else {
+		return errors.New("缺少结束时间或时长")
+	}
+
+	row.StartedAt, row.EndedAt = row.StartedAt.Local(), row.EndedAt.Local()
+	if !row.EndedAt.After(row.StartedAt) {
+		return errors.New("结束时间必须晚于开始时间")
+	}
+	if row.EndedAt.After(time.Now().Add(time.Minute)) {
+		return errors.New("不能导入未来的番茄钟")
+	}
+	row.Duration = int(row.EndedAt.Sub(row.StartedAt).Seconds())
+	if row.Duration > maxPlannedDuration {
+		return fmt.Errorf("时长超出限制（最多 %d 秒）", maxPlannedDuration)
+	}
+
+	row.Category = csvUnsafe(get("category"))
+	if row.Category == "" {
+		row.Category = req.defaultCategory
+	}
+	if utf8.RuneCountInString(row.Category) > 30 {
+		return errors.New("分类名称过长")
+	}
+
+	row.Note = csvUnsafe(get("note"))
+	if utf8.RuneCountInString(row.Note) > maxNoteLength {
+		return fmt.Errorf("备注长度超出限制（最多 %d）", maxNoteLength)
+	}
+
+	if raw := csvUnsafe(get("tags")); raw != "" {
+		if row.Tags, err = normalizeTagNames(strings.Split(raw, req.tagSeparator)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importInterval 已占用的时间段
+type importInterval struct {
+	start, end time.Time
+}
+
+// checkImportConflicts 与已有的已完成番茄钟及文件中前面的行比较，标记重复和重叠的行
+// 开始时间和时长都相差不超过一分钟视为重复
+func checkImportConflicts(userID uint, rows []ImportRow) {
+	var first, last time.Time
+	for _, row := range rows {
+		if row.Status != importRowOK {
+			continue
+		}
+		if first.IsZero() || row.StartedAt.Before(first) {
+			first = row.StartedAt
+		}
+		if row.EndedAt.After(last) {
+			last = row.EndedAt
+		}
+	}
+	if first.IsZero() {
+		return
+	}
+
+	var existing []models.Pomodoro
+	database.DB.Select("started_at", "completed_at").
+		Where("user_id = ? AND completed = ? AND completed_at IS NOT NULL AND started_at < ? AND completed_at > ?", userID, true, last, first).
+		Find(&existing)
+
+	// 按开始时间排序，插入时保持有序
+	// maxSpan 为最长时间段的时长（忘记停止的番茄钟可能远超计划时长），向前查找到此为止
+	var maxSpan time.Duration
+	intervals := make([]importInterval, 0, len(existing)+len(rows))
+	for _, p := range existing {
+		intervals = append(intervals, importInterval{p.StartedAt, *p.CompletedAt})
+		maxSpan = max(maxSpan, p.CompletedAt.Sub(p.StartedAt))
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].start.Before(intervals[j].start) })
+
+	abs := func(d time.Duration) time.Duration {
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+
+	for i := range rows {
+		row := &rows[i]
+		if row.Status != importRowOK {
+			continue
+		}
+
+		// 开始时间早于 row.EndedAt 的时间段才可能重叠
+		hi := sort.Search(len(intervals), func(k int) bool { return !intervals[k].start.Before(row.EndedAt) })
+		for k := hi - 1; k >= 0 && intervals[k].start.After(row.StartedAt.Add(-maxSpan)); k-- {
+			iv := intervals[k]
+			if abs(iv.start.Sub(row.StartedAt)) <= time.Minute && abs(iv.end.Sub(iv.start)-row.EndedAt.Sub(row.StartedAt)) <= time.Minute {
+				row.Status, row.Error = importRowDuplicate, "与已有记录重复"
+				break
+			}
+			if iv.end.After(row.StartedAt) {
+				row.Status, row.Error = importRowOverlap, "与已有记录时间重叠"
+				break
+			}
+		}
+		if row.Status != importRowOK {
+			continue
+		}
+
+		pos := sort.Search(len(intervals), func(k int) bool { return intervals[k].start.After(row.StartedAt) })
+		intervals = append(intervals, importInterval{})
+		copy(intervals[pos+1:], intervals[pos:])
+		intervals[pos] = importInterval{row.StartedAt, row.EndedAt}
+		maxSpan = max(maxSpan, row.EndedAt.Sub(row.StartedAt))
+	}
+}
+
+// importCategories 按名称（不区分大小写）匹配已有分类，返回名称到 ID 的映射和需要新建的分类
+// 同名分类优先使用未归档的
+func importCategories(userID uint, rows []ImportRow) (map[string]uint, []string) {
+	var categories []models.Category
+	database.DB.Where("user_id = ?", userID).Order("archived ASC, id ASC").Find(&categories)
+
+	ids := make(map[string]uint)
+	for _, category := range categories {
+		key := strings.ToLower(category.Name)
+		if _, ok := ids[key]; !ok {
+			ids[key] = category.ID
+		}
+	}
+
+	var missing []string
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		key := strings.ToLower(row.Category)
+		if row.Status != importRowOK || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if _, ok := ids[key]; !ok {
+			missing = append(missing, row.Category)
+		}
+	}
+	return ids, missing
+}
+
+// importNewTagCount 返回导入后需要新建的标签数
+func importNewTagCount(userID uint, rows []ImportRow) int {
+	names := make(map[string]bool)
+	for _, row := range rows {
+		if row.Status != importRowOK {
+			continue
+		}
+		for _, name := range row.Tags {
+			names[strings.ToLower(name)] = true
+		}
+	}
+	if len(names) == 0 {
+		return 0
+	}
+
+	var existing []string
+	database.DB.Model(&models.Tag{}).Where("user_id = ?", userID).Pluck("LOWER(name)", &existing)
+	for _, name := range existing {
+		delete(names, name)
+	}
+	return len(names)
+}
+
+// PreviewImport 预览导入结果，不写入数据
+func PreviewImport(c *gin.Context) {
+	runImport(c, false)
+}
+
+// ImportPomodoros 从 CSV 导入番茄钟，全部在一个事务中完成
+func ImportPomodoros(c *gin.Context) {
+	runImport(c, true)
+}
+
+// runImport 解析上传的 CSV，commit 为 true 时写入可导入的行
+func runImport(c *gin.Context, commit bool) {
+	userID := c.GetUint("user_id")
+
+	req, ok := parseImportRequest(c)
+	if !ok {
+		return
+	}
+	defer req.file.Close()
+
+	rows, err := readImportRows(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	checkImportConflicts(userID, rows)
+	categoryIDs, missing := importCategories(userID, rows)
+
+	report := ImportReport{Preset: req.preset, Committed: commit, Total: len(rows), NewCategories: missing, Rows: []ImportRow{}}
+	for _, row := range rows {
+		if row.Status == importRowOK {
+			report.Imported++
+		} else {
+			report.Skipped++
+		}
+		if !commit || row.Status != importRowOK {
+			report.Rows = append(report.Rows, row)
+		}
+	}
+	if report.NewCategories == nil {
+		report.NewCategories = []string{}
+	}
+
+	if len(missing) > 0 {
+		var count int64
+		database.DB.Model(&models.Category{}).Where("user_id = ? AND archived = ?", userID, false).Count(&count)
+		if total := int(count) + len(missing); total > maxCategoriesPerUser {
+			rejectLimit(c, "分类数量", total, maxCategoriesPerUser)
+			return
+		}
+	}
+	if newTags := importNewTagCount(userID, rows); newTags > 0 {
+		var count int64
+		database.DB.Model(&models.Tag{}).Where("user_id = ?", userID).Count(&count)
+		if total := int(count) + newTags; total > maxTagsPerUser {
+			rejectLimit(c, "标签数量", total, maxTagsPerUser)
+			return
+		}
+	}
+
+	if !commit || report.Imported == 0 {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, name := range missing {
+			category := models.Category{UserID: userID, Name: name, Color: "#999999"}
+			if err := tx.Create(&category).Error; err != nil {
+				return err
+			}
+			categoryIDs[strings.ToLower(name)] = category.ID
+		}
+
+		for _, row := range rows {
+			if row.Status != importRowOK {
+				continue
+			}
+			tags, err := resolveTagsWith(tx, userID, row.Tags)
+			if err != nil {
+				return err
+			}
+			end := row.EndedAt
+			pomodoro := models.Pomodoro{
+				UserID:          userID,
+				CategoryID:      categoryIDs[strings.ToLower(row.Category)],
+				Duration:        row.Duration,
+				PlannedDuration: row.Duration,
+				Completed:       true,
+				StartedAt:       row.StartedAt,
+				CompletedAt:     &end,
+				Note:            row.Note,
+				Source:          models.PomodoroSourceImport,
+				Tags:            tags,
+			}
+			if err := tx.Create(&pomodoro).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("导入番茄钟失败: user_id=%d, err=%v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "导入失败"})
+		return
+	}
+
+	recordChange(c, "pomodoro.import", "pomodoro", 0, userID, nil, gin.H{
+		"preset":         report.Preset,
+		"imported":       report.Imported,
+		"skipped":        report.Skipped,
+		"new_categories": report.NewCategories,
+	})
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// importTestRouter 注册导入接口
+func importTestRouter(userID uint) *gin.Engine {
+	r := gin.New()
+	r.Use(withUser(userID))
+	r.POST("/api/import/pomodoros/preview", PreviewImport)
+	r.POST("/api/import/pomodoros", ImportPomodoros)
+	return r
+}
+
+// uploadCSV 以表单上传 CSV，fields 为其他表单字段
+func uploadCSV(r http.Handler, path, content string, fields map[string]string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	part, _ := form.CreateFormFile("file", "import.csv")
+	part.Write([]byte(content))
+	form.Close()
+	return performRequest(r, http.MethodPost, path, &body, "Content-Type", form.FormDataContentType())
+}
+
+// importTime 本地时区的时间
+func importTime(hour, minute int) time.Time {
+	return time.Date(2026, 1, 5, hour, minute, 0, 0, time.Local)
+}
+
+func TestImportPresets(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+	r := importTestRouter(user.ID)
+
+	tests := []struct {
+		preset string
+		csv    string
+	}{
+		{"pomodoro", "\ufeffstarted_at,completed_at,duration,category,note,tags,completed\n" +
+			"2026-01-05T09:00:00+08:00,2026-01-05T09:25:00+08:00,1500,学习,'=1+1,英语;阅读,true\n" +
+			"2026-01-05T10:00:00+08:00,2026-01-05T10:25:00+08:00,1500,学习,,,false\n"},
+		{"toggl", "User,Project,Description,Start date,Start time,End date,End time,Duration,Tags\n" +
+			"alice,学习,=1+1,2026-01-05,09:00:00,2026-01-05,09:25:00,00:25:00,\"英语, 阅读\"\n"},
+		{"clockify", "Project,Description,Tags,Start Date,Start Time,End Date,End Time,Duration (h)\n" +
+			"学习,=1+1,\"英语, 阅读\",01/05/2026,09:00 AM,01/05/2026,09:25 AM,0:25:00\n"},
+		{"generic", "start,duration,category,note,tags\n" +
+			"2026-01-05 09:00,25m,学习,=1+1,英语;阅读\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.preset, func(t *testing.T) {
+			fields := map[string]string{"preset": tt.preset, "timezone": "Asia/Shanghai"}
+			w := uploadCSV(r, "/api/import/pomodoros/preview", tt.csv, fields)
+			if w.Code != http.StatusOK {
+				t.Fatalf("状态码 = %d, body=%s", w.Code, w.Body.String())
+			}
+			var report ImportReport
+			decodeJSON(t, w, &report)
+			if report.Preset != tt.preset || report.Committed {
+				t.Errorf("preset = %s, committed = %v", report.Preset, report.Committed)
+			}
+
+			row := report.Rows[0]
+			if row.Status != importRowOK {
+				t.Fatalf("第一行状态 = %s (%s)", row.Status, row.Error)
+			}
+			wantStart := time.Date(2026, 1, 5, 1, 0, 0, 0, time.UTC)
+			if !row.StartedAt.Equal(wantStart) || row.Duration != 1500 {
+				t.Errorf("开始时间 = %s, 时长 = %d", row.StartedAt, row.Duration)
+			}
+			if row.Category != "学习" {
+				t.Errorf("分类 = %q", row.Category)
+			}
+			if strings.Join(row.Tags, "|") != "英语|阅读" {
+				t.Errorf("标签 = %v", row.Tags)
+			}
+			if tt.preset != "pomodoro" && row.Note != "=1+1" {
+				t.Errorf("备注 = %q", row.Note)
+			}
+			// 本应用导出时加上的单引号在导入时去掉
+			if tt.preset == "pomodoro" {
+				if row.Note != "=1+1" {
+					t.Errorf("备注 = %q, want =1+1", row.Note)
+				}
+				if len(report.Rows) != 2 || report.Rows[1].Status != importRowInvalid {
+					t.Errorf("未完成的番茄钟应标记为无效: %+v", report.Rows)
+				}
+			}
+		})
+	}
+
+	if w := uploadCSV(r, "/api/import/pomodoros/preview", "a,b\n", map[string]string{"preset": "unknown"}); w.Code != http.StatusBadRequest {
+		t.Errorf("未知预设状态码 = %d, want 400", w.Code)
+	}
+	if w := uploadCSV(r, "/api/import/pomodoros/preview", "a,b\n1,2\n", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("找不到开始时间列时状态码 = %d, want 400", w.Code)
+	}
+	// mapping 覆盖预设中的列名
+	fields := map[string]string{"mapping": `{"started_at":"开始","duration":"分钟","category":""}`}
+	w := uploadCSV(r, "/api/import/pomodoros/preview", "开始,分钟,category\n2026-01-05 09:00,25m,工作\n", fields)
+	var report ImportReport
+	decodeJSON(t, w, &report)
+	if report.Imported != 1 || report.Rows[0].Category != importDefaultCategory {
+		t.Errorf("自定义列名导入结果 = %+v", report)
+	}
+}
+
+func TestImportMarksDuplicatesAndOverlaps(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+	r := importTestRouter(user.ID)
+
+	var category models.Category
+	database.DB.Where("user_id = ?", user.ID).First(&category)
+	addPomodoro := func(start, end time.Time, completed bool) {
+		p := models.Pomodoro{UserID: user.ID, CategoryID: category.ID, Duration: int(end.Sub(start).Seconds()),
+			PlannedDuration: 1500, Completed: completed, StartedAt: start, CompletedAt: &end}
+		database.DB.Create(&p)
+	}
+	addPomodoro(importTime(9, 0), importTime(9, 25), true)
+	// 已放弃的番茄钟不参与比较
+	addPomodoro(importTime(11, 0), importTime(11, 25), false)
+	// 忘记停止的番茄钟，时长远超计划时长上限
+	addPomodoro(importTime(13, 0), importTime(23, 0), true)
+
+	csv := "start,end\n" +
+		"2026-01-05 09:00,2026-01-05 09:25\n" + // 与已有记录重复
+		"2026-01-05 09:00:40,2026-01-05 09:25:20\n" + // 相差不超过一分钟也算重复
+		"2026-01-05 09:20,2026-01-05 09:45\n" + // 与已有记录重叠
+		"2026-01-05 10:00,2026-01-05 10:25\n" +
+		"2026-01-05 10:00,2026-01-05 10:25\n" + // 与文件中前面的行重复
+		"2026-01-05 10:10,2026-01-05 10:35\n" + // 与文件中前面的行重叠
+		"2026-01-05 11:00,2026-01-05 11:25\n" +
+		"2026-01-05 21:00,2026-01-05 21:25\n" + // 与忘记停止的番茄钟重叠
+		"2026-01-05 23:00,2026-01-05 23:25\n" + // 紧接在已有记录之后
+		"2026-01-05 08:00,2026-01-05 07:00\n" // 结束时间早于开始时间
+
+	w := uploadCSV(r, "/api/import/pomodoros/preview", csv, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+	var report ImportReport
+	decodeJSON(t, w, &report)
+
+	want := []string{
+		importRowDuplicate, importRowDuplicate, importRowOverlap,
+		importRowOK, importRowDuplicate, importRowOverlap,
+		importRowOK, importRowOverlap, importRowOK, importRowInvalid,
+	}
+	if len(report.Rows) != len(want) {
+		t.Fatalf("返回 %d 行, want %d", len(report.Rows), len(want))
+	}
+	for i, row := range report.Rows {
+		if row.Status != want[i] {
+			t.Errorf("第 %d 行状态 = %s (%s), want %s", row.Line, row.Status, row.Error, want[i])
+		}
+	}
+	if report.Total != 10 || report.Imported != 3 || report.Skipped != 7 {
+		t.Errorf("total = %d, imported = %d, skipped = %d", report.Total, report.Imported, report.Skipped)
+	}
+}
+
+func TestImportCommitsInTransaction(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+	r := importTestRouter(user.ID)
+
+	csv := "start,end,category,tags\n" +
+		"2026-01-05 09:00,2026-01-05 09:25,学习,英语\n" +
+		"2026-01-05 10:00,2026-01-05 10:25,新分类,英语;新标签\n" +
+		"2026-01-05 11:00,2026-01-05 11:25,新分类,\n"
+
+	count := func(model interface{}) int64 {
+		var n int64
+		database.DB.Model(model).Where("user_id = ?", user.ID).Count(&n)
+		return n
+	}
+	categoriesBefore := count(&models.Category{})
+
+	// 写入第三个番茄钟时出错，之前写入的分类、标签和番茄钟全部回滚
+	created := 0
+	failCreate := func(db *gorm.DB) {
+		if db.Statement.Table == "pomodoros" {
+			if created++; created == 3 {
+				db.AddError(errors.New("disk I/O error"))
+			}
+		}
+	}
+	if err := database.DB.Callback().Create().Before("gorm:create").Register("test:fail_pomodoro", failCreate); err != nil {
+		t.Fatal(err)
+	}
+	w := uploadCSV(r, "/api/import/pomodoros", csv, nil)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("状态码 = %d, want 500, body=%s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "disk") {
+		t.Errorf("响应中不应包含内部错误: %s", w.Body.String())
+	}
+	if n := count(&models.Pomodoro{}); n != 0 {
+		t.Errorf("失败后仍有 %d 个番茄钟", n)
+	}
+	if n := count(&models.Category{}); n != categoriesBefore {
+		t.Errorf("失败后分类数 = %d, want %d", n, categoriesBefore)
+	}
+	if n := count(&models.Tag{}); n != 0 {
+		t.Errorf("失败后仍有 %d 个标签", n)
+	}
+	database.DB.Callback().Create().Remove("test:fail_pomodoro")
+
+	w = uploadCSV(r, "/api/import/pomodoros", csv, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+	var report ImportReport
+	decodeJSON(t, w, &report)
+	if !report.Committed || report.Imported != 3 || len(report.Rows) != 0 {
+		t.Errorf("导入结果 = %+v", report)
+	}
+	if len(report.NewCategories) != 1 || report.NewCategories[0] != "新分类" {
+		t.Errorf("新建分类 = %v", report.NewCategories)
+	}
+
+	var pomodoros []models.Pomodoro
+	database.DB.Preload("Tags").Where("user_id = ?", user.ID).Order("started_at").Find(&pomodoros)
+	if len(pomodoros) != 3 {
+		t.Fatalf("导入了 %d 个番茄钟, want 3", len(pomodoros))
+	}
+	for _, p := range pomodoros {
+		if p.Source != models.PomodoroSourceImport || !p.Completed || p.CompletedAt == nil {
+			t.Errorf("导入的番茄钟 = %+v", p)
+		}
+	}
+	if pomodoros[1].CategoryID != pomodoros[2].CategoryID || len(pomodoros[1].Tags) != 2 {
+		t.Errorf("第二个番茄钟分类 = %d, 标签 = %v", pomodoros[1].CategoryID, pomodoros[1].Tags)
+	}
+	if n := count(&models.Tag{}); n != 2 {
+		t.Errorf("标签数 = %d, want 2", n)
+	}
+
+	// 再次导入同一文件，全部视为重复
+	w = uploadCSV(r, "/api/import/pomodoros", csv, nil)
+	decodeJSON(t, w, &report)
+	if report.Imported != 0 || report.Skipped != 3 {
+		t.Errorf("重复导入结果 imported = %d, skipped = %d", report.Imported, report.Skipped)
+	}
+	if n := count(&models.Pomodoro{}); n != 3 {
+		t.Errorf("重复导入后番茄钟数 = %d, want 3", n)
+	}
+}
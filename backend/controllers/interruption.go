@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"pomodoro-api/utils"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 单个番茄钟最多记录的打断次数
+var maxInterruptionsPerPomodoro = utils.GetEnvInt("MAX_INTERRUPTIONS_PER_POMODORO", 50)
+
+// findUserPomodoro 查找当前用户的番茄钟
+func findUserPomodoro(c *gin.Context) (*models.Pomodoro, bool) {
+	var pomodoro models.Pomodoro
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Param("id"), c.GetUint("user_id")).First(&pomodoro).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "番茄钟不存在"})
+		return nil, false
+	}
+	return &pomodoro, true
+}
+
+// CreateInterruption 记录番茄钟进行中的一次打断
+func CreateInterruption(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	pomodoro, ok := findUserPomodoro(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Type       string     `json:"type" binding:"required"`
+		OccurredAt *time.Time `json:"occurred_at"` // 可选，默认为当前时间
+		Note       string     `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !models.IsValidInterruptionType(input.Type) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "打断类型只能是 internal 或 external"})
+		return
+	}
+	if !checkNoteLength(c, input.Note) {
+		return
+	}
+
+	occurredAt := time.Now()
+	if input.OccurredAt != nil {
+		// 统一转换为本地时间保存，SQLite 按字符串比较时间，时区不同会导致范围查询出错
+		occurredAt = input.OccurredAt.Local()
+	}
+
+	// 打断时间必须在番茄钟进行期间（允许一分钟误差）
+	end := time.Now()
+	if pomodoro.CompletedAt != nil {
+		end = *pomodoro.CompletedAt
+	}
+	if occurredAt.Before(pomodoro.StartedAt.Add(-time.Minute)) || occurredAt.After(end.Add(time.Minute)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "打断时间不在番茄钟进行期间"})
+		return
+	}
+
+	var count int64
+	database.DB.Model(&models.Interruption{}).Where("pomodoro_id = ?", pomodoro.ID).Count(&count)
+	if count >= int64(maxInterruptionsPerPomodoro) {
+		rejectLimit(c, "打断次数", int(count)+1, maxInterruptionsPerPomodoro)
+		return
+	}
+
+	interruption := models.Interruption{
+		UserID:     userID,
+		PomodoroID: pomodoro.ID,
+		Type:       input.Type,
+		OccurredAt: occurredAt,
+		Note:       input.Note,
+	}
+	if err := database.DB.Create(&interruption).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, interruption)
+}
+
+// GetInterruptions 获取番茄钟的打断记录
+func GetInterruptions(c *gin.Context) {
+	pomodoro, ok := findUserPomodoro(c)
+	if !ok {
+		return
+	}
+
+	var interruptions []models.Interruption
+	database.DB.Where("pomodoro_id = ?", pomodoro.ID).Order("occurred_at ASC").Find(&interruptions)
+
+	c.JSON(http.StatusOK, interruptions)
+}
+
+// InterruptionBucket 某个维度上的打断统计
+type InterruptionBucket struct {
+	Key           int     `json:"key"`            // 分类 ID、小时（0-23）或星期（0 为周日）
+	Name          string  `json:"name,omitempty"` // 分类名称
+	Pomodoros     int     `json:"pomodoros"`      // 番茄钟数量
+	Interruptions int     `json:"interruptions"`  // 打断次数
+	Internal      int     `json:"internal"`       // 内部打断次数
+	External      int     `json:"external"`       // 外部打断次数
+	Rate          float64 `json:"rate"`           // 平均每个番茄钟的打断次数
+}
+
+// GetInterruptionStats 按分类、小时和星期统计打断率，默认最近30天
+// 番茄钟按开始时间归入小时和星期，打断按发生时间归入
+func GetInterruptionStats(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	start, end, ok := parseDateRange(c, 30)
+	if !ok {
+		return
+	}
+
+	var pomodoros []models.Pomodoro
+	database.DB.Select("id", "category_id", "started_at").
+		Where("user_id = ? AND started_at >= ? AND started_at < ?", userID, start, end).
+		Find(&pomodoros)
+
+	var interruptions []models.Interruption
+	database.DB.Where("user_id = ? AND occurred_at >= ? AND occurred_at < ?", userID, start, end).
+		Find(&interruptions)
+
+	var categories []models.Category
+	database.DB.Unscoped().Where("user_id = ?", userID).Find(&categories)
+	categoryNames := make(map[uint]string, len(categories))
+	for _, cat := range categories {
+		categoryNames[cat.ID] = cat.Name
+	}
+
+	byCategory := make(map[uint]*InterruptionBucket)
+	byHour := make([]InterruptionBucket, 24)
+	byWeekday := make([]InterruptionBucket, 7)
+	for i := range byHour {
+		byHour[i].Key = i
+	}
+	for i := range byWeekday {
+		byWeekday[i].Key = i
+	}
+
+	categoryBucket := func(id uint) *InterruptionBucket {
+		if b, ok := byCategory[id]; ok {
+			return b
+		}
+		b := &InterruptionBucket{Key: int(id), Name: categoryNames[id]}
+		byCategory[id] = b
+		return b
+	}
+
+	pomodoroCategory := make(map[uint]uint, len(pomodoros))
+	for _, p := range pomodoros {
+		pomodoroCategory[p.ID] = p.CategoryID
+		t := p.StartedAt.Local()
+		categoryBucket(p.CategoryID).Pomodoros++
+		byHour[t.Hour()].Pomodoros++
+		byWeekday[t.Weekday()].Pomodoros++
+	}
+
+	var internal, external int
+	count := func(b *InterruptionBucket, kind string) {
+		b.Interruptions++
+		if kind == models.InterruptionInternal {
+			b.Internal++
+		} else {
+			b.External++
+		}
+	}
+	for _, it := range interruptions {
+		if it.Type == models.InterruptionInternal {
+			internal++
+		} else {
+			external++
+		}
+
+		t := it.OccurredAt.Local()
+		count(&byHour[t.Hour()], it.Type)
+		count(&byWeekday[t.Weekday()], it.Type)
+		if categoryID, ok := pomodoroCategory[it.PomodoroID]; ok {
+			count(categoryBucket(categoryID), it.Type)
+		}
+	}
+
+	setRate := func(b *InterruptionBucket) {
+		if b.Pomodoros > 0 {
+			b.Rate = float64(b.Interruptions) / float64(b.Pomodoros)
+		}
+	}
+	categoryStats := make([]InterruptionBucket, 0, len(byCategory))
+	for _, b := range byCategory {
+		setRate(b)
+		categoryStats = append(categoryStats, *b)
+	}
+	sort.Slice(categoryStats, func(i, j int) bool {
+		return categoryStats[i].Rate > categoryStats[j].Rate
+	})
+	for i := range byHour {
+		setRate(&byHour[i])
+	}
+	for i := range byWeekday {
+		setRate(&byWeekday[i])
+	}
+
+	var rate float64
+	if len(pomodoros) > 0 {
+		rate = float64(len(interruptions)) / float64(len(pomodoros))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":          start.Format("2006-01-02"),
+		"to":            end.AddDate(0, 0, -1).Format("2006-01-02"),
+		"pomodoros":     len(pomodoros),
+		"interruptions": len(interruptions),
+		"internal":      internal,
+		"external":      external,
+		"rate":          rate,
+		"by_category":   categoryStats,
+		"by_hour":       byHour,
+		"by_weekday":    byWeekday,
+	})
+}
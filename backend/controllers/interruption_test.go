@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCreateInterruptionNormalizesTimeZone(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+
+	r := gin.New()
+	r.Use(withUser(user.ID))
+	r.POST("/api/pomodoros/:id/interruptions", CreateInterruption)
+	r.GET("/api/stats/interruptions", GetInterruptionStats)
+
+	var category models.Category
+	database.DB.Where("user_id = ?", user.ID).First(&category)
+	start := time.Date(2026, 3, 10, 23, 30, 0, 0, time.Local)
+	end := start.Add(25 * time.Minute)
+	pomodoro := models.Pomodoro{
+		UserID:          user.ID,
+		CategoryID:      category.ID,
+		Duration:        1500,
+		PlannedDuration: 1500,
+		Completed:       true,
+		StartedAt:       start,
+		CompletedAt:     &end,
+	}
+	database.DB.Create(&pomodoro)
+
+	// 客户端使用 +09:00 时区提交，与服务器时区不同时日期也可能不同
+	occurredAt := start.Add(10 * time.Minute).In(time.FixedZone("JST", 9*3600))
+	w := performRequest(r, http.MethodPost, fmt.Sprintf("/api/pomodoros/%d/interruptions", pomodoro.ID), gin.H{
+		"type":        models.InterruptionExternal,
+		"occurred_at": occurredAt.Format(time.RFC3339),
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("创建打断状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+
+	var stored models.Interruption
+	database.DB.First(&stored)
+	if got, want := stored.OccurredAt.Format(time.RFC3339), occurredAt.Local().Format(time.RFC3339); got != want {
+		t.Errorf("保存的时间 = %s, want %s（本地时区）", got, want)
+	}
+
+	w = performRequest(r, http.MethodGet, "/api/stats/interruptions?from=2026-03-10&to=2026-03-10", nil)
+	var stats struct {
+		Pomodoros     int                  `json:"pomodoros"`
+		Interruptions int                  `json:"interruptions"`
+		ByHour        []InterruptionBucket `json:"by_hour"`
+	}
+	decodeJSON(t, w, &stats)
+	if stats.Pomodoros != 1 || stats.Interruptions != 1 {
+		t.Fatalf("统计结果 pomodoros=%d interruptions=%d, 都应为 1", stats.Pomodoros, stats.Interruptions)
+	}
+	if stats.ByHour[23].Interruptions != 1 {
+		t.Errorf("打断应归入本地时间 23 点")
+	}
+}
@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"net/http"
+	"pomodoro-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJWKS 公开签名公钥，供其他内部服务校验本服务签发的令牌
+func GetJWKS(c *gin.Context) {
+	jwks, err := utils.PublicJWKS()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "密钥加载失败"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, jwks)
+}
@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"pomodoro-api/utils"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 数据量限制（可通过环境变量调整）
+var (
+	// 每个用户最多可创建的分类数
+	maxCategoriesPerUser = utils.GetEnvInt("MAX_CATEGORIES_PER_USER", 50)
+	// 备注最大长度（字符）
+	maxNoteLength = utils.GetEnvInt("MAX_NOTE_LENGTH", 500)
+	// 单日单词数上限，防止刷排行榜
+	maxDailyWordCount = utils.GetEnvInt("MAX_DAILY_WORD_COUNT", 10000)
+	// 单个番茄钟计划时长上限（秒）
+	maxPlannedDuration = utils.GetEnvInt("MAX_PLANNED_DURATION", 4*3600)
+)
+
+// rejectLimit 记录并返回超出限制的请求
+func rejectLimit(c *gin.Context, what string, value, limit int) {
+	log.Printf("超出数据限制: user_id=%d, %s=%d, 上限=%d, path=%s", c.GetUint("user_id"), what, value, limit, c.FullPath())
+	c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s超出限制（最多 %d）", what, limit)})
+}
+
+// checkNoteLength 检查备注长度，超出时写入响应并返回 false
+func checkNoteLength(c *gin.Context, note string) bool {
+	if n := utf8.RuneCountInString(note); n > maxNoteLength {
+		rejectLimit(c, "备注长度", n, maxNoteLength)
+		return false
+	}
+	return true
+}
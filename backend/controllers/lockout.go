@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"pomodoro-api/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 登录失败锁定策略（可通过环境变量调整）
+var (
+	// 连续失败多少次后开始锁定
+	loginLockThreshold = utils.GetEnvInt("LOGIN_LOCK_THRESHOLD", 5)
+	// 首次锁定时长，之后每多失败一次翻倍
+	loginLockBase = utils.GetEnvDuration("LOGIN_LOCK_BASE", time.Minute)
+	// 单次锁定的最长时长
+	loginLockMax = utils.GetEnvDuration("LOGIN_LOCK_MAX", time.Hour)
+	// 超过该时间的失败记录不再计数
+	loginFailureWindow = utils.GetEnvDuration("LOGIN_FAILURE_WINDOW", 24*time.Hour)
+	// 解锁邮件链接有效期
+	unlockTokenTTL = utils.GetEnvDuration("UNLOCK_TOKEN_TTL", time.Hour)
+)
+
+// loginLock 账号当前的锁定状态
+type loginLock struct {
+	Failures    int64
+	LockedUntil time.Time
+}
+
+func (l loginLock) locked(now time.Time) bool {
+	return now.Before(l.LockedUntil)
+}
+
+// failedLogins 按账号筛选失败记录；未知账号按登录标识筛选，使其行为与真实账号一致
+func failedLogins(user *models.User, found bool, identifier string) *gorm.DB {
+	query := database.DB.Model(&models.LoginAttempt{})
+	if found {
+		return query.Where("user_id = ?", user.ID)
+	}
+	return query.Where("user_id IS NULL AND identifier = ?", strings.ToLower(identifier))
+}
+
+// getLoginLock 计算账号的锁定状态：失败次数达到阈值后按指数退避锁定
+func getLoginLock(user *models.User, found bool, identifier string) loginLock {
+	var lock loginLock
+	failedLogins(user, found, identifier).
+		Where("created_at > ?", time.Now().Add(-loginFailureWindow)).
+		Count(&lock.Failures)
+
+	if lock.Failures < int64(loginLockThreshold) {
+		return lock
+	}
+
+	var last models.LoginAttempt
+	if err := failedLogins(user, found, identifier).Order("created_at DESC").First(&last).Error; err != nil {
+		return lock
+	}
+	lock.LockedUntil = last.CreatedAt.Add(lockoutDuration(lock.Failures))
+	return lock
+}
+
+// lockoutDuration 第 threshold 次失败锁定 base，此后每次翻倍，不超过 max
+func lockoutDuration(failures int64) time.Duration {
+	exp := float64(failures - int64(loginLockThreshold))
+	d := time.Duration(float64(loginLockBase) * math.Pow(2, exp))
+	if d > loginLockMax || d <= 0 {
+		return loginLockMax
+	}
+	return d
+}
+
+// respondLocked 返回账号锁定响应
+func respondLocked(c *gin.Context, lock loginLock) {
+	retryAfter := int(math.Ceil(time.Until(lock.LockedUntil).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "登录失败次数过多，账号已被临时锁定，请稍后再试或通过邮件解锁",
+		"retry_after": retryAfter,
+	})
+}
+
+// handleLoginFailure 记录失败并在触发锁定时写入审计、发送解锁邮件
+func handleLoginFailure(c *gin.Context, user *models.User, found bool, identifier string) {
+	recordFailedLogin(c, user, found, identifier)
+
+	lock := getLoginLock(user, found, identifier)
+	if !lock.locked(time.Now()) {
+		return
+	}
+
+	event := models.AuditEvent{
+		Action: "login.lockout",
+		Detail: fmt.Sprintf("failures=%d locked_until=%s", lock.Failures, lock.LockedUntil.Format(time.RFC3339)),
+	}
+	if found {
+		event.TargetType = "user"
+		event.TargetID = strconv.FormatUint(uint64(user.ID), 10)
+	} else {
+		event.TargetType = "identifier"
+		event.TargetID = strings.ToLower(identifier)
+	}
+	recordAudit(c, event)
+	log.Printf("账号锁定: %s=%s, 失败次数=%d, 锁定至 %s", event.TargetType, event.TargetID, lock.Failures, lock.LockedUntil.Format(time.RFC3339))
+
+	if found {
+		if err := sendUnlockEmail(user, false); err != nil {
+			log.Printf("发送解锁邮件失败: user_id=%d, err=%v", user.ID, err)
+		}
+	}
+}
+
+// sendUnlockEmail 生成解锁令牌并发送邮件
+// force 为 false 时，若已有未过期的解锁令牌则不重复发送
+func sendUnlockEmail(user *models.User, force bool) error {
+	if !force {
+		var pending int64
+		database.DB.Model(&models.AccountToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", user.ID, models.AccountTokenPurposeUnlock, time.Now()).
+			Count(&pending)
+		if pending > 0 {
+			return nil
+		}
+	}
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	record := models.AccountToken{
+		UserID:    user.ID,
+		Purpose:   models.AccountTokenPurposeUnlock,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(unlockTokenTTL),
+	}
+	if err := database.DB.Create(&record).Error; err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(utils.GetEnv("PUBLIC_BASE_URL", "http://localhost:8080"), "/") + "/api/auth/unlock?token=" + token
+	body := fmt.Sprintf("%s，你好：\n\n你的账号因多次登录失败已被临时锁定。\n如果是你本人操作，请在 %d 分钟内打开以下链接解锁：\n\n%s\n\n如果不是你本人操作，建议尽快修改密码。",
+		user.Username, int(unlockTokenTTL.Minutes()), link)
+	return utils.SendMail(user.Email, "番茄钟账号解锁", body)
+}
+
+// unlockAccount 清除账号的失败记录
+func unlockAccount(userID uint) {
+	clearFailedLogins(userID)
+}
+
+// RequestUnlock 重新发送解锁邮件（无论账号是否存在都返回相同结果）
+func RequestUnlock(c *gin.Context) {
+	var input struct {
+		Account string `json:"account" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	identifier := strings.TrimSpace(input.Account)
+	var user models.User
+	if err := whereAccount(identifier).First(&user).Error; err == nil {
+		if getLoginLock(&user, true, identifier).locked(time.Now()) {
+			if err := sendUnlockEmail(&user, true); err != nil {
+				log.Printf("发送解锁邮件失败: user_id=%d, err=%v", user.ID, err)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "如果该账号存在且已被锁定，解锁邮件已发送"})
+}
+
+// unlockPage 解锁确认页面，Token 为空时只显示 Message
+var unlockPage = template.Must(template.New("unlock").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>解锁账号</title>
+</head>
+<body>
+{{if .Token}}<form method="post" action="unlock">
+<p>确认解锁你的番茄钟账号？</p>
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">解锁账号</button>
+</form>{{else}}<p>{{.Message}}</p>{{end}}
+</body>
+</html>
+`))
+
+// renderUnlockPage 输出解锁页面，令牌在地址中，禁止缓存和发送 Referer
+func renderUnlockPage(c *gin.Context, status int, token, message string) {
+	header := c.Writer.Header()
+	header.Set("Content-Security-Policy", "default-src 'none'; form-action 'self'; frame-ancestors 'none'")
+	header.Set("Referrer-Policy", "no-referrer")
+	header.Set("Cache-Control", "no-store")
+	header.Set("X-Content-Type-Options", "nosniff")
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := unlockPage.Execute(c.Writer, gin.H{"Token": token, "Message": message}); err != nil {
+		log.Printf("输出解锁页面失败: %v", err)
+	}
+}
+
+// UnlockPage 邮件中的解锁链接打开的确认页面
+// GET 不修改账号状态，避免邮件扫描器或链接预取提前消耗一次性令牌，由页面中的表单 POST 解锁
+func UnlockPage(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		renderUnlockPage(c, http.StatusBadRequest, "", "缺少解锁令牌")
+		return
+	}
+	renderUnlockPage(c, http.StatusOK, token, "")
+}
+
+// UnlockAccount 通过邮件中的令牌解锁账号
+// 接受 JSON {"token"} 或确认页面提交的表单，表单提交时返回页面
+func UnlockAccount(c *gin.Context) {
+	fromPage := c.ContentType() == "application/x-www-form-urlencoded"
+	respond := func(status int, key, message string) {
+		if fromPage {
+			renderUnlockPage(c, status, "", message)
+		} else {
+			c.JSON(status, gin.H{key: message})
+		}
+	}
+
+	token := c.Query("token")
+	if fromPage {
+		token = c.PostForm("token")
+	} else if token == "" {
+		var input struct {
+			Token string `json:"token"`
+		}
+		c.ShouldBindJSON(&input)
+		token = input.Token
+	}
+	if token == "" {
+		respond(http.StatusBadRequest, "error", "缺少解锁令牌")
+		return
+	}
+
+	var record models.AccountToken
+	err := database.DB.Where("token_hash = ? AND purpose = ?", utils.HashToken(token), models.AccountTokenPurposeUnlock).First(&record).Error
+	if err != nil || record.UsedAt != nil || time.Now().After(record.ExpiresAt) {
+		respond(http.StatusBadRequest, "error", "解锁链接无效或已过期")
+		return
+	}
+
+	// 只有一个请求能把令牌标记为已使用，并发提交同一令牌时其余请求失败
+	claimed := database.DB.Model(&models.AccountToken{}).
+		Where("id = ? AND used_at IS NULL", record.ID).
+		Update("used_at", time.Now())
+	if claimed.Error != nil || claimed.RowsAffected != 1 {
+		respond(http.StatusBadRequest, "error", "解锁链接无效或已过期")
+		return
+	}
+	unlockAccount(record.UserID)
+
+	recordAudit(c, models.AuditEvent{
+		ActorID:    &record.UserID,
+		Action:     "account.unlock",
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(record.UserID), 10),
+		Detail:     "via=email",
+	})
+
+	respond(http.StatusOK, "message", "账号已解锁，请重新登录")
+}
+
+// AdminUnlockAccount 管理员通过命令行解锁账号（用户名或邮箱）
+func AdminUnlockAccount(identifier string) error {
+	var user models.User
+	if err := whereAccount(identifier).First(&user).Error; err != nil {
+		return errors.New("用户不存在")
+	}
+
+	unlockAccount(user.ID)
+	recordAudit(nil, models.AuditEvent{
+		Action:     "account.unlock",
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(user.ID), 10),
+		Detail:     "via=cli",
+	})
+	return nil
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"pomodoro-api/utils"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// createUnlockToken 为用户创建解锁令牌和若干登录失败记录
+func createUnlockToken(t *testing.T, user *models.User, plain string) {
+	t.Helper()
+	for i := 0; i < 3; i++ {
+		database.DB.Create(&models.LoginAttempt{UserID: &user.ID, Identifier: user.Username})
+	}
+	record := models.AccountToken{
+		UserID: user.ID, Purpose: models.AccountTokenPurposeUnlock,
+		TokenHash: utils.HashToken(plain), ExpiresAt: time.Now().Add(time.Hour),
+	}
+	if err := database.DB.Create(&record).Error; err != nil {
+		t.Fatalf("创建解锁令牌失败: %v", err)
+	}
+}
+
+// failedLoginCount 返回用户的登录失败记录数
+func failedLoginCount(userID uint) int64 {
+	var n int64
+	database.DB.Model(&models.LoginAttempt{}).Where("user_id = ?", userID).Count(&n)
+	return n
+}
+
+func TestUnlockTokenIsSingleUse(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+	createUnlockToken(t, user, "unlock-secret")
+
+	r := gin.New()
+	r.POST("/api/auth/unlock", UnlockAccount)
+
+	w := performRequest(r, http.MethodPost, "/api/auth/unlock", gin.H{"token": "unlock-secret"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("解锁状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+	if n := failedLoginCount(user.ID); n != 0 {
+		t.Errorf("解锁后仍有 %d 条失败记录", n)
+	}
+
+	createUnlockToken(t, user, "other-secret")
+	if w := performRequest(r, http.MethodPost, "/api/auth/unlock", gin.H{"token": "unlock-secret"}); w.Code != http.StatusBadRequest {
+		t.Errorf("重复使用令牌状态码 = %d, want 400", w.Code)
+	}
+	if n := failedLoginCount(user.ID); n != 3 {
+		t.Errorf("重复使用令牌不应清除失败记录, 剩余 %d 条", n)
+	}
+}
+
+func TestUnlockTokenClaimedConcurrently(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+	createUnlockToken(t, user, "unlock-secret")
+
+	// 模拟另一个请求在本次请求查到令牌之后、标记为已使用之前抢先使用了令牌
+	claimed := false
+	err := database.DB.Callback().Query().After("gorm:query").Register("test:claim_token", func(db *gorm.DB) {
+		if db.Statement.Table == "account_tokens" && !claimed {
+			claimed = true
+			db.Session(&gorm.Session{NewDB: true}).Model(&models.AccountToken{}).
+				Where("user_id = ?", user.ID).Update("used_at", time.Now())
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.DB.Callback().Query().Remove("test:claim_token") })
+
+	r := gin.New()
+	r.POST("/api/auth/unlock", UnlockAccount)
+	w := performRequest(r, http.MethodPost, "/api/auth/unlock", gin.H{"token": "unlock-secret"})
+	if !claimed {
+		t.Fatal("没有模拟并发使用")
+	}
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("令牌已被并发使用时状态码 = %d, want 400", w.Code)
+	}
+	if n := failedLoginCount(user.ID); n != 3 {
+		t.Errorf("令牌已被并发使用时不应解锁, 剩余 %d 条失败记录", n)
+	}
+}
@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"pomodoro-api/database"
+	"pomodoro-api/middleware"
+	"pomodoro-api/models"
+	"pomodoro-api/utils"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const oidcStateCookie = "oidc_state"
+
+// oidcProvider 未配置 OIDC 时为 nil
+var oidcProvider = newOIDCProviderFromEnv()
+
+func newOIDCProviderFromEnv() *utils.OIDCProvider {
+	config := utils.LoadOIDCConfig()
+	if config == nil {
+		return nil
+	}
+	log.Printf("已启用OIDC登录: %s", config.DiscoveryURL)
+	return utils.NewOIDCProvider(*config, nil)
+}
+
+// OIDCLogin 跳转到外部身份提供方登录
+func OIDCLogin(c *gin.Context) {
+	if oidcProvider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未启用第三方登录"})
+		return
+	}
+
+	var parts [3]string
+	for i := range parts {
+		v, err := utils.GenerateRandomToken(32)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "登录请求生成失败"})
+			return
+		}
+		parts[i] = v
+	}
+	state, nonce, verifier := parts[0], parts[1], parts[2]
+
+	// mode=cookie 时登录成功后使用 Cookie 认证模式
+	mode := "token"
+	if c.Query("mode") == "cookie" && middleware.CookieAuthEnabled() {
+		mode = "cookie"
+	}
+
+	authURL, err := oidcProvider.AuthCodeURL(c.Request.Context(), state, nonce, verifier)
+	if err != nil {
+		log.Printf("OIDC授权地址生成失败: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "身份提供方暂时不可用"})
+		return
+	}
+
+	// state、nonce、PKCE verifier 和登录模式保存在短期 Cookie 中，回调时校验
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state+"."+nonce+"."+verifier+"."+mode, 600, "/api/auth/oidc", "", middleware.IsSecureRequest(c), true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback 外部身份提供方登录回调
+func OIDCCallback(c *gin.Context) {
+	if oidcProvider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未启用第三方登录"})
+		return
+	}
+
+	if errCode := c.Query("error"); errCode != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "第三方登录失败: " + errCode})
+		return
+	}
+
+	cookie, err := c.Cookie(oidcStateCookie)
+	c.SetCookie(oidcStateCookie, "", -1, "/api/auth/oidc", "", middleware.IsSecureRequest(c), true)
+	parts := strings.Split(cookie, ".")
+	if err != nil || len(parts) != 4 || c.Query("state") == "" || c.Query("state") != parts[0] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "登录状态无效，请重新登录"})
+		return
+	}
+
+	claims, err := oidcProvider.Exchange(c.Request.Context(), c.Query("code"), parts[2], parts[1])
+	if err != nil {
+		log.Printf("OIDC登录失败: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "第三方登录失败"})
+		return
+	}
+
+	user, err := findOrCreateOIDCUser(c, claims)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if user.Disabled {
+		recordLoginAudit(c, "login.failure", user, true, "", "method=oidc reason=disabled")
+		c.JSON(http.StatusForbidden, gin.H{"error": "账号已被停用，请联系管理员"})
+		return
+	}
+
+	token, err := utils.GenerateToken(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token 生成失败"})
+		return
+	}
+	recordLoginAudit(c, "login.success", user, true, "", "method=oidc")
+
+	redirect := utils.GetEnv("OIDC_SUCCESS_REDIRECT", "")
+	if parts[3] == "cookie" {
+		if redirect == "" {
+			respondCookieSession(c, token, user)
+			return
+		}
+		if _, err := middleware.SetSessionCookies(c, token); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Token 生成失败"})
+			return
+		}
+		c.Redirect(http.StatusFound, redirect)
+		return
+	}
+
+	// 配置了前端地址时通过 URL 片段把令牌交给前端，否则直接返回 JSON
+	if redirect != "" {
+		c.Redirect(http.StatusFound, redirect+"#token="+url.QueryEscape(token))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token": token,
+		"user":  user,
+	})
+}
+
+// findOrCreateOIDCUser 按绑定关系、已验证邮箱依次查找用户，都不存在时自动创建
+func findOrCreateOIDCUser(c *gin.Context, claims *utils.OIDCClaims) (*models.User, error) {
+	issuer := claims.Issuer
+
+	var identity models.UserIdentity
+	if err := database.DB.Where("provider = ? AND subject = ?", issuer, claims.Subject).First(&identity).Error; err == nil {
+		var user models.User
+		if err := database.DB.First(&user, identity.UserID).Error; err != nil {
+			return nil, errors.New("绑定的用户不存在")
+		}
+		return &user, nil
+	}
+
+	// 只有经过身份提供方验证的邮箱才能用于绑定或创建账号
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, errors.New("第三方账号邮箱未验证，无法登录")
+	}
+
+	var user models.User
+	err := database.DB.Where("email = ?", claims.Email).First(&user).Error
+	created := false
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		newUser, err := createOIDCUser(claims)
+		if err != nil {
+			log.Printf("OIDC自动创建用户失败: %v", err)
+			return nil, errors.New("创建用户失败")
+		}
+		user = *newUser
+		created = true
+	} else if err != nil {
+		return nil, errors.New("查询用户失败")
+	}
+
+	identity = models.UserIdentity{
+		UserID:   user.ID,
+		Provider: issuer,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+	if err := database.DB.Create(&identity).Error; err != nil {
+		return nil, errors.New("绑定第三方账号失败")
+	}
+
+	detail := "provider=" + issuer + " created=" + strconv.FormatBool(created)
+	recordAudit(c, models.AuditEvent{
+		ActorID:    &user.ID,
+		Action:     "account.link_oidc",
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(user.ID), 10),
+		Detail:     detail,
+	})
+	return &user, nil
+}
+
+var usernameInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_\-\.]`)
+
+// createOIDCUser 自动创建用户，默认设置和分类与注册一致
+func createOIDCUser(claims *utils.OIDCClaims) (*models.User, error) {
+	// 第三方账号没有本地密码，使用随机密码占位
+	randomPassword, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(randomPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	base := claims.PreferredUsername
+	if base == "" {
+		base = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	base = usernameInvalidChars.ReplaceAllString(base, "")
+	if len(base) > 13 {
+		base = base[:13]
+	}
+	for len(base) < 3 {
+		base += "_"
+	}
+
+	// 用户名冲突时追加随机后缀
+	username := base
+	for i := 0; i < 5; i++ {
+		var count int64
+		database.DB.Model(&models.User{}).Where("username = ?", username).Count(&count)
+		if count == 0 {
+			break
+		}
+		suffix, err := utils.GenerateRandomToken(4)
+		if err != nil {
+			return nil, err
+		}
+		username = base + "_" + strings.ToLower(suffix)
+	}
+
+	user := models.User{
+		Username: username,
+		Email:    claims.Email,
+		Password: string(hashedPassword),
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		return nil, err
+	}
+
+	createDefaultUserData(user.ID)
+	return &user, nil
+}
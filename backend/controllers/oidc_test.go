@@ -0,0 +1,340 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"pomodoro-api/utils"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testOIDCClientID = "pomodoro-test"
+
+// mockOIDCAuthorization 授权端点签发的授权码对应的请求
+type mockOIDCAuthorization struct {
+	challenge   string
+	nonce       string
+	redirectURI string
+}
+
+// mockOIDCIssuer 本地模拟的身份提供方，提供发现文档、授权、令牌和 JWKS 端点
+type mockOIDCIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	// 下一次登录返回的用户信息
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	// 不为空时 ID Token 使用这个 nonce，模拟被替换的令牌
+	NonceOverride string
+
+	mu    sync.Mutex
+	codes map[string]mockOIDCAuthorization
+}
+
+func newMockOIDCIssuer(t *testing.T) *mockOIDCIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("生成密钥失败: %v", err)
+	}
+
+	m := &mockOIDCIssuer{key: key, codes: map[string]mockOIDCAuthorization{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.URL,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"jwks_uri":               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/authorize", m.authorize)
+	mux.HandleFunc("/token", m.token)
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		jwk, err := utils.NewJWK(&m.key.PublicKey, "test-key", "RS256")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(utils.JWKSet{Keys: []utils.JWK{jwk}})
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+// authorize 校验授权请求并直接跳转回应用（模拟用户已同意）
+func (m *mockOIDCIssuer) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != testOIDCClientID || q.Get("response_type") != "code" ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" ||
+		q.Get("state") == "" || q.Get("nonce") == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	code, _ := utils.GenerateRandomToken(16)
+	m.mu.Lock()
+	m.codes[code] = mockOIDCAuthorization{challenge: q.Get("code_challenge"), nonce: q.Get("nonce"), redirectURI: q.Get("redirect_uri")}
+	m.mu.Unlock()
+
+	http.Redirect(w, r, q.Get("redirect_uri")+"?code="+code+"&state="+url.QueryEscape(q.Get("state")), http.StatusFound)
+}
+
+// token 用授权码换取 ID Token，授权码只能使用一次，并校验 PKCE
+func (m *mockOIDCIssuer) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	m.mu.Lock()
+	auth, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("redirect_uri") != auth.redirectURI ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != auth.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	nonce := auth.nonce
+	if m.NonceOverride != "" {
+		nonce = m.NonceOverride
+	}
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, utils.OIDCClaims{
+		Email:             m.Email,
+		EmailVerified:     m.EmailVerified,
+		PreferredUsername: m.Username,
+		Nonce:             nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.URL,
+			Subject:   m.Subject,
+			Audience:  jwt.ClaimStrings{testOIDCClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+	})
+	token.Header["kid"] = "test-key"
+	signed, err := token.SignedString(m.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": signed, "token_type": "Bearer"})
+}
+
+// setupOIDCTest 使用模拟的身份提供方，返回应用路由
+func setupOIDCTest(t *testing.T) (*mockOIDCIssuer, *gin.Engine) {
+	t.Helper()
+	setupTestDB(t)
+	issuer := newMockOIDCIssuer(t)
+
+	previous := oidcProvider
+	oidcProvider = utils.NewOIDCProvider(utils.OIDCConfig{
+		DiscoveryURL: issuer.URL + "/.well-known/openid-configuration",
+		ClientID:     testOIDCClientID,
+		RedirectURL:  "http://app.test/api/auth/oidc/callback",
+		Scopes:       []string{"openid", "email", "profile"},
+	}, issuer.Client())
+	t.Cleanup(func() { oidcProvider = previous })
+
+	r := gin.New()
+	r.GET("/api/auth/oidc/login", OIDCLogin)
+	r.GET("/api/auth/oidc/callback", OIDCCallback)
+	return issuer, r
+}
+
+// startOIDCLogin 发起登录并经过模拟授权端点，返回回调地址和 state Cookie
+func startOIDCLogin(t *testing.T, issuer *mockOIDCIssuer, r *gin.Engine) (*url.URL, *http.Cookie) {
+	t.Helper()
+	w := performRequest(r, http.MethodGet, "/api/auth/oidc/login", nil)
+	if w.Code != http.StatusFound {
+		t.Fatalf("登录跳转状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+	var stateCookie *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == oidcStateCookie {
+			stateCookie = cookie
+		}
+	}
+	if stateCookie == nil || !stateCookie.HttpOnly {
+		t.Fatalf("缺少 HttpOnly 的 state Cookie")
+	}
+
+	client := issuer.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Get(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("请求授权端点失败: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("授权端点状态码 = %d", resp.StatusCode)
+	}
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("回调地址无效: %v", err)
+	}
+	return callback, stateCookie
+}
+
+// finishOIDCLogin 带上 state Cookie 访问回调地址
+func finishOIDCLogin(r *gin.Engine, callback *url.URL, cookie *http.Cookie) *httptest.ResponseRecorder {
+	return performRequest(r, http.MethodGet, callback.RequestURI(), nil, "Cookie", cookie.Name+"="+cookie.Value)
+}
+
+type oidcLoginResponse struct {
+	Token string      `json:"token"`
+	User  models.User `json:"user"`
+	Error string      `json:"error"`
+}
+
+func TestOIDCLoginCreatesUser(t *testing.T) {
+	issuer, r := setupOIDCTest(t)
+	issuer.Subject, issuer.Email, issuer.EmailVerified, issuer.Username = "sub-1", "new@example.com", true, "new.user"
+
+	callback, cookie := startOIDCLogin(t, issuer, r)
+	w := finishOIDCLogin(r, callback, cookie)
+	if w.Code != http.StatusOK {
+		t.Fatalf("回调状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+	var resp oidcLoginResponse
+	decodeJSON(t, w, &resp)
+	if resp.Token == "" || resp.User.Username != "new.user" || resp.User.Email != "new@example.com" {
+		t.Fatalf("登录响应不正确: %+v", resp)
+	}
+	if claims, err := utils.ParseToken(resp.Token); err != nil || claims.UserID != resp.User.ID {
+		t.Fatalf("签发的令牌无效: %v", err)
+	}
+
+	var categories int64
+	database.DB.Model(&models.Category{}).Where("user_id = ?", resp.User.ID).Count(&categories)
+	if categories == 0 {
+		t.Errorf("新用户没有默认分类")
+	}
+
+	// 同一个第三方账号再次登录，使用已绑定的用户
+	callback, cookie = startOIDCLogin(t, issuer, r)
+	w = finishOIDCLogin(r, callback, cookie)
+	var again oidcLoginResponse
+	decodeJSON(t, w, &again)
+	if w.Code != http.StatusOK || again.User.ID != resp.User.ID {
+		t.Fatalf("再次登录应使用同一用户: code=%d, user_id=%d", w.Code, again.User.ID)
+	}
+	var users, identities int64
+	database.DB.Model(&models.User{}).Count(&users)
+	database.DB.Model(&models.UserIdentity{}).Count(&identities)
+	if users != 1 || identities != 1 {
+		t.Errorf("users=%d identities=%d, 都应为 1", users, identities)
+	}
+}
+
+func TestOIDCCallbackRejectsBadState(t *testing.T) {
+	issuer, r := setupOIDCTest(t)
+	issuer.Subject, issuer.Email, issuer.EmailVerified = "sub-1", "new@example.com", true
+
+	callback, cookie := startOIDCLogin(t, issuer, r)
+
+	// state 与 Cookie 不一致
+	forged := *callback
+	q := forged.Query()
+	q.Set("state", "forged")
+	forged.RawQuery = q.Encode()
+	if w := finishOIDCLogin(r, &forged, cookie); w.Code != http.StatusBadRequest {
+		t.Errorf("state 不一致时状态码 = %d, 应为 400", w.Code)
+	}
+
+	// 没有 state Cookie
+	if w := performRequest(r, http.MethodGet, callback.RequestURI(), nil); w.Code != http.StatusBadRequest {
+		t.Errorf("缺少 Cookie 时状态码 = %d, 应为 400", w.Code)
+	}
+
+	// PKCE verifier 与授权请求不一致
+	parts := strings.Split(cookie.Value, ".")
+	parts[2] = "wrong-verifier"
+	tampered := &http.Cookie{Name: cookie.Name, Value: strings.Join(parts, ".")}
+	if w := finishOIDCLogin(r, callback, tampered); w.Code != http.StatusUnauthorized {
+		t.Errorf("verifier 不一致时状态码 = %d, 应为 401", w.Code)
+	}
+
+	var users int64
+	database.DB.Model(&models.User{}).Count(&users)
+	if users != 0 {
+		t.Errorf("登录失败时不应创建用户")
+	}
+}
+
+func TestOIDCCallbackRejectsNonceMismatch(t *testing.T) {
+	issuer, r := setupOIDCTest(t)
+	issuer.Subject, issuer.Email, issuer.EmailVerified = "sub-1", "new@example.com", true
+	issuer.NonceOverride = "other-nonce"
+
+	callback, cookie := startOIDCLogin(t, issuer, r)
+	if w := finishOIDCLogin(r, callback, cookie); w.Code != http.StatusUnauthorized {
+		t.Fatalf("nonce 不一致时状态码 = %d, 应为 401", w.Code)
+	}
+}
+
+func TestOIDCLinksExistingUserByVerifiedEmail(t *testing.T) {
+	issuer, r := setupOIDCTest(t)
+	existing := createTestUser(t, "alice", "alice@example.com")
+	issuer.Subject, issuer.Email, issuer.EmailVerified, issuer.Username = "sub-alice", "alice@example.com", true, "alice-sso"
+
+	callback, cookie := startOIDCLogin(t, issuer, r)
+	w := finishOIDCLogin(r, callback, cookie)
+	if w.Code != http.StatusOK {
+		t.Fatalf("回调状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+	var resp oidcLoginResponse
+	decodeJSON(t, w, &resp)
+	if resp.User.ID != existing.ID {
+		t.Fatalf("应绑定到已有用户 %d, 实际为 %d", existing.ID, resp.User.ID)
+	}
+
+	var identity models.UserIdentity
+	if err := database.DB.Where("provider = ? AND subject = ?", issuer.URL, "sub-alice").First(&identity).Error; err != nil || identity.UserID != existing.ID {
+		t.Fatalf("没有创建绑定关系: %v", err)
+	}
+	var audits int64
+	database.DB.Model(&models.AuditEvent{}).Where("action = ?", "account.link_oidc").Count(&audits)
+	if audits != 1 {
+		t.Errorf("绑定应记录审计日志")
+	}
+}
+
+func TestOIDCRefusesUnverifiedEmail(t *testing.T) {
+	issuer, r := setupOIDCTest(t)
+	createTestUser(t, "alice", "alice@example.com")
+	issuer.Subject, issuer.Email, issuer.EmailVerified = "sub-attacker", "alice@example.com", false
+
+	callback, cookie := startOIDCLogin(t, issuer, r)
+	w := finishOIDCLogin(r, callback, cookie)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("邮箱未验证时状态码 = %d, 应为 403", w.Code)
+	}
+
+	var identities, users int64
+	database.DB.Model(&models.UserIdentity{}).Count(&identities)
+	database.DB.Model(&models.User{}).Count(&users)
+	if identities != 0 || users != 1 {
+		t.Errorf("邮箱未验证时不应绑定或创建用户: identities=%d users=%d", identities, users)
+	}
+}
@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 列表分页大小
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// sortKind 排序字段的类型，决定游标值如何编码
+type sortKind int
+
+const (
+	sortTime sortKind = iota
+	sortInt
+	sortString
+)
+
+// listSort 列表排序方式，相同值按 id 排序保证顺序稳定
+type listSort struct {
+	column string
+	desc   bool
+	kind   sortKind
+}
+
+// listCursor 游标，记录生成时的排序方式和上一页最后一条的排序值、ID
+type listCursor struct {
+	Column string          `json:"c"`
+	Desc   bool            `json:"d,omitempty"`
+	Value  json.RawMessage `json:"v"`
+	ID     uint            `json:"id"`
+}
+
+// errCursorSortMismatch 游标与本次请求的排序方式不一致
+var errCursorSortMismatch = errors.New("游标与排序方式不一致")
+
+// parseListSort 解析 sort 参数（如 -started_at 表示降序），columns 为允许的排序字段
+func parseListSort(c *gin.Context, def string, columns map[string]sortKind) (listSort, bool) {
+	raw := c.DefaultQuery("sort", def)
+	sort := listSort{column: strings.TrimPrefix(raw, "-"), desc: strings.HasPrefix(raw, "-")}
+	kind, ok := columns[sort.column]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的排序字段"})
+		return sort, false
+	}
+	sort.kind = kind
+	return sort, true
+}
+
+// parseListLimit 解析 limit 参数，默认 50，最多 200
+func parseListLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		return defaultListLimit
+	}
+	if limit > maxListLimit {
+		return maxListLimit
+	}
+	return limit
+}
+
+// escapeLike 转义 LIKE 中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// paginate 按游标和排序方式查询下一页，多取一条用于判断是否还有更多
+func paginate(c *gin.Context, query *gorm.DB, sort listSort, limit int) (*gorm.DB, bool) {
+	op, order := ">", "ASC"
+	if sort.desc {
+		op, order = "<", "DESC"
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		value, id, err := decodeCursor(raw, sort)
+		if errors.Is(err, errCursorSortMismatch) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "游标与排序方式不一致，请从第一页重新查询"})
+			return nil, false
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的游标"})
+			return nil, false
+		}
+		query = query.Where("("+sort.column+" "+op+" ? OR ("+sort.column+" = ? AND id "+op+" ?))", value, value, id)
+	}
+
+	return query.Order(sort.column + " " + order).Order("id " + order).Limit(limit + 1), true
+}
+
+// setPageHeaders 写入总数和下一页游标，没有更多数据时不返回 X-Next-Cursor
+func setPageHeaders(c *gin.Context, total int64, next string) {
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	if next != "" {
+		c.Header("X-Next-Cursor", next)
+	}
+}
+
+// encodeCursor 由排序方式和一页最后一条的排序值、ID 生成下一页游标
+func encodeCursor(sort listSort, value interface{}, id uint) string {
+	v, _ := json.Marshal(value)
+	data, _ := json.Marshal(listCursor{Column: sort.column, Desc: sort.desc, Value: v, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor 解析游标，排序方式与生成游标时不同时返回 errCursorSortMismatch
+func decodeCursor(raw string, sort listSort) (interface{}, uint, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, 0, err
+	}
+	var cursor listCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, 0, err
+	}
+	if cursor.Column != sort.column || cursor.Desc != sort.desc {
+		return nil, 0, errCursorSortMismatch
+	}
+
+	switch sort.kind {
+	case sortInt:
+		var v int64
+		err = json.Unmarshal(cursor.Value, &v)
+		return v, cursor.ID, err
+	case sortTime:
+		var v time.Time
+		err = json.Unmarshal(cursor.Value, &v)
+		// 与写入数据库时的时区保持一致，保证按字符串比较的结果正确
+		return v.Local(), cursor.ID, err
+	default:
+		var v string
+		err = json.Unmarshal(cursor.Value, &v)
+		return v, cursor.ID, err
+	}
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// walkPages 按 X-Next-Cursor 依次请求每一页，返回所有记录的 ID
+func walkPages(t *testing.T, r http.Handler, path string) []uint {
+	t.Helper()
+	var ids []uint
+	cursor := ""
+	for page := 0; ; page++ {
+		if page > 100 {
+			t.Fatal("翻页次数过多，游标可能没有前进")
+		}
+		url := path
+		if cursor != "" {
+			url += "&cursor=" + cursor
+		}
+		w := performRequest(r, http.MethodGet, url, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s 状态码 = %d, body=%s", url, w.Code, w.Body.String())
+		}
+		var items []struct {
+			ID uint `json:"id"`
+		}
+		decodeJSON(t, w, &items)
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		if cursor = w.Header().Get("X-Next-Cursor"); cursor == "" {
+			return ids
+		}
+	}
+}
+
+func TestPaginationWalksAllPagesWithTies(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+
+	var category models.Category
+	database.DB.Where("user_id = ?", user.ID).First(&category)
+
+	// 开始时间和时长都有大量相同的值
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	var pomodoros []models.Pomodoro
+	for i := 0; i < 23; i++ {
+		p := models.Pomodoro{
+			UserID: user.ID, CategoryID: category.ID, PlannedDuration: 1500,
+			Duration:  []int{1500, 300, 1500, 900}[i%4],
+			StartedAt: base.Add(time.Duration(i%5) * time.Hour),
+		}
+		database.DB.Create(&p)
+		pomodoros = append(pomodoros, p)
+	}
+	for i := 0; i < 9; i++ {
+		database.DB.Create(&models.WordRecord{
+			UserID: user.ID, Date: base.AddDate(0, 0, i).Format("2006-01-02"), WordCount: []int{50, 20, 50}[i%3],
+		})
+	}
+	var words []models.WordRecord
+	database.DB.Where("user_id = ?", user.ID).Find(&words)
+
+	r := gin.New()
+	r.Use(withUser(user.ID))
+	r.GET("/api/pomodoros", GetPomodoros)
+	r.GET("/api/words", GetWordRecords)
+
+	// expected 按排序值和 ID 计算完整的顺序
+	expected := func(n int, less func(i, j int) bool, id func(i int) uint) []uint {
+		idx := make([]int, n)
+		for i := range idx {
+			idx[i] = i
+		}
+		sort.Slice(idx, func(a, b int) bool { return less(idx[a], idx[b]) })
+		ids := make([]uint, n)
+		for i, k := range idx {
+			ids[i] = id(k)
+		}
+		return ids
+	}
+	byValue := func(cmp func(i, j int) int, id func(i int) uint, desc bool) func(i, j int) bool {
+		return func(i, j int) bool {
+			c := cmp(i, j)
+			if c == 0 {
+				c = int(id(i)) - int(id(j))
+			}
+			if desc {
+				return c > 0
+			}
+			return c < 0
+		}
+	}
+	pomodoroID := func(i int) uint { return pomodoros[i].ID }
+	byStart := func(i, j int) int { return pomodoros[i].StartedAt.Compare(pomodoros[j].StartedAt) }
+	byDuration := func(i, j int) int { return pomodoros[i].Duration - pomodoros[j].Duration }
+	wordID := func(i int) uint { return words[i].ID }
+	byWordCount := func(i, j int) int { return words[i].WordCount - words[j].WordCount }
+
+	tests := []struct {
+		path string
+		want []uint
+	}{
+		{"/api/pomodoros?sort=started_at", expected(len(pomodoros), byValue(byStart, pomodoroID, false), pomodoroID)},
+		{"/api/pomodoros?sort=-started_at", expected(len(pomodoros), byValue(byStart, pomodoroID, true), pomodoroID)},
+		{"/api/pomodoros?sort=duration", expected(len(pomodoros), byValue(byDuration, pomodoroID, false), pomodoroID)},
+		{"/api/pomodoros?sort=-duration", expected(len(pomodoros), byValue(byDuration, pomodoroID, true), pomodoroID)},
+		{"/api/words?sort=word_count", expected(len(words), byValue(byWordCount, wordID, false), wordID)},
+		{"/api/words?sort=-word_count", expected(len(words), byValue(byWordCount, wordID, true), wordID)},
+	}
+	for _, tt := range tests {
+		for _, limit := range []int{1, 2, 5, 7, 50} {
+			path := fmt.Sprintf("%s&limit=%d", tt.path, limit)
+			t.Run(path, func(t *testing.T) {
+				got := walkPages(t, r, path)
+				if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+					t.Errorf("翻页结果 = %v\nwant %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestPaginationRejectsCursorFromOtherSort(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+
+	var category models.Category
+	database.DB.Where("user_id = ?", user.ID).First(&category)
+	for i := 0; i < 3; i++ {
+		database.DB.Create(&models.Pomodoro{
+			UserID: user.ID, CategoryID: category.ID, Duration: 1500, PlannedDuration: 1500,
+			StartedAt: time.Now().Add(-time.Duration(i) * time.Hour),
+		})
+	}
+
+	r := gin.New()
+	r.Use(withUser(user.ID))
+	r.GET("/api/pomodoros", GetPomodoros)
+
+	w := performRequest(r, http.MethodGet, "/api/pomodoros?sort=duration&limit=1", nil)
+	cursor := w.Header().Get("X-Next-Cursor")
+	if cursor == "" {
+		t.Fatal("第一页应返回游标")
+	}
+
+	for _, path := range []string{
+		"/api/pomodoros?sort=-duration&cursor=" + cursor,
+		"/api/pomodoros?sort=started_at&cursor=" + cursor,
+		"/api/pomodoros?cursor=" + cursor, // 默认 -started_at
+		"/api/pomodoros?sort=duration&cursor=not-a-cursor",
+	} {
+		if w := performRequest(r, http.MethodGet, path, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s 状态码 = %d, want 400", path, w.Code)
+		}
+	}
+	if w := performRequest(r, http.MethodGet, "/api/pomodoros?sort=duration&limit=1&cursor="+cursor, nil); w.Code != http.StatusOK {
+		t.Errorf("相同排序方式使用游标的状态码 = %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,285 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PlanProgress 每日计划完成进度
+type PlanProgress struct {
+	Date               string  `json:"date"`
+	TargetPomodoros    int     `json:"target_pomodoros"`
+	CompletedPomodoros int64   `json:"completed_pomodoros"`
+	FocusDuration      int     `json:"focus_duration"` // 当天专注时长（秒）
+	PlannedTasks       int     `json:"planned_tasks"`
+	DoneTasks          int     `json:"done_tasks"`
+	Percentage         float64 `json:"percentage"` // 番茄数完成百分比
+	Reviewed           bool    `json:"reviewed"`
+}
+
+// DailyPlanResponse 某一天的计划、回顾和进度
+type DailyPlanResponse struct {
+	Date     string              `json:"date"`
+	Plan     *models.DailyPlan   `json:"plan"`
+	Review   *models.DailyReview `json:"review"`
+	Progress PlanProgress        `json:"progress"`
+}
+
+// parsePlanDate 校验日期参数，today 表示今天
+func parsePlanDate(c *gin.Context) (string, bool) {
+	date := c.Param("date")
+	if date == "today" {
+		return time.Now().Format("2006-01-02"), true
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "日期格式应为 YYYY-MM-DD"})
+		return "", false
+	}
+	return date, true
+}
+
+// loadUserTasks 按 ID 加载当前用户的任务，有不存在的任务时返回错误
+func loadUserTasks(userID uint, ids []uint) ([]models.Task, error) {
+	tasks := []models.Task{}
+	if len(ids) == 0 {
+		return tasks, nil
+	}
+	database.DB.Where("user_id = ? AND id IN ?", userID, ids).Find(&tasks)
+	if len(tasks) != len(ids) {
+		return nil, errors.New("任务不存在或有重复")
+	}
+	return tasks, nil
+}
+
+// planDayTotal 某一天已完成番茄钟的数量和时长
+type planDayTotal struct {
+	Date     string
+	Duration int
+	Count    int64
+}
+
+// loadPlanDayTotals 按天统计 from 到 to（含）已完成的番茄钟
+func loadPlanDayTotals(userID uint, from, to string) map[string]planDayTotal {
+	var rows []planDayTotal
+	database.DB.Model(&models.Pomodoro{}).
+		Select("DATE(started_at) as date, COALESCE(SUM(duration), 0) as duration, COUNT(*) as count").
+		Where("user_id = ? AND completed = ? AND DATE(started_at) BETWEEN ? AND ?", userID, true, from, to).
+		Group("DATE(started_at)").
+		Scan(&rows)
+
+	totals := make(map[string]planDayTotal, len(rows))
+	for _, row := range rows {
+		totals[row.Date] = row
+	}
+	return totals
+}
+
+// buildPlanProgress 计算某一天的计划进度
+func buildPlanProgress(date string, plan *models.DailyPlan, reviewed bool, day planDayTotal) PlanProgress {
+	progress := PlanProgress{
+		Date:               date,
+		Reviewed:           reviewed,
+		CompletedPomodoros: day.Count,
+		FocusDuration:      day.Duration,
+	}
+	if plan == nil {
+		return progress
+	}
+
+	progress.TargetPomodoros = plan.TargetPomodoros
+	progress.PlannedTasks = len(plan.Tasks)
+	for _, t := range plan.Tasks {
+		if t.Status == models.TaskStatusDone {
+			progress.DoneTasks++
+		}
+	}
+	if plan.TargetPomodoros > 0 {
+		progress.Percentage = float64(day.Count) / float64(plan.TargetPomodoros) * 100
+	}
+	return progress
+}
+
+// loadDailyPlan 加载某一天的计划和回顾，不存在时为 nil
+func loadDailyPlan(userID uint, date string) DailyPlanResponse {
+	response := DailyPlanResponse{Date: date}
+
+	var plan models.DailyPlan
+	if err := database.DB.Preload("Tasks").Where("user_id = ? AND date = ?", userID, date).First(&plan).Error; err == nil {
+		response.Plan = &plan
+	}
+
+	var review models.DailyReview
+	if err := database.DB.Preload("CompletedTasks").Where("user_id = ? AND date = ?", userID, date).First(&review).Error; err == nil {
+		response.Review = &review
+	}
+
+	response.Progress = buildPlanProgress(date, response.Plan, response.Review != nil, loadPlanDayTotals(userID, date, date)[date])
+	return response
+}
+
+// GetDailyPlan 获取某一天的计划、回顾和进度
+func GetDailyPlan(c *gin.Context) {
+	date, ok := parsePlanDate(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, loadDailyPlan(c.GetUint("user_id"), date))
+}
+
+// GetDailyPlans 按日期浏览计划历史，默认最近30天
+func GetDailyPlans(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	start, end, ok := parseDateRange(c, 30)
+	if !ok {
+		return
+	}
+	from, to := start.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02")
+
+	// 一次性加载范围内的计划、回顾和每天的番茄钟统计
+	var plans []models.DailyPlan
+	database.DB.Preload("Tasks").Where("user_id = ? AND date BETWEEN ? AND ?", userID, from, to).Find(&plans)
+	var reviews []models.DailyReview
+	database.DB.Preload("CompletedTasks").Where("user_id = ? AND date BETWEEN ? AND ?", userID, from, to).Find(&reviews)
+	totals := loadPlanDayTotals(userID, from, to)
+
+	// 有计划或回顾的日期
+	days := make(map[string]*DailyPlanResponse)
+	day := func(date string) *DailyPlanResponse {
+		if days[date] == nil {
+			days[date] = &DailyPlanResponse{Date: date}
+		}
+		return days[date]
+	}
+	for i := range plans {
+		day(plans[i].Date).Plan = &plans[i]
+	}
+	for i := range reviews {
+		day(reviews[i].Date).Review = &reviews[i]
+	}
+
+	history := make([]DailyPlanResponse, 0, len(days))
+	for date, response := range days {
+		response.Progress = buildPlanProgress(date, response.Plan, response.Review != nil, totals[date])
+		history = append(history, *response)
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].Date > history[j].Date })
+
+	c.JSON(http.StatusOK, history)
+}
+
+// SaveDailyPlan 创建或更新某一天的计划
+func SaveDailyPlan(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	date, ok := parsePlanDate(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		TargetPomodoros int    `json:"target_pomodoros" binding:"min=0,max=100"`
+		TaskIDs         []uint `json:"task_ids" binding:"max=50"`
+		Note            string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkNoteLength(c, input.Note) {
+		return
+	}
+
+	tasks, err := loadUserTasks(userID, input.TaskIDs)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var plan models.DailyPlan
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND date = ?", userID, date).First(&plan).Error; err != nil {
+			plan = models.DailyPlan{UserID: userID, Date: date}
+		}
+		plan.TargetPomodoros = input.TargetPomodoros
+		plan.Note = input.Note
+		if err := tx.Omit("Tasks").Save(&plan).Error; err != nil {
+			return err
+		}
+		return tx.Model(&plan).Association("Tasks").Replace(tasks)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, loadDailyPlan(userID, date))
+}
+
+// SaveDailyReview 创建或更新某一天的回顾
+// 未传 completed_task_ids 时，默认取当天计划中已完成的任务
+func SaveDailyReview(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	date, ok := parsePlanDate(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		FocusScore       int     `json:"focus_score" binding:"required,min=1,max=5"`
+		Reflection       string  `json:"reflection"`
+		CompletedTaskIDs *[]uint `json:"completed_task_ids"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkNoteLength(c, input.Reflection) {
+		return
+	}
+
+	var completed []models.Task
+	if input.CompletedTaskIDs != nil {
+		var err error
+		if completed, err = loadUserTasks(userID, *input.CompletedTaskIDs); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		completed = []models.Task{}
+		var plan models.DailyPlan
+		if err := database.DB.Preload("Tasks").Where("user_id = ? AND date = ?", userID, date).First(&plan).Error; err == nil {
+			for _, t := range plan.Tasks {
+				if t.Status == models.TaskStatusDone {
+					completed = append(completed, t)
+				}
+			}
+		}
+	}
+
+	var review models.DailyReview
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND date = ?", userID, date).First(&review).Error; err != nil {
+			review = models.DailyReview{UserID: userID, Date: date}
+		}
+		review.FocusScore = input.FocusScore
+		review.Reflection = input.Reflection
+		if err := tx.Omit("CompletedTasks").Save(&review).Error; err != nil {
+			return err
+		}
+		return tx.Model(&review).Association("CompletedTasks").Replace(completed)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, loadDailyPlan(userID, date))
+}
@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// planTestRouter 注册每日计划接口
+func planTestRouter(userID uint) *gin.Engine {
+	r := gin.New()
+	r.Use(withUser(userID))
+	r.GET("/api/plans", GetDailyPlans)
+	r.GET("/api/plans/:date", GetDailyPlan)
+	r.PUT("/api/plans/:date", SaveDailyPlan)
+	r.PUT("/api/plans/:date/review", SaveDailyReview)
+	return r
+}
+
+// createPlanTask 创建任务
+func createPlanTask(t *testing.T, userID uint, title, status string) models.Task {
+	t.Helper()
+	task := models.Task{UserID: userID, Title: title, Status: status, EstimatedPomodoros: 1}
+	if err := database.DB.Create(&task).Error; err != nil {
+		t.Fatalf("创建任务失败: %v", err)
+	}
+	return task
+}
+
+// createDayPomodoro 在某一天中午创建番茄钟
+func createDayPomodoro(userID, categoryID uint, date string, completed bool) {
+	day, _ := time.ParseInLocation("2006-01-02", date, time.Local)
+	start := day.Add(12 * time.Hour)
+	p := models.Pomodoro{UserID: userID, CategoryID: categoryID, PlannedDuration: 1500, StartedAt: start, Duration: 600}
+	if completed {
+		end := start.Add(25 * time.Minute)
+		p.Completed, p.CompletedAt, p.Duration = true, &end, 1500
+	}
+	database.DB.Create(&p)
+}
+
+func TestSaveDailyPlanAndReview(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+	other := createTestUser(t, "bob", "bob@example.com")
+	r := planTestRouter(user.ID)
+
+	done := createPlanTask(t, user.ID, "写周报", models.TaskStatusDone)
+	todo := createPlanTask(t, user.ID, "读论文", models.TaskStatusTodo)
+	othersTask := createPlanTask(t, other.ID, "别人的任务", models.TaskStatusTodo)
+
+	for _, ids := range [][]uint{{othersTask.ID}, {done.ID, done.ID}, {99999}} {
+		if w := performRequest(r, http.MethodPut, "/api/plans/2026-03-02", gin.H{"target_pomodoros": 4, "task_ids": ids}); w.Code != http.StatusBadRequest {
+			t.Errorf("任务 %v 状态码 = %d, want 400", ids, w.Code)
+		}
+	}
+	if w := performRequest(r, http.MethodPut, "/api/plans/2026-3-2", gin.H{"target_pomodoros": 4}); w.Code != http.StatusBadRequest {
+		t.Errorf("日期格式错误状态码 = %d, want 400", w.Code)
+	}
+	if w := performRequest(r, http.MethodPut, "/api/plans/2026-03-02", gin.H{"target_pomodoros": 101}); w.Code != http.StatusBadRequest {
+		t.Errorf("目标番茄数超出范围状态码 = %d, want 400", w.Code)
+	}
+
+	w := performRequest(r, http.MethodPut, "/api/plans/2026-03-02", gin.H{"target_pomodoros": 4, "task_ids": []uint{done.ID, todo.ID}, "note": "上午专注写作"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("保存计划状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+	var resp DailyPlanResponse
+	decodeJSON(t, w, &resp)
+	if resp.Plan == nil || len(resp.Plan.Tasks) != 2 || resp.Plan.Note != "上午专注写作" {
+		t.Fatalf("计划 = %+v", resp.Plan)
+	}
+
+	// 再次保存时替换任务，不新建计划
+	w = performRequest(r, http.MethodPut, "/api/plans/2026-03-02", gin.H{"target_pomodoros": 2, "task_ids": []uint{todo.ID}})
+	decodeJSON(t, w, &resp)
+	if len(resp.Plan.Tasks) != 1 || resp.Plan.Tasks[0].ID != todo.ID || resp.Plan.TargetPomodoros != 2 {
+		t.Errorf("更新后的计划 = %+v", resp.Plan)
+	}
+	var plans int64
+	database.DB.Model(&models.DailyPlan{}).Where("user_id = ?", user.ID).Count(&plans)
+	if plans != 1 {
+		t.Errorf("计划数 = %d, want 1", plans)
+	}
+
+	// 未传 completed_task_ids 时取计划中已完成的任务
+	performRequest(r, http.MethodPut, "/api/plans/2026-03-02", gin.H{"target_pomodoros": 2, "task_ids": []uint{done.ID, todo.ID}})
+	w = performRequest(r, http.MethodPut, "/api/plans/2026-03-02/review", gin.H{"focus_score": 4, "reflection": "下午有些分心"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("保存回顾状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+	decodeJSON(t, w, &resp)
+	if resp.Review == nil || resp.Review.FocusScore != 4 || len(resp.Review.CompletedTasks) != 1 || resp.Review.CompletedTasks[0].ID != done.ID {
+		t.Errorf("回顾 = %+v", resp.Review)
+	}
+	if !resp.Progress.Reviewed || resp.Progress.PlannedTasks != 2 || resp.Progress.DoneTasks != 1 {
+		t.Errorf("进度 = %+v", resp.Progress)
+	}
+
+	w = performRequest(r, http.MethodPut, "/api/plans/2026-03-02/review", gin.H{"focus_score": 3, "completed_task_ids": []uint{}})
+	decodeJSON(t, w, &resp)
+	if resp.Review.FocusScore != 3 || len(resp.Review.CompletedTasks) != 0 {
+		t.Errorf("显式传入空列表后的回顾 = %+v", resp.Review)
+	}
+	for _, body := range []gin.H{{"focus_score": 0}, {"focus_score": 6}, {"focus_score": 3, "completed_task_ids": []uint{othersTask.ID}}} {
+		if w := performRequest(r, http.MethodPut, "/api/plans/2026-03-02/review", body); w.Code != http.StatusBadRequest {
+			t.Errorf("回顾 %v 状态码 = %d, want 400", body, w.Code)
+		}
+	}
+}
+
+func TestDailyPlanProgress(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+	r := planTestRouter(user.ID)
+
+	var category models.Category
+	database.DB.Where("user_id = ?", user.ID).First(&category)
+	createDayPomodoro(user.ID, category.ID, "2026-03-02", true)
+	createDayPomodoro(user.ID, category.ID, "2026-03-02", true)
+	createDayPomodoro(user.ID, category.ID, "2026-03-02", true)
+	createDayPomodoro(user.ID, category.ID, "2026-03-02", false) // 放弃的不计入
+	createDayPomodoro(user.ID, category.ID, "2026-03-03", true)
+
+	// 没有计划时也返回当天的番茄钟统计
+	var resp DailyPlanResponse
+	decodeJSON(t, performRequest(r, http.MethodGet, "/api/plans/2026-03-02", nil), &resp)
+	if resp.Plan != nil || resp.Progress.CompletedPomodoros != 3 || resp.Progress.FocusDuration != 4500 || resp.Progress.Percentage != 0 {
+		t.Errorf("没有计划时的进度 = %+v", resp.Progress)
+	}
+
+	performRequest(r, http.MethodPut, "/api/plans/2026-03-02", gin.H{"target_pomodoros": 4})
+	decodeJSON(t, performRequest(r, http.MethodGet, "/api/plans/2026-03-02", nil), &resp)
+	want := PlanProgress{Date: "2026-03-02", TargetPomodoros: 4, CompletedPomodoros: 3, FocusDuration: 4500, Percentage: 75}
+	if resp.Progress != want {
+		t.Errorf("进度 = %+v, want %+v", resp.Progress, want)
+	}
+}
+
+func TestGetDailyPlansLoadsRangeInBatch(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+	other := createTestUser(t, "bob", "bob@example.com")
+	r := planTestRouter(user.ID)
+
+	var category models.Category
+	database.DB.Where("user_id = ?", user.ID).First(&category)
+	done := createPlanTask(t, user.ID, "写周报", models.TaskStatusDone)
+
+	// 3 日只有计划，4 日有计划和回顾，5 日只有回顾，范围外和其他用户的不返回
+	performRequest(r, http.MethodPut, "/api/plans/2026-03-03", gin.H{"target_pomodoros": 2, "task_ids": []uint{done.ID}})
+	performRequest(r, http.MethodPut, "/api/plans/2026-03-04", gin.H{"target_pomodoros": 1, "task_ids": []uint{done.ID}})
+	performRequest(r, http.MethodPut, "/api/plans/2026-03-04/review", gin.H{"focus_score": 5})
+	performRequest(r, http.MethodPut, "/api/plans/2026-03-05/review", gin.H{"focus_score": 2})
+	performRequest(r, http.MethodPut, "/api/plans/2026-02-20", gin.H{"target_pomodoros": 1})
+	performRequest(planTestRouter(other.ID), http.MethodPut, "/api/plans/2026-03-04", gin.H{"target_pomodoros": 9})
+	createDayPomodoro(user.ID, category.ID, "2026-03-03", true)
+	createDayPomodoro(user.ID, category.ID, "2026-03-04", true)
+	createDayPomodoro(user.ID, category.ID, "2026-03-04", true)
+
+	// 统计查询次数，应与范围内的天数无关
+	queries := 0
+	countQuery := func(*gorm.DB) { queries++ }
+	if err := database.DB.Callback().Query().Before("gorm:query").Register("test:count_queries", countQuery); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.DB.Callback().Row().Before("gorm:row").Register("test:count_rows", countQuery); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		database.DB.Callback().Query().Remove("test:count_queries")
+		database.DB.Callback().Row().Remove("test:count_rows")
+	})
+
+	// 4 日的计划和回顾都带任务，单独查询时各类查询都会执行
+	performRequest(r, http.MethodGet, "/api/plans?from=2026-03-04&to=2026-03-04", nil)
+	singleDay := queries
+	queries = 0
+
+	w := performRequest(r, http.MethodGet, "/api/plans?from=2026-03-01&to=2026-03-10", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+	var history []DailyPlanResponse
+	decodeJSON(t, w, &history)
+
+	if len(history) != 3 || history[0].Date != "2026-03-05" || history[1].Date != "2026-03-04" || history[2].Date != "2026-03-03" {
+		t.Fatalf("历史 = %+v", history)
+	}
+	if h := history[0]; h.Plan != nil || h.Review == nil || !h.Progress.Reviewed {
+		t.Errorf("5 日 = %+v", h)
+	}
+	if h := history[1]; h.Plan == nil || h.Plan.TargetPomodoros != 1 || h.Review == nil || len(h.Review.CompletedTasks) != 1 || h.Progress.CompletedPomodoros != 2 || h.Progress.Percentage != 200 {
+		t.Errorf("4 日 = %+v", h)
+	}
+	if h := history[2]; h.Plan == nil || len(h.Plan.Tasks) != 1 || h.Review != nil || h.Progress.DoneTasks != 1 || h.Progress.CompletedPomodoros != 1 {
+		t.Errorf("3 日 = %+v", h)
+	}
+	if queries != singleDay {
+		t.Errorf("加载 %d 天的计划执行了 %d 次查询, 加载 1 天时为 %d 次", len(history), queries, singleDay)
+	}
+}
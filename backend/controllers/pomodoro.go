@@ -2,6 +2,7 @@
 
   import (
         "net/http"
+        "strings"
         "time"
 
         "pomodoro-api/database"
@@ -15,9 +16,11 @@
         userID := c.GetUint("user_id")
 
         var input struct {
-                CategoryID      uint   `json:"category_id" binding:"required"`
-                PlannedDuration int    `json:"planned_duration"` // 可选，默认用设置中的时长
-                Note            string `json:"note"`
+                CategoryID      uint     `json:"category_id"`      // 关联任务有分类时可省略
+                TaskID          *uint    `json:"task_id"`          // 可选，关联的任务
+                PlannedDuration int      `json:"planned_duration"` // 可选，默认用设置中的时长
+                Note            string   `json:"note"`
+                Tags            []string `json:"tags"` // 标签名，不存在时自动创建
         }
 
         if err := c.ShouldBindJSON(&input); err != nil {
@@ -25,10 +28,41 @@
                 return
         }
 
-        // 验证分类是否属于当前用户
-        var category models.Category
-        if err := database.DB.Where("id = ? AND user_id = ?", input.CategoryID, userID).First(&category).Error; err != nil {
-                c.JSON(http.StatusBadRequest, gin.H{"error": "分类不存在"})
+        if !checkNoteLength(c, input.Note) {
+                return
+        }
+        if input.PlannedDuration < 0 || input.PlannedDuration > maxPlannedDuration {
+                rejectLimit(c, "计划时长", input.PlannedDuration, maxPlannedDuration)
+                return
+        }
+
+        // 验证任务是否属于当前用户
+        var task *models.Task
+        if input.TaskID != nil {
+                var ok bool
+                if task, ok = findUserTask(c, *input.TaskID); !ok {
+                        return
+                }
+                if task.Status == models.TaskStatusDone || task.Status == models.TaskStatusCancelled {
+                        c.JSON(http.StatusBadRequest, gin.H{"error": "任务已完成或已取消"})
+                        return
+                }
+                if input.CategoryID == 0 && task.CategoryID != nil {
+                        input.CategoryID = *task.CategoryID
+                }
+        }
+        if input.CategoryID == 0 {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "请选择分类"})
+                return
+        }
+
+        if !checkPomodoroCategory(c, userID, input.CategoryID) {
+                return
+        }
+
+        tags, err := resolveTags(userID, input.Tags)
+        if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
                 return
         }
 
@@ -43,11 +77,14 @@
         pomodoro := models.Pomodoro{
                 UserID:          userID,
                 CategoryID:      input.CategoryID,
+                TaskID:          input.TaskID,
                 PlannedDuration: plannedDuration,
                 Duration:        0,
                 Completed:       false,
                 StartedAt:       time.Now(),
                 Note:            input.Note,
+                Source:          models.PomodoroSourceTimer,
+                Tags:            tags,
         }
 
         if err := database.DB.Create(&pomodoro).Error; err != nil {
@@ -55,13 +92,19 @@
                 return
         }
 
-        // 加载分类信息
-        database.DB.Preload("Category").First(&pomodoro, pomodoro.ID)
+        // 开始第一个番茄钟时任务自动进入进行中
+        if task != nil && task.Status == models.TaskStatusTodo {
+                database.DB.Model(task).Update("status", models.TaskStatusInProgress)
+        }
+
+        // 加载分类和标签信息
+        database.DB.Preload("Category").Preload("Tags").First(&pomodoro, pomodoro.ID)
 
         c.JSON(http.StatusOK, pomodoro)
   }
 
-  // CompletePomodoro 完成/取消番茄钟
+  // CompletePomodoro 完成/取消进行中的番茄钟
+  // 已结束的番茄钟（包括补录和导入的）不能再次结束，修改时间请使用 PATCH
   func CompletePomodoro(c *gin.Context) {
         userID := c.GetUint("user_id")
         pomodoroID := c.Param("id")
@@ -71,9 +114,15 @@
                 c.JSON(http.StatusNotFound, gin.H{"error": "番茄钟不存在"})
                 return
         }
+        if pomodoro.CompletedAt != nil {
+                c.JSON(http.StatusConflict, gin.H{"error": "番茄钟已结束"})
+                return
+        }
 
         var input struct {
-                Completed bool `json:"completed"`
+                pomodoroRating           // 可选，专注评分、精力和心情
+                Completed      bool      `json:"completed"`
+                Tags           *[]string `json:"tags"` // 不传表示不修改，传入时替换原有标签
         }
 
         if err := c.ShouldBindJSON(&input); err != nil {
@@ -81,9 +130,24 @@
                 return
         }
 
+        // 放弃的番茄钟不计入统计，不需要检查重叠
         now := time.Now()
+        if input.Completed && !checkPomodoroOverlap(c, userID, pomodoro.StartedAt, now, pomodoro.ID) {
+                return
+        }
+
+        if input.Tags != nil {
+                tags, err := resolveTags(userID, *input.Tags)
+                if err != nil {
+                        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                        return
+                }
+                database.DB.Model(&pomodoro).Association("Tags").Replace(tags)
+        }
+
         pomodoro.Completed = input.Completed
         pomodoro.CompletedAt = &now
+        input.pomodoroRating.apply(&pomodoro)
 
         // 计算实际时长
         duration := int(now.Sub(pomodoro.StartedAt).Seconds())
@@ -91,31 +155,96 @@
 
         database.DB.Save(&pomodoro)
 
-        // 加载分类信息
-        database.DB.Preload("Category").First(&pomodoro, pomodoro.ID)
+        // 加载分类和标签信息
+        database.DB.Preload("Category").Preload("Tags").First(&pomodoro, pomodoro.ID)
 
         c.JSON(http.StatusOK, pomodoro)
   }
 
-  // GetPomodoros 获取番茄钟历史
+  // pomodoroSortColumns 番茄钟历史支持的排序字段
+  var pomodoroSortColumns = map[string]sortKind{
+        "started_at": sortTime,
+        "created_at": sortTime,
+        "duration":   sortInt,
+  }
+
+  // GetPomodoros 获取番茄钟历史，使用 cursor 翻页
+  // 总数和下一页游标通过 X-Total-Count、X-Next-Cursor 响应头返回
   func GetPomodoros(c *gin.Context) {
         userID := c.GetUint("user_id")
 
         // 查询参数
         categoryID := c.Query("category_id")
         completed := c.Query("completed")
+        tag := strings.TrimSpace(c.Query("tag"))
+        taskID := c.Query("task_id")
+        source := c.Query("source")
+        keyword := strings.TrimSpace(c.Query("q"))
 
-        var pomodoros []models.Pomodoro
-        query := database.DB.Where("user_id = ?", userID).Preload("Category")
+        sort, ok := parseListSort(c, "-started_at", pomodoroSortColumns)
+        if !ok {
+                return
+        }
+        limit := parseListLimit(c)
+
+        query := database.DB.Model(&models.Pomodoro{}).Where("user_id = ?", userID)
 
+        if c.Query("from") != "" || c.Query("to") != "" {
+                start, end, ok := parseDateRange(c, maxDateRangeDays)
+                if !ok {
+                        return
+                }
+                query = query.Where("started_at >= ? AND started_at < ?", start, end)
+        }
         if categoryID != "" {
                 query = query.Where("category_id = ?", categoryID)
         }
         if completed != "" {
                 query = query.Where("completed = ?", completed)
         }
+        if tag != "" {
+                query = query.Where("id IN (?)", database.DB.Table("pomodoro_tags").
+                        Select("pomodoro_tags.pomodoro_id").
+                        Joins("JOIN tags ON tags.id = pomodoro_tags.tag_id").
+                        Where("tags.user_id = ? AND LOWER(tags.name) = ?", userID, strings.ToLower(tag)))
+        }
+        if taskID != "" {
+                query = query.Where("task_id = ?", taskID)
+        }
+        if source != "" {
+                query = query.Where("source = ?", source)
+        }
+        if keyword != "" {
+                query = query.Where("note LIKE ? ESCAPE '\\'", "%"+escapeLike(keyword)+"%")
+        }
 
-        query.Order("created_at DESC").Limit(50).Find(&pomodoros)
+        var total int64
+        query.Count(&total)
+
+        query, ok = paginate(c, query, sort, limit)
+        if !ok {
+                return
+        }
+
+        var pomodoros []models.Pomodoro
+        query.Preload("Category").Preload("Tags").Find(&pomodoros)
+
+        next := ""
+        if len(pomodoros) > limit {
+                pomodoros = pomodoros[:limit]
+                last := pomodoros[limit-1]
+                var value interface{}
+                switch sort.column {
+                case "started_at":
+                        value = last.StartedAt
+                case "created_at":
+                        value = last.CreatedAt
+                default:
+                        value = last.Duration
+                }
+                next = encodeCursor(sort, value, last.ID)
+        }
+        setPageHeaders(c, total, next)
 
         c.JSON(http.StatusOK, pomodoros)
   }
\ No newline at end of file
@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkPomodoroCategory 校验分类属于当前用户且未归档，失败时写入响应
+func checkPomodoroCategory(c *gin.Context, userID, categoryID uint) bool {
+	var category models.Category
+	if err := database.DB.Where("id = ? AND user_id = ?", categoryID, userID).First(&category).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "分类不存在"})
+		return false
+	}
+	if category.Archived {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "分类已归档"})
+		return false
+	}
+	return true
+}
+
+// checkPomodoroTimes 校验手动填写的起止时间，失败时写入响应
+func checkPomodoroTimes(c *gin.Context, start, end time.Time) bool {
+	if !end.After(start) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "结束时间必须晚于开始时间"})
+		return false
+	}
+	if end.After(time.Now().Add(time.Minute)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不能记录未来的番茄钟"})
+		return false
+	}
+	if seconds := int(end.Sub(start).Seconds()); seconds > maxPlannedDuration {
+		rejectLimit(c, "番茄钟时长", seconds, maxPlannedDuration)
+		return false
+	}
+	return true
+}
+
+// checkPomodoroOverlap 检查与用户其他已完成的番茄钟是否时间重叠，重叠时写入响应
+// 进行中和已放弃的番茄钟不参与检查
+func checkPomodoroOverlap(c *gin.Context, userID uint, start, end time.Time, excludeID uint) bool {
+	var conflict models.Pomodoro
+	err := database.DB.Where("user_id = ? AND id <> ? AND completed = ? AND started_at < ? AND completed_at > ?",
+		userID, excludeID, true, end, start).First(&conflict).Error
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":       "与已有番茄钟时间重叠",
+			"conflict_id": conflict.ID,
+			"started_at":  conflict.StartedAt,
+			"ended_at":    conflict.CompletedAt,
+		})
+		return false
+	}
+	return true
+}
+
+// CreateManualPomodoro 手动补录一个已完成的番茄钟
+func CreateManualPomodoro(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		pomodoroRating
+		CategoryID uint      `json:"category_id"` // 关联任务有分类时可省略
+		TaskID     *uint     `json:"task_id"`
+		StartedAt  time.Time `json:"started_at" binding:"required"`
+		EndedAt    time.Time `json:"ended_at" binding:"required"`
+		Note       string    `json:"note"`
+		Tags       []string  `json:"tags"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	start, end := input.StartedAt.Local(), input.EndedAt.Local()
+	if !checkNoteLength(c, input.Note) || !checkPomodoroTimes(c, start, end) {
+		return
+	}
+
+	if input.TaskID != nil {
+		task, ok := findUserTask(c, *input.TaskID)
+		if !ok {
+			return
+		}
+		if input.CategoryID == 0 && task.CategoryID != nil {
+			input.CategoryID = *task.CategoryID
+		}
+	}
+	if input.CategoryID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请选择分类"})
+		return
+	}
+	if !checkPomodoroCategory(c, userID, input.CategoryID) || !checkPomodoroOverlap(c, userID, start, end, 0) {
+		return
+	}
+
+	tags, err := resolveTags(userID, input.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	duration := int(end.Sub(start).Seconds())
+	pomodoro := models.Pomodoro{
+		UserID:          userID,
+		CategoryID:      input.CategoryID,
+		TaskID:          input.TaskID,
+		Duration:        duration,
+		PlannedDuration: duration,
+		Completed:       true,
+		StartedAt:       start,
+		CompletedAt:     &end,
+		Note:            input.Note,
+		Source:          models.PomodoroSourceManual,
+		Tags:            tags,
+	}
+	input.pomodoroRating.apply(&pomodoro)
+
+	if err := database.DB.Create(&pomodoro).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败"})
+		return
+	}
+	recordChange(c, "pomodoro.create_manual", "pomodoro", pomodoro.ID, userID, nil, pomodoro)
+
+	database.DB.Preload("Category").Preload("Tags").First(&pomodoro, pomodoro.ID)
+
+	c.JSON(http.StatusOK, pomodoro)
+}
+
+// UpdatePomodoro 修改已结束番茄钟的备注、分类或起止时间
+// 修改计时器记录的时间后，来源变为 manual
+func UpdatePomodoro(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	pomodoro, ok := findUserPomodoro(c)
+	if !ok {
+		return
+	}
+	if pomodoro.CompletedAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "番茄钟尚未结束"})
+		return
+	}
+
+	var input struct {
+		Note       *string    `json:"note"`
+		CategoryID *uint      `json:"category_id"`
+		StartedAt  *time.Time `json:"started_at"`
+		EndedAt    *time.Time `json:"ended_at"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := *pomodoro
+
+	if input.Note != nil {
+		if !checkNoteLength(c, *input.Note) {
+			return
+		}
+		pomodoro.Note = *input.Note
+	}
+
+	if input.CategoryID != nil && *input.CategoryID != pomodoro.CategoryID {
+		if !checkPomodoroCategory(c, userID, *input.CategoryID) {
+			return
+		}
+		pomodoro.CategoryID = *input.CategoryID
+	}
+
+	if input.StartedAt != nil || input.EndedAt != nil {
+		start, end := pomodoro.StartedAt, *pomodoro.CompletedAt
+		if input.StartedAt != nil {
+			start = input.StartedAt.Local()
+		}
+		if input.EndedAt != nil {
+			end = input.EndedAt.Local()
+		}
+		if !checkPomodoroTimes(c, start, end) || !checkPomodoroOverlap(c, userID, start, end, pomodoro.ID) {
+			return
+		}
+		pomodoro.StartedAt = start
+		pomodoro.CompletedAt = &end
+		pomodoro.Duration = int(end.Sub(start).Seconds())
+		if pomodoro.Source == models.PomodoroSourceTimer {
+			pomodoro.Source = models.PomodoroSourceManual
+		}
+	}
+
+	database.DB.Model(pomodoro).
+		Select("note", "category_id", "started_at", "completed_at", "duration", "source").
+		Updates(pomodoro)
+	recordChange(c, "pomodoro.update", "pomodoro", pomodoro.ID, userID, before, *pomodoro)
+
+	database.DB.Preload("Category").Preload("Tags").First(pomodoro, pomodoro.ID)
+
+	c.JSON(http.StatusOK, pomodoro)
+}
+
+// DeletePomodoro 删除番茄钟及其打断记录
+func DeletePomodoro(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	pomodoro, ok := findUserPomodoro(c)
+	if !ok {
+		return
+	}
+
+	database.DB.Where("pomodoro_id = ?", pomodoro.ID).Delete(&models.Interruption{})
+	database.DB.Delete(pomodoro)
+	recordChange(c, "pomodoro.delete", "pomodoro", pomodoro.ID, userID, *pomodoro, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
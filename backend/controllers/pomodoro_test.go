@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCompletePomodoroRejectsEndedPomodoros(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+
+	r := gin.New()
+	r.Use(withUser(user.ID))
+	r.PUT("/api/pomodoros/:id", CompletePomodoro)
+
+	var category models.Category
+	database.DB.Where("user_id = ?", user.ID).First(&category)
+	newPomodoro := func(start time.Time, end *time.Time, source string) models.Pomodoro {
+		p := models.Pomodoro{
+			UserID:          user.ID,
+			CategoryID:      category.ID,
+			PlannedDuration: 1500,
+			StartedAt:       start,
+			CompletedAt:     end,
+			Source:          source,
+		}
+		if end != nil {
+			p.Completed = true
+			p.Duration = int(end.Sub(start).Seconds())
+		}
+		database.DB.Create(&p)
+		return p
+	}
+	complete := func(id uint) int {
+		return performRequest(r, http.MethodPut, fmt.Sprintf("/api/pomodoros/%d", id), gin.H{"completed": true}).Code
+	}
+
+	now := time.Now()
+
+	// 上个月补录的番茄钟不能再通过完成接口改写时长
+	manualStart := now.AddDate(0, -1, 0)
+	manualEnd := manualStart.Add(25 * time.Minute)
+	manual := newPomodoro(manualStart, &manualEnd, models.PomodoroSourceManual)
+	if code := complete(manual.ID); code != http.StatusConflict {
+		t.Errorf("完成补录的番茄钟状态码 = %d, 应为 409", code)
+	}
+	database.DB.First(&manual, manual.ID)
+	if manual.Duration != 1500 || !manual.CompletedAt.Equal(manualEnd) {
+		t.Errorf("补录的番茄钟被修改: duration=%d completed_at=%v", manual.Duration, manual.CompletedAt)
+	}
+
+	// 进行中的番茄钟只能完成一次
+	running := newPomodoro(now.Add(-5*time.Minute), nil, models.PomodoroSourceTimer)
+	if code := complete(running.ID); code != http.StatusOK {
+		t.Fatalf("完成进行中的番茄钟状态码 = %d, 应为 200", code)
+	}
+	database.DB.First(&running, running.ID)
+	duration := running.Duration
+	if code := complete(running.ID); code != http.StatusConflict {
+		t.Errorf("重复完成状态码 = %d, 应为 409", code)
+	}
+	database.DB.First(&running, running.ID)
+	if running.Duration != duration {
+		t.Errorf("重复完成修改了时长: %d -> %d", duration, running.Duration)
+	}
+}
+
+func TestCompletePomodoroChecksOverlap(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+
+	r := gin.New()
+	r.Use(withUser(user.ID))
+	r.PUT("/api/pomodoros/:id", CompletePomodoro)
+
+	var category models.Category
+	database.DB.Where("user_id = ?", user.ID).First(&category)
+	now := time.Now()
+
+	running := models.Pomodoro{UserID: user.ID, CategoryID: category.ID, PlannedDuration: 1500, StartedAt: now.Add(-30 * time.Minute)}
+	database.DB.Create(&running)
+
+	// 计时期间补录了另一个番茄钟
+	manualEnd := now.Add(-10 * time.Minute)
+	manual := models.Pomodoro{
+		UserID: user.ID, CategoryID: category.ID, PlannedDuration: 600, Duration: 600, Completed: true,
+		StartedAt: now.Add(-20 * time.Minute), CompletedAt: &manualEnd, Source: models.PomodoroSourceManual,
+	}
+	database.DB.Create(&manual)
+
+	w := performRequest(r, http.MethodPut, fmt.Sprintf("/api/pomodoros/%d", running.ID), gin.H{"completed": true})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("时间重叠时状态码 = %d, 应为 409, body=%s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		ConflictID uint `json:"conflict_id"`
+	}
+	decodeJSON(t, w, &resp)
+	if resp.ConflictID != manual.ID {
+		t.Errorf("conflict_id = %d, want %d", resp.ConflictID, manual.ID)
+	}
+
+	database.DB.First(&running, running.ID)
+	if running.CompletedAt != nil {
+		t.Errorf("时间重叠时不应结束番茄钟")
+	}
+}
+
+func TestCancelOverlappingPomodoro(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+
+	r := gin.New()
+	r.Use(withUser(user.ID))
+	r.PUT("/api/pomodoros/:id", CompletePomodoro)
+
+	var category models.Category
+	database.DB.Where("user_id = ?", user.ID).First(&category)
+	now := time.Now()
+
+	running := models.Pomodoro{UserID: user.ID, CategoryID: category.ID, PlannedDuration: 1500, StartedAt: now.Add(-30 * time.Minute)}
+	database.DB.Create(&running)
+	manualEnd := now.Add(-10 * time.Minute)
+	database.DB.Create(&models.Pomodoro{
+		UserID: user.ID, CategoryID: category.ID, PlannedDuration: 600, Duration: 600, Completed: true,
+		StartedAt: now.Add(-20 * time.Minute), CompletedAt: &manualEnd, Source: models.PomodoroSourceManual,
+	})
+
+	// 与补录记录重叠的计时仍然可以放弃
+	w := performRequest(r, http.MethodPut, fmt.Sprintf("/api/pomodoros/%d", running.ID), gin.H{"completed": false})
+	if w.Code != http.StatusOK {
+		t.Fatalf("放弃重叠的番茄钟状态码 = %d, 应为 200, body=%s", w.Code, w.Body.String())
+	}
+	database.DB.First(&running, running.ID)
+	if running.Completed || running.CompletedAt == nil {
+		t.Errorf("番茄钟应标记为已放弃: completed=%v completed_at=%v", running.Completed, running.CompletedAt)
+	}
+}
+
+func TestAbandonedPomodoroDoesNotBlockManualEntry(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+
+	r := gin.New()
+	r.Use(withUser(user.ID))
+	r.POST("/api/pomodoros/manual", CreateManualPomodoro)
+
+	var category models.Category
+	database.DB.Where("user_id = ?", user.ID).First(&category)
+	start := time.Now().Add(-2 * time.Hour).Truncate(time.Minute)
+
+	// 中途放弃的计时
+	abandonedEnd := start.Add(10 * time.Minute)
+	database.DB.Create(&models.Pomodoro{
+		UserID: user.ID, CategoryID: category.ID, PlannedDuration: 1500, Duration: 600,
+		StartedAt: start, CompletedAt: &abandonedEnd, Source: models.PomodoroSourceTimer,
+	})
+
+	w := performRequest(r, http.MethodPost, "/api/pomodoros/manual", gin.H{
+		"category_id": category.ID,
+		"started_at":  start.Format(time.RFC3339),
+		"ended_at":    start.Add(25 * time.Minute).Format(time.RFC3339),
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("在放弃的番茄钟时间段补录状态码 = %d, 应为 200, body=%s", w.Code, w.Body.String())
+	}
+
+	// 与已完成的番茄钟重叠时仍然拒绝
+	w = performRequest(r, http.MethodPost, "/api/pomodoros/manual", gin.H{
+		"category_id": category.ID,
+		"started_at":  start.Add(5 * time.Minute).Format(time.RFC3339),
+		"ended_at":    start.Add(20 * time.Minute).Format(time.RFC3339),
+	})
+	if w.Code != http.StatusConflict {
+		t.Errorf("与已完成的番茄钟重叠时状态码 = %d, 应为 409", w.Code)
+	}
+}
@@ -0,0 +1,240 @@
+package controllers
+
+import (
+	"math"
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pomodoroRating 番茄钟结束后的自评，不传的字段保持不变
+type pomodoroRating struct {
+	FocusScore *int `json:"focus_score" binding:"omitempty,min=1,max=5"`
+	Energy     *int `json:"energy" binding:"omitempty,min=1,max=5"`
+	Mood       *int `json:"mood" binding:"omitempty,min=1,max=5"`
+}
+
+// apply 把评分写入番茄钟
+func (r pomodoroRating) apply(pomodoro *models.Pomodoro) {
+	if r.FocusScore != nil {
+		pomodoro.FocusScore = r.FocusScore
+	}
+	if r.Energy != nil {
+		pomodoro.Energy = r.Energy
+	}
+	if r.Mood != nil {
+		pomodoro.Mood = r.Mood
+	}
+}
+
+// RatePomodoro 修改已结束番茄钟的专注评分、精力、心情和标签
+func RatePomodoro(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	pomodoro, ok := findUserPomodoro(c)
+	if !ok {
+		return
+	}
+	if pomodoro.CompletedAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "番茄钟尚未结束"})
+		return
+	}
+
+	var input struct {
+		pomodoroRating
+		Tags *[]string `json:"tags"` // 不传表示不修改，传入时替换原有标签
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Tags != nil {
+		tags, err := resolveTags(userID, *input.Tags)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		database.DB.Model(pomodoro).Association("Tags").Replace(tags)
+	}
+
+	input.pomodoroRating.apply(pomodoro)
+	database.DB.Model(pomodoro).Select("focus_score", "energy", "mood").Updates(pomodoro)
+
+	database.DB.Preload("Category").Preload("Tags").First(pomodoro, pomodoro.ID)
+
+	c.JSON(http.StatusOK, pomodoro)
+}
+
+// FocusBucket 某个维度上的平均评分
+type FocusBucket struct {
+	Key       int      `json:"key"`            // 分类 ID、小时（0-23）或时长分段序号
+	Name      string   `json:"name,omitempty"` // 分类名称或时长分段
+	Count     int      `json:"count"`          // 已评分的番茄钟数量
+	AvgFocus  float64  `json:"avg_focus"`      // 平均专注评分
+	AvgEnergy *float64 `json:"avg_energy"`     // 平均精力，没有数据时为 null
+	AvgMood   *float64 `json:"avg_mood"`       // 平均心情，没有数据时为 null
+	energy    ratingSum
+	mood      ratingSum
+}
+
+// ratingSum 评分累加器
+type ratingSum struct {
+	sum   int
+	count int
+}
+
+func (s *ratingSum) add(v *int) {
+	if v != nil {
+		s.sum += *v
+		s.count++
+	}
+}
+
+func (s ratingSum) avg() *float64 {
+	if s.count == 0 {
+		return nil
+	}
+	avg := float64(s.sum) / float64(s.count)
+	return &avg
+}
+
+func (b *FocusBucket) add(p models.Pomodoro) {
+	b.AvgFocus = (b.AvgFocus*float64(b.Count) + float64(*p.FocusScore)) / float64(b.Count+1)
+	b.Count++
+	b.energy.add(p.Energy)
+	b.mood.add(p.Mood)
+	b.AvgEnergy = b.energy.avg()
+	b.AvgMood = b.mood.avg()
+}
+
+// sessionLengths 番茄钟时长分段（分钟，上限不含）
+var sessionLengths = []struct {
+	name  string
+	upper int
+}{
+	{"<15", 15},
+	{"15-25", 25},
+	{"25-35", 35},
+	{"35-50", 50},
+	{"50+", math.MaxInt},
+}
+
+// sessionLengthIndex 返回时长所在的分段
+func sessionLengthIndex(seconds int) int {
+	for i, l := range sessionLengths {
+		if seconds/60 < l.upper {
+			return i
+		}
+	}
+	return len(sessionLengths) - 1
+}
+
+// pearson 计算相关系数，样本不足或方差为 0 时返回 nil
+func pearson(xs, ys []float64) *float64 {
+	n := float64(len(xs))
+	if len(xs) < 3 {
+		return nil
+	}
+	var sx, sy, sxx, syy, sxy float64
+	for i := range xs {
+		sx += xs[i]
+		sy += ys[i]
+		sxx += xs[i] * xs[i]
+		syy += ys[i] * ys[i]
+		sxy += xs[i] * ys[i]
+	}
+	den := math.Sqrt(n*sxx-sx*sx) * math.Sqrt(n*syy-sy*sy)
+	if den == 0 {
+		return nil
+	}
+	r := (n*sxy - sx*sy) / den
+	return &r
+}
+
+// GetFocusStats 专注评分与时段、时长和分类的关系，默认最近90天
+func GetFocusStats(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	start, end, ok := parseDateRange(c, 90)
+	if !ok {
+		return
+	}
+
+	var pomodoros []models.Pomodoro
+	database.DB.Where("user_id = ? AND focus_score IS NOT NULL AND started_at >= ? AND started_at < ?", userID, start, end).
+		Find(&pomodoros)
+
+	var categories []models.Category
+	database.DB.Unscoped().Where("user_id = ?", userID).Find(&categories)
+	categoryNames := make(map[uint]string, len(categories))
+	for _, cat := range categories {
+		categoryNames[cat.ID] = cat.Name
+	}
+
+	var overall FocusBucket
+	byHour := make([]FocusBucket, 24)
+	for i := range byHour {
+		byHour[i].Key = i
+	}
+	byLength := make([]FocusBucket, len(sessionLengths))
+	for i := range byLength {
+		byLength[i].Key = i
+		byLength[i].Name = sessionLengths[i].name
+	}
+	byCategory := make(map[uint]*FocusBucket)
+
+	// 相关系数的样本
+	var focus, minutes []float64
+	var energyFocus, energy, moodFocus, mood []float64
+
+	for _, p := range pomodoros {
+		overall.add(p)
+		byHour[p.StartedAt.Local().Hour()].add(p)
+		byLength[sessionLengthIndex(p.Duration)].add(p)
+		b, ok := byCategory[p.CategoryID]
+		if !ok {
+			b = &FocusBucket{Key: int(p.CategoryID), Name: categoryNames[p.CategoryID]}
+			byCategory[p.CategoryID] = b
+		}
+		b.add(p)
+
+		score := float64(*p.FocusScore)
+		focus = append(focus, score)
+		minutes = append(minutes, float64(p.Duration)/60)
+		if p.Energy != nil {
+			energyFocus = append(energyFocus, score)
+			energy = append(energy, float64(*p.Energy))
+		}
+		if p.Mood != nil {
+			moodFocus = append(moodFocus, score)
+			mood = append(mood, float64(*p.Mood))
+		}
+	}
+
+	categoryStats := make([]FocusBucket, 0, len(byCategory))
+	for _, b := range byCategory {
+		categoryStats = append(categoryStats, *b)
+	}
+	sort.Slice(categoryStats, func(i, j int) bool {
+		return categoryStats[i].AvgFocus > categoryStats[j].AvgFocus
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":        start.Format("2006-01-02"),
+		"to":          end.AddDate(0, 0, -1).Format("2006-01-02"),
+		"overall":     overall,
+		"by_hour":     byHour,
+		"by_length":   byLength,
+		"by_category": categoryStats,
+		// 专注评分与时长、精力、心情的皮尔逊相关系数
+		"correlation": gin.H{
+			"duration": pearson(focus, minutes),
+			"energy":   pearson(energyFocus, energy),
+			"mood":     pearson(moodFocus, mood),
+		},
+	})
+}
@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSessionLengthIndex(t *testing.T) {
+	tests := []struct {
+		seconds int
+		want    string
+	}{
+		{0, "<15"},
+		{14*60 + 59, "<15"},
+		{15 * 60, "15-25"},
+		{25*60 - 1, "15-25"},
+		{25 * 60, "25-35"},
+		{35*60 - 1, "25-35"},
+		{35 * 60, "35-50"},
+		{50*60 - 1, "35-50"},
+		{50 * 60, "50+"},
+		{24 * 3600, "50+"},
+	}
+	for _, tt := range tests {
+		if got := sessionLengths[sessionLengthIndex(tt.seconds)].name; got != tt.want {
+			t.Errorf("sessionLengthIndex(%d) 分段 = %s, want %s", tt.seconds, got, tt.want)
+		}
+	}
+}
+
+func TestPearson(t *testing.T) {
+	tests := []struct {
+		name   string
+		xs, ys []float64
+		want   *float64
+	}{
+		{"完全正相关", []float64{1, 2, 3, 4}, []float64{2, 4, 6, 8}, ptr(1.0)},
+		{"完全负相关", []float64{1, 2, 3, 4}, []float64{5, 4, 3, 2}, ptr(-1.0)},
+		{"部分相关", []float64{1, 2, 3, 4, 5}, []float64{2, 1, 4, 3, 5}, ptr(0.8)},
+		{"样本不足", []float64{1, 2}, []float64{1, 2}, nil},
+		{"方差为 0", []float64{3, 3, 3}, []float64{1, 2, 3}, nil},
+	}
+	for _, tt := range tests {
+		got := pearson(tt.xs, tt.ys)
+		switch {
+		case tt.want == nil && got != nil:
+			t.Errorf("%s: pearson = %v, want nil", tt.name, *got)
+		case tt.want != nil && (got == nil || math.Abs(*got-*tt.want) > 1e-9):
+			t.Errorf("%s: pearson = %v, want %v", tt.name, got, *tt.want)
+		}
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestRatePomodoro(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+	other := createTestUser(t, "bob", "bob@example.com")
+
+	r := gin.New()
+	r.Use(withUser(user.ID))
+	r.PUT("/api/pomodoros/:id/rating", RatePomodoro)
+
+	var category models.Category
+	database.DB.Where("user_id = ?", user.ID).First(&category)
+	start := time.Now().Add(-time.Hour)
+	end := start.Add(25 * time.Minute)
+	done := models.Pomodoro{UserID: user.ID, CategoryID: category.ID, Duration: 1500, PlannedDuration: 1500,
+		Completed: true, StartedAt: start, CompletedAt: &end, Mood: ptr(2)}
+	database.DB.Create(&done)
+	running := models.Pomodoro{UserID: user.ID, CategoryID: category.ID, PlannedDuration: 1500, StartedAt: time.Now()}
+	database.DB.Create(&running)
+	othersPomodoro := models.Pomodoro{UserID: other.ID, CategoryID: category.ID, Duration: 1500, PlannedDuration: 1500,
+		Completed: true, StartedAt: start, CompletedAt: &end}
+	database.DB.Create(&othersPomodoro)
+
+	rate := func(id uint, body gin.H) int {
+		return performRequest(r, http.MethodPut, fmt.Sprintf("/api/pomodoros/%d/rating", id), body).Code
+	}
+
+	if code := rate(done.ID, gin.H{"focus_score": 4, "energy": 3, "tags": []string{"深度工作"}}); code != http.StatusOK {
+		t.Fatalf("评分状态码 = %d", code)
+	}
+	var saved models.Pomodoro
+	database.DB.Preload("Tags").First(&saved, done.ID)
+	if saved.FocusScore == nil || *saved.FocusScore != 4 || saved.Energy == nil || *saved.Energy != 3 {
+		t.Errorf("评分未保存: focus=%v energy=%v", saved.FocusScore, saved.Energy)
+	}
+	if saved.Mood == nil || *saved.Mood != 2 {
+		t.Errorf("未传的心情应保持不变: %v", saved.Mood)
+	}
+	if len(saved.Tags) != 1 || saved.Tags[0].Name != "深度工作" {
+		t.Errorf("标签 = %v", saved.Tags)
+	}
+
+	// 只改评分时不修改标签
+	if code := rate(done.ID, gin.H{"mood": 5}); code != http.StatusOK {
+		t.Fatalf("修改心情状态码 = %d", code)
+	}
+	database.DB.Preload("Tags").First(&saved, done.ID)
+	if *saved.Mood != 5 || *saved.FocusScore != 4 || len(saved.Tags) != 1 {
+		t.Errorf("部分更新结果: focus=%d mood=%d tags=%v", *saved.FocusScore, *saved.Mood, saved.Tags)
+	}
+
+	for _, body := range []gin.H{{"focus_score": 0}, {"focus_score": 6}, {"energy": -1}, {"mood": 10}} {
+		if code := rate(done.ID, body); code != http.StatusBadRequest {
+			t.Errorf("评分 %v 状态码 = %d, want 400", body, code)
+		}
+	}
+	if code := rate(running.ID, gin.H{"focus_score": 3}); code != http.StatusBadRequest {
+		t.Errorf("进行中的番茄钟评分状态码 = %d, want 400", code)
+	}
+	if code := rate(othersPomodoro.ID, gin.H{"focus_score": 3}); code != http.StatusNotFound {
+		t.Errorf("其他用户的番茄钟评分状态码 = %d, want 404", code)
+	}
+}
+
+func TestCompletePomodoroSavesRating(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+
+	r := gin.New()
+	r.Use(withUser(user.ID))
+	r.PUT("/api/pomodoros/:id", CompletePomodoro)
+
+	var category models.Category
+	database.DB.Where("user_id = ?", user.ID).First(&category)
+	running := models.Pomodoro{UserID: user.ID, CategoryID: category.ID, PlannedDuration: 1500, StartedAt: time.Now().Add(-25 * time.Minute)}
+	database.DB.Create(&running)
+	path := fmt.Sprintf("/api/pomodoros/%d", running.ID)
+
+	// 评分超出范围时不完成番茄钟
+	if w := performRequest(r, http.MethodPut, path, gin.H{"completed": true, "focus_score": 6}); w.Code != http.StatusBadRequest {
+		t.Errorf("评分超出范围状态码 = %d, want 400", w.Code)
+	}
+	database.DB.First(&running, running.ID)
+	if running.CompletedAt != nil {
+		t.Fatal("评分无效时不应完成番茄钟")
+	}
+
+	if w := performRequest(r, http.MethodPut, path, gin.H{"completed": true, "focus_score": 5, "mood": 4}); w.Code != http.StatusOK {
+		t.Fatalf("完成状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+	database.DB.First(&running, running.ID)
+	if running.FocusScore == nil || *running.FocusScore != 5 || running.Mood == nil || *running.Mood != 4 || running.Energy != nil {
+		t.Errorf("完成时的评分: focus=%v energy=%v mood=%v", running.FocusScore, running.Energy, running.Mood)
+	}
+}
@@ -60,6 +60,7 @@ func UpdateSettings(c *gin.Context) {
 		}
 		database.DB.Create(&setting)
 	}
+	before := setting
 
 	// 更新字段（只更新传入的字段）
 	if input.DefaultDuration != nil {
@@ -79,6 +80,7 @@ func UpdateSettings(c *gin.Context) {
 	}
 
 	database.DB.Save(&setting)
+	recordChange(c, "settings.update", "setting", setting.ID, userID, before, setting)
 
 	c.JSON(http.StatusOK, setting)
 }
@@ -10,12 +10,17 @@ import (
 )
 
 type CategoryStats struct {
-	ID         uint   `json:"id"`
-	Name       string `json:"name"`
-	Color      string `json:"color"`
-	Duration   int    `json:"duration"`   // 总时长（秒）
-	Count      int64  `json:"count"`      // 番茄钟数量
-	Percentage float64 `json:"percentage"` // 占总时长的百分比
+	ID            uint    `json:"id"`
+	Name          string  `json:"name"`
+	Color         string  `json:"color"`
+	Duration      int     `json:"duration"`   // 总时长（秒）
+	Count         int64   `json:"count"`      // 番茄钟数量
+	Percentage    float64 `json:"percentage"` // 占总时长的百分比
+	ParentID      *uint   `json:"parent_id"`
+	Depth         int     `json:"depth"`          // 所在层级，顶级为 0
+	Path          string  `json:"path"`           // 完整路径
+	TotalDuration int     `json:"total_duration"` // 含子分类的总时长（秒）
+	TotalCount    int64   `json:"total_count"`    // 含子分类的番茄钟数量
 }
 
 type StatsResponse struct {
@@ -25,53 +30,50 @@ type StatsResponse struct {
 }
 
 // GetStats 获取统计数据（总时长 + 各分类时长）
+// level 参数指定按哪一层分类汇总（0 为顶级），不传时返回每个分类自身及含子分类的时长
 func GetStats(c *gin.Context) {
 	userID := c.GetUint("user_id")
 
-	// 查询所有已完成的番茄钟
-	var pomodoros []models.Pomodoro
-	database.DB.Where("user_id = ? AND completed = ?", userID, true).Preload("Category").Find(&pomodoros)
+	c.JSON(http.StatusOK, buildStats(userID, categoryLevel(c.Query("level"))))
+}
 
-	// 计算总时长和总数量
-	var totalDuration int
-	for _, p := range pomodoros {
-		totalDuration += p.Duration
+// buildStats 计算用户的总时长和各分类时长
+func buildStats(userID uint, level int) StatsResponse {
+	var total struct {
+		Duration int
+		Count    int64
 	}
-	totalCount := int64(len(pomodoros))
+	database.DB.Model(&models.Pomodoro{}).
+		Select("COALESCE(SUM(duration), 0) as duration, COUNT(*) as count").
+		Where("user_id = ? AND completed = ?", userID, true).
+		Scan(&total)
 
-	// 按分类统计
-	categoryMap := make(map[uint]*CategoryStats)
+	categories := aggregateCategoryStats(userID, level)
 
-	for _, p := range pomodoros {
-		if _, exists := categoryMap[p.CategoryID]; !exists {
-			categoryMap[p.CategoryID] = &CategoryStats{
-				ID:       p.Category.ID,
-				Name:     p.Category.Name,
-				Color:    p.Category.Color,
-				Duration: 0,
-				Count:    0,
-			}
-		}
-		categoryMap[p.CategoryID].Duration += p.Duration
-		categoryMap[p.CategoryID].Count++
+	// 分类已删除的番茄钟单独列出，保证各分类时长之和等于总时长
+	orphan := CategoryStats{Duration: total.Duration, Count: total.Count}
+	for _, stats := range categories {
+		orphan.Duration -= stats.Duration
+		orphan.Count -= stats.Count
+	}
+	if orphan.Count > 0 {
+		orphan.TotalDuration = orphan.Duration
+		orphan.TotalCount = orphan.Count
+		categories = append(categories, orphan)
 	}
 
 	// 计算百分比
-	categories := make([]CategoryStats, 0, len(categoryMap))
-	for _, stats := range categoryMap {
-		if totalDuration > 0 {
-			stats.Percentage = float64(stats.Duration) / float64(totalDuration) * 100
+	for i := range categories {
+		if total.Duration > 0 {
+			categories[i].Percentage = float64(categories[i].Duration) / float64(total.Duration) * 100
 		}
-		categories = append(categories, *stats)
 	}
 
-	response := StatsResponse{
-		TotalDuration: totalDuration,
-		TotalCount:    totalCount,
+	return StatsResponse{
+		TotalDuration: total.Duration,
+		TotalCount:    total.Count,
 		Categories:    categories,
 	}
-
-	c.JSON(http.StatusOK, response)
 }
 
 // GetTotalDuration 获取总时长
@@ -91,46 +93,11 @@ func GetTotalDuration(c *gin.Context) {
 }
 
 // GetCategoryStats 获取各分类统计
+// level 参数指定按哪一层分类汇总（0 为顶级），不传时返回每个分类自身及含子分类的时长
 func GetCategoryStats(c *gin.Context) {
 	userID := c.GetUint("user_id")
 
-	type Result struct {
-		CategoryID uint
-		Duration   int
-		Count      int64
-	}
-
-	var results []Result
-	database.DB.Model(&models.Pomodoro{}).
-		Select("category_id, SUM(duration) as duration, COUNT(*) as count").
-		Where("user_id = ? AND completed = ?", userID, true).
-		Group("category_id").
-		Scan(&results)
-
-	// 获取分类信息
-	var categories []models.Category
-	database.DB.Where("user_id = ?", userID).Find(&categories)
-
-	categoryMap := make(map[uint]models.Category)
-	for _, cat := range categories {
-		categoryMap[cat.ID] = cat
-	}
-
-	// 组装结果
-	stats := make([]CategoryStats, 0)
-	for _, r := range results {
-		if cat, exists := categoryMap[r.CategoryID]; exists {
-			stats = append(stats, CategoryStats{
-				ID:       cat.ID,
-				Name:     cat.Name,
-				Color:    cat.Color,
-				Duration: r.Duration,
-				Count:    r.Count,
-			})
-		}
-	}
-
-	c.JSON(http.StatusOK, stats)
+	c.JSON(http.StatusOK, aggregateCategoryStats(userID, categoryLevel(c.Query("level"))))
 }
 
 // GetDailyStats 获取每日统计
@@ -155,7 +122,7 @@ func GetDailyStats(c *gin.Context) {
 	c.JSON(http.StatusOK, results)
 }
 
-// 获取排行榜
+// 获取排行榜，exclude_manual=true 时只统计计时器记录
 func GetLeaderboard(c *gin.Context) {
 	type UserStat struct {
 		UserID       uint   `json:"user_id"`
@@ -167,11 +134,15 @@ func GetLeaderboard(c *gin.Context) {
 	var userStats []UserStat
 
 	// 查询所有用户的统计数据
-	database.DB.Table("pomodoros").
+	query := database.DB.Table("pomodoros").
 		Select("users.id as user_id, users.username, COUNT(*) as total_count, SUM(pomodoros.duration) as total_duration").
 		Joins("JOIN users ON users.id = pomodoros.user_id").
-		Where("pomodoros.completed = ?", true).
-		Group("users.id, users.username").
+		Where("pomodoros.completed = ? AND pomodoros.deleted_at IS NULL", true).
+		Where("users.leaderboard_hidden = ? AND users.disabled = ?", false, false)
+	if c.Query("exclude_manual") == "true" {
+		query = query.Where("pomodoros.source = ?", models.PomodoroSourceTimer)
+	}
+	query.Group("users.id, users.username").
 		Order("total_duration DESC").
 		Limit(100).
 		Scan(&userStats)
@@ -183,12 +154,14 @@ func GetLeaderboard(c *gin.Context) {
 type CheckinStatsResponse struct {
 	StreakDays      int  `json:"streak_days"`       // 连续打卡天数
 	TodayDuration   int  `json:"today_duration"`    // 今日学习时长（秒）
-	TodayGoal       int  `json:"today_goal"`        // 今日目标（秒）
+	TodayGoal       int  `json:"today_goal"`        // 今日目标（秒），今天的计划设置了目标番茄数时按计划折算
+	TodayGoalSource string `json:"today_goal_source"` // 今日目标来源：plan 或 setting
 	TodayCompleted  bool `json:"today_completed"`   // 今日是否完成目标
 	TodayCount      int  `json:"today_count"`       // 今日番茄钟数量
 	ExamDate        *string `json:"exam_date"`      // 考试日期
 	ExamName        string  `json:"exam_name"`      // 考试名称
 	DaysUntilExam   int  `json:"days_until_exam"`   // 距离考试天数
+	Plan            PlanProgress `json:"plan"`       // 今日计划进度
 }
 
 // GetCheckinStats 获取打卡统计
@@ -210,9 +183,20 @@ func GetCheckinStats(c *gin.Context) {
 		Where("user_id = ? AND completed = ? AND DATE(started_at) = ?", userID, true, today).
 		Scan(&todayStats)
 
-	// 计算连续打卡天数
+	// 计算连续打卡天数（按设置中的每日目标）
 	streakDays := calculateStreak(userID, setting.DailyGoal)
 
+	// 今日目标：今天的计划（含日历导入累加的）设置了目标番茄数时以计划为准
+	plan := loadDailyPlan(userID, today).Progress
+	todayGoal, goalSource := setting.DailyGoal, "setting"
+	if plan.TargetPomodoros > 0 {
+		pomodoroLength := setting.DefaultDuration
+		if pomodoroLength <= 0 {
+			pomodoroLength = 1500
+		}
+		todayGoal, goalSource = plan.TargetPomodoros*pomodoroLength, "plan"
+	}
+
 	// 计算距离考试天数
 	daysUntilExam := 0
 	if setting.ExamDate != nil && *setting.ExamDate != "" {
@@ -228,12 +212,14 @@ func GetCheckinStats(c *gin.Context) {
 	response := CheckinStatsResponse{
 		StreakDays:     streakDays,
 		TodayDuration:  todayStats.Duration,
-		TodayGoal:      setting.DailyGoal,
-		TodayCompleted: todayStats.Duration >= setting.DailyGoal,
+		TodayGoal:      todayGoal,
+		TodayGoalSource: goalSource,
+		TodayCompleted: todayStats.Duration >= todayGoal,
 		TodayCount:     todayStats.Count,
 		ExamDate:       setting.ExamDate,
 		ExamName:       setting.ExamName,
 		DaysUntilExam:  daysUntilExam,
+		Plan:           plan,
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCheckinGoalFollowsImportedCalendar(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+	database.DB.Model(&models.Setting{}).Where("user_id = ?", user.ID).Updates(map[string]interface{}{
+		"daily_goal": 7200, "default_duration": 1500,
+	})
+
+	r := gin.New()
+	r.Use(withUser(user.ID))
+	r.POST("/api/calendar/import", ImportCalendar)
+	r.GET("/api/stats/checkin", GetCheckinStats)
+
+	var stats CheckinStatsResponse
+	decodeJSON(t, performRequest(r, http.MethodGet, "/api/stats/checkin", nil), &stats)
+	if stats.TodayGoal != 7200 || stats.TodayGoalSource != "setting" {
+		t.Fatalf("没有计划时今日目标 = %d (%s), 应为设置中的 7200", stats.TodayGoal, stats.TodayGoalSource)
+	}
+
+	// 今天两个事件：1 小时（2 个番茄）和 25 分钟（1 个番茄）
+	now := time.Now()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 9, 0, 0, 0, time.Local)
+	ics := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"
+	for i, length := range []time.Duration{time.Hour, 25 * time.Minute} {
+		begin := day.Add(time.Duration(i) * 2 * time.Hour).UTC()
+		ics += fmt.Sprintf("BEGIN:VEVENT\r\nUID:event-%d@test\r\nSUMMARY:会议\r\nDTSTART:%s\r\nDTEND:%s\r\nEND:VEVENT\r\n",
+			i, begin.Format("20060102T150405Z"), begin.Add(length).Format("20060102T150405Z"))
+	}
+	ics += "END:VCALENDAR\r\n"
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "work.ics")
+	part.Write([]byte(ics))
+	form.Close()
+	w := performRequest(r, http.MethodPost, "/api/calendar/import?include_unmatched=true", &body, "Content-Type", form.FormDataContentType())
+	if w.Code != http.StatusOK {
+		t.Fatalf("导入日历状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+
+	decodeJSON(t, performRequest(r, http.MethodGet, "/api/stats/checkin", nil), &stats)
+	if stats.Plan.TargetPomodoros != 3 {
+		t.Fatalf("计划目标番茄数 = %d, want 3", stats.Plan.TargetPomodoros)
+	}
+	if stats.TodayGoal != 3*1500 || stats.TodayGoalSource != "plan" {
+		t.Errorf("导入日历后今日目标 = %d (%s), 应为计划折算的 %d", stats.TodayGoal, stats.TodayGoalSource, 3*1500)
+	}
+
+	// 完成计划的 3 个番茄即达到今日目标，即使不到设置中的 2 小时
+	var category models.Category
+	database.DB.Where("user_id = ?", user.ID).First(&category)
+	start := day.Add(3 * time.Hour)
+	for i := 0; i < 3; i++ {
+		begin := start.Add(time.Duration(i) * 30 * time.Minute)
+		end := begin.Add(25 * time.Minute)
+		database.DB.Create(&models.Pomodoro{
+			UserID: user.ID, CategoryID: category.ID, Duration: 1500, PlannedDuration: 1500,
+			Completed: true, StartedAt: begin, CompletedAt: &end,
+		})
+	}
+	decodeJSON(t, performRequest(r, http.MethodGet, "/api/stats/checkin", nil), &stats)
+	if !stats.TodayCompleted {
+		t.Errorf("完成 %d 秒时应达到 %d 秒的今日目标", stats.TodayDuration, stats.TodayGoal)
+	}
+}
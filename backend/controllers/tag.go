@@ -0,0 +1,263 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"pomodoro-api/utils"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	// 单个番茄钟最多的标签数
+	maxTagsPerPomodoro = utils.GetEnvInt("MAX_TAGS_PER_POMODORO", 10)
+	// 每个用户最多的标签数
+	maxTagsPerUser = utils.GetEnvInt("MAX_TAGS_PER_USER", 200)
+)
+
+// maxTagNameLength 标签名最大长度（字符）
+const maxTagNameLength = 30
+
+// normalizeTagNames 去除空白和重复的标签名（不区分大小写）
+// 导出时多个标签用分号连接、导入时按逗号或分号拆分，所以标签名不能包含这两个字符
+func normalizeTagNames(names []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var result []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if utf8.RuneCountInString(name) > maxTagNameLength {
+			return nil, fmt.Errorf("标签名不能超过 %d 个字符", maxTagNameLength)
+		}
+		if strings.ContainsAny(name, ",;") || strings.IndexFunc(name, unicode.IsControl) >= 0 {
+			return nil, fmt.Errorf("标签名不能包含逗号、分号或控制字符")
+		}
+		key := strings.ToLower(name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, name)
+	}
+	if len(result) > maxTagsPerPomodoro {
+		return nil, fmt.Errorf("标签数量超出限制（最多 %d）", maxTagsPerPomodoro)
+	}
+	return result, nil
+}
+
+// resolveTags 按名称查找用户的标签，不存在时自动创建
+func resolveTags(userID uint, names []string) ([]models.Tag, error) {
+	return resolveTagsWith(database.DB, userID, names)
+}
+
+// resolveTagsWith 同 resolveTags，在指定的事务中执行
+func resolveTagsWith(db *gorm.DB, userID uint, names []string) ([]models.Tag, error) {
+	names, err := normalizeTagNames(names)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]models.Tag, 0, len(names))
+	for _, name := range names {
+		var tag models.Tag
+		if err := db.Where("user_id = ? AND LOWER(name) = ?", userID, strings.ToLower(name)).First(&tag).Error; err == nil {
+			tags = append(tags, tag)
+			continue
+		}
+
+		var count int64
+		db.Model(&models.Tag{}).Where("user_id = ?", userID).Count(&count)
+		if count >= int64(maxTagsPerUser) {
+			return nil, fmt.Errorf("标签数量超出限制（最多 %d）", maxTagsPerUser)
+		}
+
+		tag = models.Tag{UserID: userID, Name: name}
+		if err := db.Create(&tag).Error; err != nil {
+			return nil, fmt.Errorf("创建标签失败")
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// GetTags 获取所有标签及使用次数
+func GetTags(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	type TagWithCount struct {
+		models.Tag
+		Count int64 `json:"count"`
+	}
+
+	var tags []TagWithCount
+	database.DB.Model(&models.Tag{}).
+		Select("tags.*, COUNT(pomodoros.id) as count").
+		Joins("LEFT JOIN pomodoro_tags ON pomodoro_tags.tag_id = tags.id").
+		Joins("LEFT JOIN pomodoros ON pomodoros.id = pomodoro_tags.pomodoro_id AND pomodoros.deleted_at IS NULL").
+		Where("tags.user_id = ?", userID).
+		Group("tags.id").
+		Order("count DESC, tags.name ASC").
+		Scan(&tags)
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// UpdateTag 修改标签名称或颜色
+func UpdateTag(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var tag models.Tag
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&tag).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "标签不存在"})
+		return
+	}
+
+	var input struct {
+		Name  string `json:"name" binding:"max=30"`
+		Color string `json:"color" binding:"max=20"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := tag
+	names, err := normalizeTagNames([]string{input.Name})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(names) > 0 {
+		// 标签按名称查找时不区分大小写，不能与其他标签只差大小写
+		var count int64
+		database.DB.Model(&models.Tag{}).
+			Where("user_id = ? AND id <> ? AND LOWER(name) = ?", userID, tag.ID, strings.ToLower(names[0])).
+			Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "标签名已存在"})
+			return
+		}
+		tag.Name = names[0]
+	}
+	if input.Color != "" {
+		tag.Color = input.Color
+	}
+
+	if err := database.DB.Save(&tag).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "标签名已存在"})
+		return
+	}
+	recordChange(c, "tag.update", "tag", tag.ID, userID, before, tag)
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag 删除标签，并从所有番茄钟上移除
+func DeleteTag(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var tag models.Tag
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&tag).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "标签不存在"})
+		return
+	}
+
+	database.DB.Exec("DELETE FROM pomodoro_tags WHERE tag_id = ?", tag.ID)
+	// 彻底删除，允许之后重新创建同名标签
+	database.DB.Unscoped().Delete(&tag)
+	recordChange(c, "tag.delete", "tag", tag.ID, userID, tag, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// TagStats 单个标签的统计
+type TagStats struct {
+	ID         uint    `json:"id"`
+	Name       string  `json:"name"`
+	Color      string  `json:"color"`
+	Duration   int     `json:"duration"`   // 总时长（秒）
+	Count      int64   `json:"count"`      // 番茄钟数量
+	Percentage float64 `json:"percentage"` // 占总时长的百分比
+}
+
+// TagPairStats 两个标签同时出现的统计
+type TagPairStats struct {
+	Tags     [2]string `json:"tags"`
+	TagIDs   [2]uint   `json:"tag_ids"`
+	Duration int       `json:"duration"`
+	Count    int64     `json:"count"`
+}
+
+// GetTagStats 获取各标签时长以及标签共同出现的情况
+func GetTagStats(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var totalDuration int
+	database.DB.Model(&models.Pomodoro{}).
+		Where("user_id = ? AND completed = ?", userID, true).
+		Select("COALESCE(SUM(duration), 0)").
+		Scan(&totalDuration)
+
+	var tags []TagStats
+	database.DB.Table("pomodoro_tags").
+		Select("tags.id, tags.name, tags.color, SUM(pomodoros.duration) as duration, COUNT(*) as count").
+		Joins("JOIN pomodoros ON pomodoros.id = pomodoro_tags.pomodoro_id").
+		Joins("JOIN tags ON tags.id = pomodoro_tags.tag_id").
+		Where("pomodoros.user_id = ? AND pomodoros.completed = ? AND pomodoros.deleted_at IS NULL", userID, true).
+		Group("tags.id, tags.name, tags.color").
+		Order("duration DESC").
+		Scan(&tags)
+	for i := range tags {
+		if totalDuration > 0 {
+			tags[i].Percentage = float64(tags[i].Duration) / float64(totalDuration) * 100
+		}
+	}
+
+	type pairRow struct {
+		TagA     uint
+		NameA    string
+		TagB     uint
+		NameB    string
+		Duration int
+		Count    int64
+	}
+	var rows []pairRow
+	database.DB.Table("pomodoro_tags AS a").
+		Select("a.tag_id as tag_a, ta.name as name_a, b.tag_id as tag_b, tb.name as name_b, SUM(pomodoros.duration) as duration, COUNT(*) as count").
+		Joins("JOIN pomodoro_tags AS b ON b.pomodoro_id = a.pomodoro_id AND b.tag_id > a.tag_id").
+		Joins("JOIN pomodoros ON pomodoros.id = a.pomodoro_id").
+		Joins("JOIN tags AS ta ON ta.id = a.tag_id").
+		Joins("JOIN tags AS tb ON tb.id = b.tag_id").
+		Where("pomodoros.user_id = ? AND pomodoros.completed = ? AND pomodoros.deleted_at IS NULL", userID, true).
+		Group("a.tag_id, ta.name, b.tag_id, tb.name").
+		Order("count DESC, duration DESC").
+		Limit(50).
+		Scan(&rows)
+
+	pairs := make([]TagPairStats, 0, len(rows))
+	for _, r := range rows {
+		pairs = append(pairs, TagPairStats{
+			Tags:     [2]string{r.NameA, r.NameB},
+			TagIDs:   [2]uint{r.TagA, r.TagB},
+			Duration: r.Duration,
+			Count:    r.Count,
+		})
+	}
+
+	if tags == nil {
+		tags = []TagStats{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"total_duration": totalDuration,
+		"tags":           tags,
+		"co_occurrence":  pairs,
+	})
+}
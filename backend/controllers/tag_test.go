@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUpdateTagValidatesName(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+
+	r := gin.New()
+	r.Use(withUser(user.ID))
+	r.PUT("/api/tags/:id", UpdateTag)
+
+	existing := models.Tag{UserID: user.ID, Name: "GO"}
+	database.DB.Create(&existing)
+	tag := models.Tag{UserID: user.ID, Name: "rust"}
+	database.DB.Create(&tag)
+	// 其他用户的同名标签不影响
+	other := createTestUser(t, "bob", "bob@example.com")
+	database.DB.Create(&models.Tag{UserID: other.ID, Name: "Python"})
+
+	tests := []struct {
+		name     string
+		input    string
+		wantCode int
+		wantName string
+	}{
+		{"与已有标签只差大小写", "Go", http.StatusBadRequest, "rust"},
+		{"超过长度限制", strings.Repeat("长", maxTagNameLength+1), http.StatusBadRequest, "rust"},
+		{"包含分隔符", "a;b", http.StatusBadRequest, "rust"},
+		{"包含控制字符", "a\nb", http.StatusBadRequest, "rust"},
+		{"只有空白时不修改", "   ", http.StatusOK, "rust"},
+		{"去除首尾空白", "  Rust  ", http.StatusOK, "Rust"},
+		{"其他用户的标签不冲突", "python", http.StatusOK, "python"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := performRequest(r, http.MethodPut, fmt.Sprintf("/api/tags/%d", tag.ID), gin.H{"name": tt.input})
+			if w.Code != tt.wantCode {
+				t.Errorf("状态码 = %d, want %d, body=%s", w.Code, tt.wantCode, w.Body.String())
+			}
+			var stored models.Tag
+			database.DB.First(&stored, tag.ID)
+			if stored.Name != tt.wantName {
+				t.Errorf("标签名 = %q, want %q", stored.Name, tt.wantName)
+			}
+		})
+	}
+
+	// 只修改自己名称的大小写
+	w := performRequest(r, http.MethodPut, fmt.Sprintf("/api/tags/%d", existing.ID), gin.H{"name": "Go"})
+	if w.Code != http.StatusOK {
+		t.Errorf("修改自身大小写状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,387 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"pomodoro-api/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 每个用户最多的未删除任务数
+var maxTasksPerUser = utils.GetEnvInt("MAX_TASKS_PER_USER", 500)
+
+// attachTaskActuals 填充任务实际完成的番茄数和专注时长
+func attachTaskActuals(userID uint, tasks []models.Task) {
+	if len(tasks) == 0 {
+		return
+	}
+
+	ids := make([]uint, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+
+	type result struct {
+		TaskID   uint
+		Duration int
+		Count    int64
+	}
+	var results []result
+	database.DB.Model(&models.Pomodoro{}).
+		Select("task_id, SUM(duration) as duration, COUNT(*) as count").
+		Where("user_id = ? AND completed = ? AND task_id IN ?", userID, true, ids).
+		Group("task_id").
+		Scan(&results)
+
+	actuals := make(map[uint]result, len(results))
+	for _, r := range results {
+		actuals[r.TaskID] = r
+	}
+	for i := range tasks {
+		r := actuals[tasks[i].ID]
+		tasks[i].ActualPomodoros = r.Count
+		tasks[i].ActualDuration = r.Duration
+	}
+}
+
+// findUserTask 查找当前用户的任务
+func findUserTask(c *gin.Context, id interface{}) (*models.Task, bool) {
+	var task models.Task
+	if err := database.DB.Where("id = ? AND user_id = ?", id, c.GetUint("user_id")).First(&task).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		return nil, false
+	}
+	return &task, true
+}
+
+// checkTaskCategory 校验分类属于当前用户
+func checkTaskCategory(userID, categoryID uint) error {
+	var count int64
+	database.DB.Model(&models.Category{}).Where("id = ? AND user_id = ?", categoryID, userID).Count(&count)
+	if count == 0 {
+		return errors.New("分类不存在")
+	}
+	return nil
+}
+
+// checkDueDate 校验日期格式
+func checkDueDate(date *string) error {
+	if date == nil || *date == "" {
+		return nil
+	}
+	if _, err := time.Parse("2006-01-02", *date); err != nil {
+		return errors.New("日期格式应为 YYYY-MM-DD")
+	}
+	return nil
+}
+
+// GetTasks 获取任务列表，可按状态（逗号分隔）、分类和截止日期过滤
+func GetTasks(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	query := database.DB.Where("user_id = ?", userID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status IN ?", strings.Split(status, ","))
+	}
+	if categoryID := c.Query("category_id"); categoryID != "" {
+		query = query.Where("category_id = ?", categoryID)
+	}
+	if dueBefore := c.Query("due_before"); dueBefore != "" {
+		query = query.Where("due_date IS NOT NULL AND due_date <= ?", dueBefore)
+	}
+
+	var tasks []models.Task
+	query.Order("position ASC, id ASC").Find(&tasks)
+	attachTaskActuals(userID, tasks)
+
+	c.JSON(http.StatusOK, tasks)
+}
+
+// GetTask 获取单个任务
+func GetTask(c *gin.Context) {
+	task, ok := findUserTask(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	tasks := []models.Task{*task}
+	attachTaskActuals(task.UserID, tasks)
+
+	c.JSON(http.StatusOK, tasks[0])
+}
+
+// CreateTask 创建任务，新任务排在最后
+func CreateTask(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		Title              string  `json:"title" binding:"required,max=100"`
+		Note               string  `json:"note"`
+		CategoryID         *uint   `json:"category_id"`
+		EstimatedPomodoros *int    `json:"estimated_pomodoros" binding:"omitempty,min=0,max=100"`
+		DueDate            *string `json:"due_date"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !checkNoteLength(c, input.Note) {
+		return
+	}
+	if err := checkDueDate(input.DueDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.CategoryID != nil {
+		if err := checkTaskCategory(userID, *input.CategoryID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var count int64
+	database.DB.Model(&models.Task{}).Where("user_id = ?", userID).Count(&count)
+	if count >= int64(maxTasksPerUser) {
+		rejectLimit(c, "任务数量", int(count)+1, maxTasksPerUser)
+		return
+	}
+
+	var maxPosition int
+	database.DB.Model(&models.Task{}).Where("user_id = ?", userID).Select("COALESCE(MAX(position), 0)").Scan(&maxPosition)
+
+	task := models.Task{
+		UserID:             userID,
+		CategoryID:         input.CategoryID,
+		Title:              input.Title,
+		Note:               input.Note,
+		EstimatedPomodoros: 1,
+		DueDate:            input.DueDate,
+		Status:             models.TaskStatusTodo,
+		Position:           maxPosition + 1,
+	}
+	if input.EstimatedPomodoros != nil {
+		task.EstimatedPomodoros = *input.EstimatedPomodoros
+	}
+
+	if err := database.DB.Create(&task).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// UpdateTask 更新任务（只更新传入的字段）
+func UpdateTask(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	task, ok := findUserTask(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Title              *string `json:"title" binding:"omitempty,min=1,max=100"`
+		Note               *string `json:"note"`
+		CategoryID         *uint   `json:"category_id"` // 0 表示取消分类
+		EstimatedPomodoros *int    `json:"estimated_pomodoros" binding:"omitempty,min=0,max=100"`
+		DueDate            *string `json:"due_date"` // 空字符串表示取消截止日期
+		Status             *string `json:"status"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Note != nil && !checkNoteLength(c, *input.Note) {
+		return
+	}
+	if err := checkDueDate(input.DueDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Status != nil && !models.IsValidTaskStatus(*input.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务状态"})
+		return
+	}
+	if input.CategoryID != nil && *input.CategoryID != 0 {
+		if err := checkTaskCategory(userID, *input.CategoryID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	before := *task
+	if input.Title != nil {
+		task.Title = *input.Title
+	}
+	if input.Note != nil {
+		task.Note = *input.Note
+	}
+	if input.CategoryID != nil {
+		task.CategoryID = input.CategoryID
+		if *input.CategoryID == 0 {
+			task.CategoryID = nil
+		}
+	}
+	if input.EstimatedPomodoros != nil {
+		task.EstimatedPomodoros = *input.EstimatedPomodoros
+	}
+	if input.DueDate != nil {
+		task.DueDate = input.DueDate
+		if *input.DueDate == "" {
+			task.DueDate = nil
+		}
+	}
+	if input.Status != nil && *input.Status != task.Status {
+		task.Status = *input.Status
+		task.CompletedAt = nil
+		if task.Status == models.TaskStatusDone {
+			now := time.Now()
+			task.CompletedAt = &now
+		}
+	}
+
+	database.DB.Save(task)
+	recordChange(c, "task.update", "task", task.ID, userID, before, task)
+
+	tasks := []models.Task{*task}
+	attachTaskActuals(userID, tasks)
+	c.JSON(http.StatusOK, tasks[0])
+}
+
+// DeleteTask 删除任务，已关联的番茄钟保留
+func DeleteTask(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	task, ok := findUserTask(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	database.DB.Delete(task)
+	recordChange(c, "task.delete", "task", task.ID, userID, task, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// ReorderTasks 按传入的 ID 顺序重新排列任务
+func ReorderTasks(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		IDs []uint `json:"ids" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var count int64
+	database.DB.Model(&models.Task{}).Where("user_id = ? AND id IN ?", userID, input.IDs).Count(&count)
+	if count != int64(len(input.IDs)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "任务不存在或有重复"})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for i, id := range input.IDs {
+			if err := tx.Model(&models.Task{}).Where("id = ? AND user_id = ?", id, userID).Update("position", i+1).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "排序失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "排序成功"})
+}
+
+// TaskEstimateStats 任务预估与实际对比
+type TaskEstimateStats struct {
+	ID                 uint    `json:"id"`
+	Title              string  `json:"title"`
+	Status             string  `json:"status"`
+	EstimatedPomodoros int     `json:"estimated_pomodoros"`
+	ActualPomodoros    int64   `json:"actual_pomodoros"`
+	ActualDuration     int     `json:"actual_duration"` // 实际专注时长（秒）
+	Difference         int64   `json:"difference"`      // 实际 - 预估
+	Ratio              float64 `json:"ratio"`           // 实际 / 预估，预估为 0 时为 0
+}
+
+// GetTaskStats 获取任务的预估与实际番茄数对比
+// 汇总部分只统计已完成的任务
+func GetTaskStats(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	query := database.DB.Where("user_id = ?", userID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status IN ?", strings.Split(status, ","))
+	}
+
+	var tasks []models.Task
+	query.Order("position ASC, id ASC").Find(&tasks)
+	attachTaskActuals(userID, tasks)
+
+	var summary struct {
+		DoneTasks      int     `json:"done_tasks"`
+		Estimated      int     `json:"estimated_pomodoros"`
+		Actual         int64   `json:"actual_pomodoros"`
+		ActualDuration int     `json:"actual_duration"`
+		Ratio          float64 `json:"ratio"`       // 实际 / 预估
+		OverCount      int     `json:"over_count"`  // 超出预估的任务数
+		UnderCount     int     `json:"under_count"` // 少于预估的任务数
+		ExactCount     int     `json:"exact_count"` // 与预估一致的任务数
+	}
+
+	stats := make([]TaskEstimateStats, 0, len(tasks))
+	for _, t := range tasks {
+		s := TaskEstimateStats{
+			ID:                 t.ID,
+			Title:              t.Title,
+			Status:             t.Status,
+			EstimatedPomodoros: t.EstimatedPomodoros,
+			ActualPomodoros:    t.ActualPomodoros,
+			ActualDuration:     t.ActualDuration,
+			Difference:         t.ActualPomodoros - int64(t.EstimatedPomodoros),
+		}
+		if t.EstimatedPomodoros > 0 {
+			s.Ratio = float64(t.ActualPomodoros) / float64(t.EstimatedPomodoros)
+		}
+		stats = append(stats, s)
+
+		if t.Status != models.TaskStatusDone {
+			continue
+		}
+		summary.DoneTasks++
+		summary.Estimated += t.EstimatedPomodoros
+		summary.Actual += t.ActualPomodoros
+		summary.ActualDuration += t.ActualDuration
+		switch {
+		case s.Difference > 0:
+			summary.OverCount++
+		case s.Difference < 0:
+			summary.UnderCount++
+		default:
+			summary.ExactCount++
+		}
+	}
+	if summary.Estimated > 0 {
+		summary.Ratio = float64(summary.Actual) / float64(summary.Estimated)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"summary": summary,
+		"tasks":   stats,
+	})
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCreateTaskEstimatedPomodoros(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "alice", "alice@example.com")
+
+	r := gin.New()
+	r.Use(withUser(user.ID))
+	r.POST("/api/tasks", CreateTask)
+	r.GET("/api/tasks/:id", GetTask)
+	r.PUT("/api/tasks/:id", UpdateTask)
+
+	tests := []struct {
+		name string
+		body gin.H
+		want int
+	}{
+		{"不预估", gin.H{"title": "整理笔记", "estimated_pomodoros": 0}, 0},
+		{"未传时默认为 1", gin.H{"title": "背单词"}, 1},
+		{"指定预估", gin.H{"title": "做真题", "estimated_pomodoros": 4}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := performRequest(r, http.MethodPost, "/api/tasks", tt.body)
+			if w.Code != http.StatusOK {
+				t.Fatalf("创建任务状态码 = %d, body=%s", w.Code, w.Body.String())
+			}
+			var created models.Task
+			decodeJSON(t, w, &created)
+			if created.EstimatedPomodoros != tt.want {
+				t.Errorf("响应中的预估番茄数 = %d, want %d", created.EstimatedPomodoros, tt.want)
+			}
+
+			var stored models.Task
+			database.DB.First(&stored, created.ID)
+			if stored.EstimatedPomodoros != tt.want {
+				t.Errorf("数据库中的预估番茄数 = %d, want %d", stored.EstimatedPomodoros, tt.want)
+			}
+
+			w = performRequest(r, http.MethodGet, fmt.Sprintf("/api/tasks/%d", created.ID), nil)
+			var fetched models.Task
+			decodeJSON(t, w, &fetched)
+			if fetched.EstimatedPomodoros != tt.want {
+				t.Errorf("读取的预估番茄数 = %d, want %d", fetched.EstimatedPomodoros, tt.want)
+			}
+		})
+	}
+
+	// 更新为 0 后保持为 0
+	var task models.Task
+	database.DB.Where("title = ?", "做真题").First(&task)
+	w := performRequest(r, http.MethodPut, fmt.Sprintf("/api/tasks/%d", task.ID), gin.H{"estimated_pomodoros": 0})
+	if w.Code != http.StatusOK {
+		t.Fatalf("更新任务状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+	database.DB.First(&task, task.ID)
+	if task.EstimatedPomodoros != 0 {
+		t.Errorf("更新后的预估番茄数 = %d, want 0", task.EstimatedPomodoros)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"pomodoro-api/database"
 	"pomodoro-api/models"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -34,6 +35,13 @@ func SubmitWordCount(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "单词数量不能为负数"})
 		return
 	}
+	if input.WordCount > maxDailyWordCount {
+		rejectLimit(c, "单词数量", input.WordCount, maxDailyWordCount)
+		return
+	}
+	if !checkNoteLength(c, input.Note) {
+		return
+	}
 
 	// 查找是否已存在该日期的记录
 	var record models.WordRecord
@@ -58,14 +66,57 @@ func SubmitWordCount(c *gin.Context) {
 	}
 }
 
-// GetWordRecords 获取用户的单词记录
+// wordSortColumns 单词记录支持的排序字段
+var wordSortColumns = map[string]sortKind{
+	"date":       sortString,
+	"word_count": sortInt,
+}
+
+// GetWordRecords 获取用户的单词记录，分页方式与番茄钟历史相同
 func GetWordRecords(c *gin.Context) {
 	userID := c.GetUint("user_id")
 
+	sort, ok := parseListSort(c, "-date", wordSortColumns)
+	if !ok {
+		return
+	}
+	limit := parseListLimit(c)
+
+	query := database.DB.Model(&models.WordRecord{}).Where("user_id = ?", userID)
+	if c.Query("from") != "" || c.Query("to") != "" {
+		start, end, ok := parseDateRange(c, maxDateRangeDays)
+		if !ok {
+			return
+		}
+		query = query.Where("date >= ? AND date < ?", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	}
+	if keyword := strings.TrimSpace(c.Query("q")); keyword != "" {
+		query = query.Where("note LIKE ? ESCAPE '\\'", "%"+escapeLike(keyword)+"%")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	query, ok = paginate(c, query, sort, limit)
+	if !ok {
+		return
+	}
+
 	var records []models.WordRecord
-	database.DB.Where("user_id = ?", userID).
-		Order("date DESC").
-		Find(&records)
+	query.Find(&records)
+
+	next := ""
+	if len(records) > limit {
+		records = records[:limit]
+		last := records[limit-1]
+		var value interface{} = last.WordCount
+		if sort.column == "date" {
+			// 读出的日期可能带有时间部分，游标只保留 YYYY-MM-DD
+			value = dateOnly(last.Date)
+		}
+		next = encodeCursor(sort, value, last.ID)
+	}
+	setPageHeaders(c, total, next)
 
 	c.JSON(http.StatusOK, records)
 }
@@ -142,6 +193,8 @@ func DeleteWordRecord(c *gin.Context) {
 	}
 
 	database.DB.Delete(&record)
+	recordChange(c, "word_record.delete", "word_record", record.ID, userID, record, nil)
+
 	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 }
 
@@ -159,7 +212,8 @@ func GetWordDailyLeaderboard(c *gin.Context) {
 	database.DB.Table("word_records").
 		Select("word_records.user_id, users.username, word_records.word_count").
 		Joins("LEFT JOIN users ON users.id = word_records.user_id").
-		Where("word_records.date = ?", today).
+		Where("word_records.date = ? AND word_records.deleted_at IS NULL", today).
+		Where("users.leaderboard_hidden = ? AND users.disabled = ?", false, false).
 		Order("word_records.word_count DESC").
 		Limit(50).
 		Scan(&leaderboard)
@@ -180,6 +234,8 @@ func GetWordTotalLeaderboard(c *gin.Context) {
 	database.DB.Table("word_records").
 		Select("word_records.user_id, users.username, SUM(word_records.word_count) as total_words, COUNT(DISTINCT word_records.date) as total_days").
 		Joins("LEFT JOIN users ON users.id = word_records.user_id").
+		Where("word_records.deleted_at IS NULL").
+		Where("users.leaderboard_hidden = ? AND users.disabled = ?", false, false).
 		Group("word_records.user_id, users.username").
 		Order("total_words DESC").
 		Limit(50).
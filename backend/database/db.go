@@ -18,16 +18,35 @@ func InitDB() {
 	}
 
 	// 自动迁移数据库表
-	err = DB.AutoMigrate(
+	if err := Migrate(DB); err != nil {
+		log.Fatal("数据库迁移失败:", err)
+	}
+
+	log.Println("数据库初始化成功")
+}
+
+// Migrate 创建或更新所有数据表
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(
 		&models.User{},
 		&models.Category{},
 		&models.Pomodoro{},
 		&models.Setting{},
 		&models.WordRecord{},
+		&models.LoginAttempt{},
+		&models.AccountToken{},
+		&models.AuditEvent{},
+		&models.APIToken{},
+		&models.UserIdentity{},
+		&models.RateLimitBucket{},
+		&models.Tag{},
+		&models.Task{},
+		&models.DailyPlan{},
+		&models.DailyReview{},
+		&models.Interruption{},
+		&models.CalendarFeed{},
+		&models.CalendarImportSource{},
+		&models.CalendarRule{},
+		&models.CalendarEventLink{},
 	)
-	if err != nil {
-		log.Fatal("数据库迁移失败:", err)
-	}
-
-	log.Println("数据库初始化成功")
 }
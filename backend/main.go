@@ -1,10 +1,14 @@
 package main
 
 import (
+	"flag"
 	"log"
+	"net"
 	"pomodoro-api/controllers"
 	"pomodoro-api/database"
 	"pomodoro-api/middleware"
+	"pomodoro-api/models"
+	"pomodoro-api/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,75 +16,228 @@ import (
 func main() {
 	// 初始化数据库
 	database.InitDB()
+	controllers.BootstrapAdmins()
+
+	// 命令行管理操作：解锁账号后退出
+	unlock := flag.String("unlock", "", "解锁被锁定的账号（用户名或邮箱）")
+	flag.Parse()
+	if *unlock != "" {
+		if err := controllers.AdminUnlockAccount(*unlock); err != nil {
+			log.Fatal("解锁失败:", err)
+		}
+		log.Printf("账号 %s 已解锁", *unlock)
+		return
+	}
+
+	// 加载 JWT 密钥
+	if err := utils.LoadJWTKeys(); err != nil {
+		log.Fatal("JWT密钥加载失败:", err)
+	}
+
+	r, trustedProxies := setupRouter()
+
+	// 启动限流状态清理任务
+	go middleware.CleanupRateLimits()
+
+	port := utils.GetEnv("PORT", "8080")
+	ln, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Fatal("服务器启动失败:", err)
+	}
+	// nginx 使用 proxy_protocol 转发时，从 PROXY 头中读取客户端地址
+	if utils.GetEnv("PROXY_PROTOCOL", "false") == "true" {
+		ln = utils.NewProxyProtocolListener(ln, trustedProxies)
+		log.Println("已启用PROXY protocol")
+	}
 
-	// 创建 Gin 路由
-	r := gin.Default()
+	log.Printf("服务器启动成功，监听端口 %s", port)
+	if err := r.RunListener(ln); err != nil {
+		log.Fatal("服务器启动失败:", err)
+	}
+}
+
+// setupRouter 创建 Gin 路由并注册所有接口，返回路由和可信代理列表
+func setupRouter() (*gin.Engine, []*net.IPNet) {
+	// 创建 Gin 路由，访问日志中不记录地址里的凭证
+	r := gin.New()
+	r.Use(middleware.Logger(), gin.Recovery())
+
+	// 可信代理：只有经过可信代理的请求才使用转发头中的客户端 IP
+	trustedProxies, err := middleware.ConfigureClientIP(r)
+	if err != nil {
+		log.Fatal("可信代理配置错误:", err)
+	}
 
 	// 跨域中间件
 	r.Use(middleware.CORS())
 
-	// 启动访问记录清理任务
-	go middleware.CleanupVisitors()
+	// 请求体大小限制：除文件上传外的路由都注册在 base 分组中
+	// 上传文件的路由注册在单独的 uploads 分组中，使用 MAX_IMPORT_BYTES 作为上限
+	base := r.Group("", middleware.BodyLimit(int64(utils.GetEnvInt("MAX_BODY_BYTES", 64<<10))))
 
 	// 公开路由（无需认证）
-	auth := r.Group("/api/auth")
+	auth := base.Group("/api/auth")
 	{
 		// 注册接口：每小时最多3次
 		auth.POST("/register", middleware.RateLimit(3, 3600), controllers.Register)
 		// 登录接口：每分钟最多5次
 		auth.POST("/login", middleware.RateLimit(5, 60), controllers.Login)
+		// 账号锁定后通过邮件解锁
+		auth.POST("/unlock/request", middleware.RateLimit(3, 3600), controllers.RequestUnlock)
+		auth.GET("/unlock", middleware.RedactLogPath(), controllers.UnlockPage)
+		auth.POST("/unlock", controllers.UnlockAccount)
+		// 外部身份提供方（OIDC）登录
+		auth.GET("/oidc/login", controllers.OIDCLogin)
+		auth.GET("/oidc/callback", middleware.RedactLogPath(), controllers.OIDCCallback)
+		// 退出登录（Cookie 认证模式）
+		auth.POST("/logout", controllers.Logout)
 	}
 
+	// JWT 签名公钥
+	base.GET("/.well-known/jwks.json", controllers.GetJWKS)
+
 	// 公开的数据接口（无需认证）
-	r.GET("/api/leaderboard", controllers.GetLeaderboard)
-	r.GET("/api/words/leaderboard/daily", controllers.GetWordDailyLeaderboard)
-	r.GET("/api/words/leaderboard/total", controllers.GetWordTotalLeaderboard)
+	base.GET("/api/leaderboard", controllers.GetLeaderboard)
+	base.GET("/api/words/leaderboard/daily", controllers.GetWordDailyLeaderboard)
+	base.GET("/api/words/leaderboard/total", controllers.GetWordTotalLeaderboard)
+
+	// 日历订阅（地址中的密钥即凭证）
+	base.GET("/api/calendar/:file", middleware.RedactLogPath(), middleware.RateLimit(30, 60), controllers.GetCalendarICS)
 
 	// 需要认证的路由
-	api := r.Group("/api")
+	api := base.Group("/api")
 	api.Use(middleware.AuthMiddleware())
+
+	// 按用户限制写操作频率，配额格式为 次数/时长
+	categoryQuota := middleware.UserQuota("categories", "QUOTA_CATEGORIES", "30/1h")
+	pomodoroQuota := middleware.UserQuota("pomodoros", "QUOTA_POMODOROS", "60/1h")
+	settingQuota := middleware.UserQuota("settings", "QUOTA_SETTINGS", "30/1h")
+	wordQuota := middleware.UserQuota("words", "QUOTA_WORDS", "30/1h")
+	tokenQuota := middleware.UserQuota("tokens", "QUOTA_TOKENS", "10/1h")
+	taskQuota := middleware.UserQuota("tasks", "QUOTA_TASKS", "120/1h")
+	exportQuota := middleware.UserQuota("export", "QUOTA_EXPORT", "10/1h")
+	importQuota := middleware.UserQuota("import", "QUOTA_IMPORT", "20/1h")
 	{
 		// 用户信息
-		api.GET("/profile", controllers.GetProfile)
+		api.GET("/profile", middleware.RequireScope("profile:read"), controllers.GetProfile)
 
 		// 分类管理
-		api.GET("/categories", controllers.GetCategories)
-		api.POST("/categories", controllers.CreateCategory)
-		api.PUT("/categories/:id", controllers.UpdateCategory)
-		api.DELETE("/categories/:id", controllers.DeleteCategory)
+		api.GET("/categories", middleware.RequireScope("categories:read"), controllers.GetCategories)
+		api.POST("/categories", middleware.RequireScope("categories:write"), categoryQuota, controllers.CreateCategory)
+		api.PUT("/categories/:id", middleware.RequireScope("categories:write"), categoryQuota, controllers.UpdateCategory)
+		api.DELETE("/categories/:id", middleware.RequireScope("categories:write"), categoryQuota, controllers.DeleteCategory)
+		api.PUT("/categories/:id/archive", middleware.RequireScope("categories:write"), categoryQuota, controllers.ArchiveCategory)
+		api.POST("/categories/:id/merge", middleware.RequireScope("categories:write"), categoryQuota, controllers.MergeCategory)
 
 		// 番茄钟管理
-		api.POST("/pomodoros", controllers.StartPomodoro)
-		api.PUT("/pomodoros/:id", controllers.CompletePomodoro)
-		api.GET("/pomodoros", controllers.GetPomodoros)
+		api.POST("/pomodoros", middleware.RequireScope("pomodoros:write"), pomodoroQuota, controllers.StartPomodoro)
+		api.POST("/pomodoros/manual", middleware.RequireScope("pomodoros:write"), pomodoroQuota, controllers.CreateManualPomodoro)
+		api.PUT("/pomodoros/:id", middleware.RequireScope("pomodoros:write"), pomodoroQuota, controllers.CompletePomodoro)
+		api.PATCH("/pomodoros/:id", middleware.RequireScope("pomodoros:write"), pomodoroQuota, controllers.UpdatePomodoro)
+		api.DELETE("/pomodoros/:id", middleware.RequireScope("pomodoros:write"), pomodoroQuota, controllers.DeletePomodoro)
+		api.PUT("/pomodoros/:id/rating", middleware.RequireScope("pomodoros:write"), pomodoroQuota, controllers.RatePomodoro)
+		api.GET("/pomodoros", middleware.RequireScope("pomodoros:read"), controllers.GetPomodoros)
+		api.POST("/pomodoros/:id/interruptions", middleware.RequireScope("pomodoros:write"), pomodoroQuota, controllers.CreateInterruption)
+		api.GET("/pomodoros/:id/interruptions", middleware.RequireScope("pomodoros:read"), controllers.GetInterruptions)
+
+		// 任务管理
+		api.GET("/tasks", middleware.RequireScope("tasks:read"), controllers.GetTasks)
+		api.POST("/tasks", middleware.RequireScope("tasks:write"), taskQuota, controllers.CreateTask)
+		api.PUT("/tasks/reorder", middleware.RequireScope("tasks:write"), taskQuota, controllers.ReorderTasks)
+		api.GET("/tasks/:id", middleware.RequireScope("tasks:read"), controllers.GetTask)
+		api.PUT("/tasks/:id", middleware.RequireScope("tasks:write"), taskQuota, controllers.UpdateTask)
+		api.DELETE("/tasks/:id", middleware.RequireScope("tasks:write"), taskQuota, controllers.DeleteTask)
+
+		// 每日计划与回顾（date 为 YYYY-MM-DD 或 today）
+		api.GET("/plans", middleware.RequireScope("tasks:read"), controllers.GetDailyPlans)
+		api.GET("/plans/:date", middleware.RequireScope("tasks:read"), controllers.GetDailyPlan)
+		api.PUT("/plans/:date", middleware.RequireScope("tasks:write"), taskQuota, controllers.SaveDailyPlan)
+		api.PUT("/plans/:date/review", middleware.RequireScope("tasks:write"), taskQuota, controllers.SaveDailyReview)
+
+		// 标签管理
+		api.GET("/tags", middleware.RequireScope("pomodoros:read"), controllers.GetTags)
+		api.PUT("/tags/:id", middleware.RequireScope("pomodoros:write"), pomodoroQuota, controllers.UpdateTag)
+		api.DELETE("/tags/:id", middleware.RequireScope("pomodoros:write"), pomodoroQuota, controllers.DeleteTag)
 
 		// 统计数据
-		api.GET("/stats", controllers.GetStats)
-		api.GET("/stats/total", controllers.GetTotalDuration)
-		api.GET("/stats/categories", controllers.GetCategoryStats)
-		api.GET("/stats/daily", controllers.GetDailyStats)
-		api.GET("/stats/checkin", controllers.GetCheckinStats)
+		api.GET("/stats", middleware.RequireScope("stats:read"), controllers.GetStats)
+		api.GET("/stats/total", middleware.RequireScope("stats:read"), controllers.GetTotalDuration)
+		api.GET("/stats/categories", middleware.RequireScope("stats:read"), controllers.GetCategoryStats)
+		api.GET("/stats/daily", middleware.RequireScope("stats:read"), controllers.GetDailyStats)
+		api.GET("/stats/checkin", middleware.RequireScope("stats:read"), controllers.GetCheckinStats)
+		api.GET("/stats/tags", middleware.RequireScope("stats:read"), controllers.GetTagStats)
+		api.GET("/stats/tasks", middleware.RequireScope("stats:read"), controllers.GetTaskStats)
+		api.GET("/stats/interruptions", middleware.RequireScope("stats:read"), controllers.GetInterruptionStats)
+		api.GET("/stats/focus", middleware.RequireScope("stats:read"), controllers.GetFocusStats)
 
 		// 用户设置
-		api.GET("/settings", controllers.GetSettings)
-		api.PUT("/settings", controllers.UpdateSettings)
+		api.GET("/settings", middleware.RequireScope("settings:read"), controllers.GetSettings)
+		api.PUT("/settings", middleware.RequireScope("settings:write"), settingQuota, controllers.UpdateSettings)
 
 		// 单词记录
-		api.POST("/words", controllers.SubmitWordCount)
-		api.GET("/words", controllers.GetWordRecords)
-		api.GET("/words/today", controllers.GetTodayWordCount)
-		api.GET("/words/stats", controllers.GetWordStats)
-		api.DELETE("/words/:id", controllers.DeleteWordRecord)
+		api.POST("/words", middleware.RequireScope("words:write"), wordQuota, controllers.SubmitWordCount)
+		api.GET("/words", middleware.RequireScope("words:read"), controllers.GetWordRecords)
+		api.GET("/words/today", middleware.RequireScope("words:read"), controllers.GetTodayWordCount)
+		api.GET("/words/stats", middleware.RequireScope("words:read"), controllers.GetWordStats)
+		api.DELETE("/words/:id", middleware.RequireScope("words:write"), wordQuota, controllers.DeleteWordRecord)
+
+		// 账号相关的审计记录
+		api.GET("/audit", middleware.RequireSession(), controllers.GetAuditEvents)
+
+		// 日历订阅地址管理
+		api.GET("/calendar/feed", middleware.RequireSession(), controllers.GetCalendarFeed)
+		api.POST("/calendar/feed", middleware.RequireSession(), tokenQuota, controllers.RotateCalendarFeed)
+		api.DELETE("/calendar/feed", middleware.RequireSession(), tokenQuota, controllers.DeleteCalendarFeed)
+
+		// 数据导出
+		api.GET("/export", middleware.RequireScope("export:read"), exportQuota, controllers.ExportData)
+
+		// 日历导入的订阅地址和关键字匹配规则
+		api.GET("/calendar/import/settings", middleware.RequireScope("tasks:read"), controllers.GetCalendarImportSettings)
+		api.PUT("/calendar/import/settings", middleware.RequireScope("tasks:write"), taskQuota, controllers.SaveCalendarImportSettings)
+
+		// 个人访问令牌（只能在登录会话中管理）
+		tokens := api.Group("/tokens", middleware.RequireSession())
+		{
+			tokens.GET("", controllers.GetAPITokens)
+			tokens.POST("", tokenQuota, controllers.CreateAPIToken)
+			tokens.DELETE("/:id", controllers.RevokeAPIToken)
+		}
+
+		// 管理接口：版主可管理排行榜，管理员可管理账号（用户列表包含邮箱，只有管理员可查看）
+		admin := api.Group("/admin", middleware.RequireSession(), middleware.RequireRole(models.RoleModerator, models.RoleAdmin))
+		{
+			admin.PUT("/users/:id/leaderboard", controllers.AdminSetLeaderboardHidden)
+			admin.DELETE("/pomodoros/:id", controllers.AdminDeletePomodoro)
+			admin.DELETE("/words/:id", controllers.AdminDeleteWordRecord)
+
+			adminOnly := admin.Group("", middleware.RequireRole(models.RoleAdmin))
+			adminOnly.GET("/users", controllers.AdminGetUsers)
+			adminOnly.GET("/users/:id/stats", controllers.AdminGetUserStats)
+			adminOnly.PUT("/users/:id/disabled", controllers.AdminSetUserDisabled)
+			adminOnly.PUT("/users/:id/role", controllers.AdminSetUserRole)
+			adminOnly.POST("/users/:id/reset-password", controllers.AdminResetPassword)
+			adminOnly.POST("/users/:id/unlock", controllers.AdminUnlockUser)
+			adminOnly.GET("/audit", controllers.AdminGetAuditEvents)
+		}
 	}
 
-	// 静态文件托管
-	r.Static("/css", "/www/wwwroot/pomodoro-frontend/css")
-	r.Static("/js", "/www/wwwroot/pomodoro-frontend/js")
-	r.StaticFile("/", "/www/wwwroot/pomodoro-frontend/index.html")
-	r.StaticFile("/index.html", "/www/wwwroot/pomodoro-frontend/index.html")
-
-	log.Println("服务器启动成功，监听端口 8080")
-	if err := r.Run(":8080"); err != nil {
-		log.Fatal("服务器启动失败:", err)
+	// 文件上传：不经过 base 分组的请求体限制，使用更大的上限
+	uploads := r.Group("/api", middleware.BodyLimit(int64(utils.GetEnvInt("MAX_IMPORT_BYTES", 5<<20))), middleware.AuthMiddleware())
+	{
+		// 从其他应用导入番茄钟
+		uploads.POST("/import/pomodoros/preview", middleware.RequireScope("pomodoros:write"), importQuota, controllers.PreviewImport)
+		uploads.POST("/import/pomodoros", middleware.RequireScope("pomodoros:write"), importQuota, controllers.ImportPomodoros)
+		// 从日历导入计划任务，按关键字规则匹配分类
+		uploads.POST("/calendar/import", middleware.RequireScope("tasks:write"), importQuota, controllers.ImportCalendar)
 	}
+
+	// 静态文件托管（附加安全响应头）
+	static := base.Group("/", middleware.SecurityHeaders())
+	static.Static("/css", "/www/wwwroot/pomodoro-frontend/css")
+	static.Static("/js", "/www/wwwroot/pomodoro-frontend/js")
+	static.StaticFile("/", "/www/wwwroot/pomodoro-frontend/index.html")
+	static.StaticFile("/index.html", "/www/wwwroot/pomodoro-frontend/index.html")
+
+	return r, trustedProxies
 }
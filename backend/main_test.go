@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"pomodoro-api/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// setupTestServer 使用临时数据库创建完整路由，返回路由和测试用户的登录令牌
+func setupTestServer(t *testing.T) (*gin.Engine, string) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previous
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := utils.LoadJWTKeys(); err != nil {
+		t.Fatalf("加载 JWT 密钥失败: %v", err)
+	}
+	user := models.User{Username: "alice", Email: "alice@example.com", Password: "-"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	db.Create(&models.Setting{UserID: user.ID, DefaultDuration: 1500})
+	token, err := utils.GenerateToken(user.ID)
+	if err != nil {
+		t.Fatalf("生成令牌失败: %v", err)
+	}
+
+	r, _ := setupRouter()
+	return r, token
+}
+
+// uploadFile 以 multipart 表单上传文件
+func uploadFile(r http.Handler, token, path, filename string, content []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", filename)
+	part.Write(content)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestImportPreviewAcceptsLargeUpload(t *testing.T) {
+	r, token := setupTestServer(t)
+
+	// 超过默认 64KB 请求体上限的 CSV
+	var csv strings.Builder
+	csv.WriteString("start,end,category,note\n")
+	start := time.Date(2026, 1, 5, 8, 0, 0, 0, time.Local)
+	rows := 0
+	for csv.Len() <= 100<<10 {
+		begin := start.Add(time.Duration(rows) * 30 * time.Minute)
+		fmt.Fprintf(&csv, "%s,%s,学习,%s\n", begin.Format("2006-01-02 15:04"),
+			begin.Add(25*time.Minute).Format("2006-01-02 15:04"), strings.Repeat("复习", 50))
+		rows++
+	}
+
+	w := uploadFile(r, token, "/api/import/pomodoros/preview", "history.csv", []byte(csv.String()))
+	if w.Code != http.StatusOK {
+		t.Fatalf("预览 %d 字节的 CSV 状态码 = %d, body=%s", csv.Len(), w.Code, w.Body.String())
+	}
+	var report struct {
+		Total    int `json:"total"`
+		Imported int `json:"imported"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	if report.Total != rows || report.Imported != rows {
+		t.Errorf("total=%d imported=%d, 都应为 %d", report.Total, report.Imported, rows)
+	}
+
+	// 其他接口仍使用默认上限
+	req := httptest.NewRequest(http.MethodPost, "/api/categories", strings.NewReader(csv.String()))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("普通接口提交大请求体状态码 = %d, 应为 413", w.Code)
+	}
+}
+
+func TestCalendarImportAcceptsLargeUpload(t *testing.T) {
+	r, token := setupTestServer(t)
+
+	// 带长描述的日历文件，超过默认 64KB 请求体上限
+	var ics strings.Builder
+	ics.WriteString("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n")
+	now := time.Now()
+	day := time.Date(now.Year(), now.Month(), now.Day()+1, 9, 0, 0, 0, time.Local)
+	events := 20
+	for i := 0; i < events; i++ {
+		begin := day.Add(time.Duration(i) * 30 * time.Minute).UTC()
+		fmt.Fprintf(&ics, "BEGIN:VEVENT\r\nUID:event-%d@test\r\nSUMMARY:会议 %d\r\nDTSTART:%s\r\nDTEND:%s\r\nDESCRIPTION:%s\r\nEND:VEVENT\r\n",
+			i, i, begin.Format("20060102T150405Z"), begin.Add(25*time.Minute).Format("20060102T150405Z"), strings.Repeat("x", 5000))
+	}
+	ics.WriteString("END:VCALENDAR\r\n")
+	if ics.Len() <= 64<<10 {
+		t.Fatalf("测试文件只有 %d 字节", ics.Len())
+	}
+
+	w := uploadFile(r, token, "/api/calendar/import?dry_run=true&include_unmatched=true", "work.ics", []byte(ics.String()))
+	if w.Code != http.StatusOK {
+		t.Fatalf("导入 %d 字节的日历状态码 = %d, body=%s", ics.Len(), w.Code, w.Body.String())
+	}
+	var report struct {
+		Imported int `json:"imported"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	if report.Imported != events {
+		t.Errorf("imported = %d, want %d", report.Imported, events)
+	}
+}
+
+// authRequest 以 JSON 发送带登录令牌的请求
+func authRequest(r http.Handler, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// createRoleUser 创建指定角色的用户并返回其登录令牌
+func createRoleUser(t *testing.T, name, role string) (*models.User, string) {
+	t.Helper()
+	user := models.User{Username: name, Email: name + "@example.com", Password: "-", Role: role}
+	if err := database.DB.Create(&user).Error; err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	token, err := utils.GenerateToken(user.ID)
+	if err != nil {
+		t.Fatalf("生成令牌失败: %v", err)
+	}
+	return &user, token
+}
+
+func TestAdminRoutesRequireRole(t *testing.T) {
+	r, userToken := setupTestServer(t)
+	_, moderatorToken := createRoleUser(t, "mod", models.RoleModerator)
+	_, adminToken := createRoleUser(t, "root", models.RoleAdmin)
+	target, _ := createRoleUser(t, "bob", models.RoleUser)
+	id := fmt.Sprint(target.ID)
+
+	tests := []struct {
+		method, path string
+		body         interface{}
+		user         int // 普通用户、版主、管理员的预期状态码
+		moderator    int
+		admin        int
+	}{
+		{http.MethodGet, "/api/admin/users", nil, 403, 403, 200},
+		{http.MethodGet, "/api/admin/users/" + id + "/stats", nil, 403, 403, 200},
+		{http.MethodPut, "/api/admin/users/" + id + "/leaderboard", gin.H{"hidden": true}, 403, 200, 200},
+		{http.MethodPut, "/api/admin/users/" + id + "/role", gin.H{"role": models.RoleModerator}, 403, 403, 200},
+		{http.MethodPut, "/api/admin/users/" + id + "/disabled", gin.H{"disabled": false}, 403, 403, 200},
+		{http.MethodPost, "/api/admin/users/" + id + "/unlock", nil, 403, 403, 200},
+		{http.MethodGet, "/api/admin/audit", nil, 403, 403, 200},
+	}
+	for _, tt := range tests {
+		for _, who := range []struct {
+			name  string
+			token string
+			want  int
+		}{{"普通用户", userToken, tt.user}, {"版主", moderatorToken, tt.moderator}, {"管理员", adminToken, tt.admin}} {
+			if w := authRequest(r, tt.method, tt.path, who.token, tt.body); w.Code != who.want {
+				t.Errorf("%s %s %s 状态码 = %d, want %d, body=%s", who.name, tt.method, tt.path, w.Code, who.want, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestAdminResetPasswordRevokesSessions(t *testing.T) {
+	r, _ := setupTestServer(t)
+	_, adminToken := createRoleUser(t, "root", models.RoleAdmin)
+	target, targetToken := createRoleUser(t, "bob", models.RoleUser)
+
+	if w := authRequest(r, http.MethodGet, "/api/settings", targetToken, nil); w.Code != http.StatusOK {
+		t.Fatalf("重置前状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+	w := authRequest(r, http.MethodPost, fmt.Sprintf("/api/admin/users/%d/reset-password", target.ID), adminToken, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("重置密码状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+	if w := authRequest(r, http.MethodGet, "/api/settings", targetToken, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("重置前签发的令牌状态码 = %d, want 401", w.Code)
+	}
+	// 管理员自己的会话不受影响
+	if w := authRequest(r, http.MethodGet, "/api/admin/users", adminToken, nil); w.Code != http.StatusOK {
+		t.Errorf("管理员令牌状态码 = %d, want 200", w.Code)
+	}
+
+	// 重置之后登录获得的令牌有效（签发时间只精确到秒，把修改时间提前以免落在同一秒）
+	database.DB.Model(target).Update("password_changed_at", time.Now().Add(-2*time.Second))
+	newToken, _ := utils.GenerateToken(target.ID)
+	if w := authRequest(r, http.MethodGet, "/api/settings", newToken, nil); w.Code != http.StatusOK {
+		t.Errorf("重置后签发的令牌状态码 = %d, want 200", w.Code)
+	}
+}
+
+// createAPIToken 通过接口创建个人访问令牌，返回明文
+func createAPIToken(t *testing.T, r http.Handler, sessionToken string, scopes ...string) string {
+	t.Helper()
+	w := authRequest(r, http.MethodPost, "/api/tokens", sessionToken, gin.H{"name": "脚本", "scopes": scopes})
+	if w.Code != http.StatusOK {
+		t.Fatalf("创建令牌状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	return resp.Token
+}
+
+func TestAPITokenStoredAsHash(t *testing.T) {
+	r, session := setupTestServer(t)
+	plain := createAPIToken(t, r, session, "settings:read")
+	if !strings.HasPrefix(plain, models.APITokenPrefix) {
+		t.Fatalf("令牌 %q 缺少前缀", plain)
+	}
+
+	var token models.APIToken
+	database.DB.First(&token)
+	if token.TokenHash != utils.HashToken(plain) || strings.Contains(token.TokenHash, plain[len(models.APITokenPrefix):]) {
+		t.Errorf("数据库中应只保存摘要: %+v", token)
+	}
+	if !strings.HasPrefix(plain, token.Prefix) || len(token.Prefix) != len(models.APITokenPrefix)+6 {
+		t.Errorf("prefix = %q", token.Prefix)
+	}
+	// 列表中不返回明文和摘要
+	if w := authRequest(r, http.MethodGet, "/api/tokens", session, nil); strings.Contains(w.Body.String(), plain) || strings.Contains(w.Body.String(), token.TokenHash) {
+		t.Errorf("令牌列表泄露了令牌: %s", w.Body.String())
+	}
+
+	if w := authRequest(r, http.MethodGet, "/api/settings", plain, nil); w.Code != http.StatusOK {
+		t.Fatalf("使用令牌状态码 = %d, body=%s", w.Code, w.Body.String())
+	}
+	database.DB.First(&token, token.ID)
+	if token.LastUsedAt == nil || token.LastUsedIP == "" {
+		t.Errorf("使用后应记录最后使用时间和 IP: %+v", token)
+	}
+
+	// 明文不同、过期或已吊销的令牌无效
+	if w := authRequest(r, http.MethodGet, "/api/settings", plain+"x", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("错误的令牌状态码 = %d, want 401", w.Code)
+	}
+	expired := createAPIToken(t, r, session, "settings:read")
+	database.DB.Model(&models.APIToken{}).Where("token_hash = ?", utils.HashToken(expired)).Update("expires_at", time.Now().Add(-time.Minute))
+	if w := authRequest(r, http.MethodGet, "/api/settings", expired, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("过期令牌状态码 = %d, want 401", w.Code)
+	}
+	if w := authRequest(r, http.MethodDelete, fmt.Sprintf("/api/tokens/%d", token.ID), session, nil); w.Code != http.StatusOK {
+		t.Fatalf("吊销令牌状态码 = %d", w.Code)
+	}
+	if w := authRequest(r, http.MethodGet, "/api/settings", plain, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("已吊销令牌状态码 = %d, want 401", w.Code)
+	}
+}
+
+func TestAPITokenScopes(t *testing.T) {
+	r, session := setupTestServer(t)
+	readOnly := createAPIToken(t, r, session, "settings:read")
+	writeOnly := createAPIToken(t, r, session, "settings:write")
+	other := createAPIToken(t, r, session, "pomodoros:read")
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		want   int
+	}{
+		{"read 令牌读取", readOnly, http.MethodGet, http.StatusOK},
+		{"read 令牌写入", readOnly, http.MethodPut, http.StatusForbidden},
+		{"write 令牌包含 read", writeOnly, http.MethodGet, http.StatusOK},
+		{"write 令牌写入", writeOnly, http.MethodPut, http.StatusOK},
+		{"其他资源的令牌", other, http.MethodGet, http.StatusForbidden},
+		{"登录会话不受限制", session, http.MethodPut, http.StatusOK},
+	}
+	for _, tt := range tests {
+		var body interface{}
+		if tt.method == http.MethodPut {
+			body = gin.H{"default_duration": 1500}
+		}
+		if w := authRequest(r, tt.method, "/api/settings", tt.token, body); w.Code != tt.want {
+			t.Errorf("%s: 状态码 = %d, want %d, body=%s", tt.name, w.Code, tt.want, w.Body.String())
+		}
+	}
+}
+
+func TestAPITokenCannotUseSessionOnlyRoutes(t *testing.T) {
+	r, session := setupTestServer(t)
+	var user models.User
+	database.DB.First(&user)
+	database.DB.Model(&user).Update("role", models.RoleAdmin)
+
+	// 拥有全部权限范围的令牌也不能管理令牌或访问管理接口
+	token := createAPIToken(t, r, session, models.APITokenScopes...)
+	for _, route := range []struct{ method, path string }{
+		{http.MethodGet, "/api/tokens"},
+		{http.MethodPost, "/api/tokens"},
+		{http.MethodDelete, "/api/tokens/1"},
+		{http.MethodGet, "/api/admin/users"},
+		{http.MethodGet, "/api/calendar/feed"},
+	} {
+		if w := authRequest(r, route.method, route.path, token, gin.H{"name": "x", "scopes": []string{"profile:read"}}); w.Code != http.StatusForbidden {
+			t.Errorf("%s %s 状态码 = %d, want 403", route.method, route.path, w.Code)
+		}
+	}
+	if w := authRequest(r, http.MethodGet, "/api/tokens", session, nil); w.Code != http.StatusOK {
+		t.Errorf("登录会话管理令牌状态码 = %d, want 200", w.Code)
+	}
+}
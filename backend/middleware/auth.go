@@ -1,19 +1,34 @@
 package middleware
 
 import (
-	"log"
 	"net/http"
-	"os"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
 	"pomodoro-api/utils"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 // AuthMiddleware JWT 认证中间件
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
+
+		// Cookie 认证模式：没有 Authorization 头时使用登录 Cookie，写请求需通过 CSRF 校验
+		if authHeader == "" && CookieAuthEnabled() {
+			if token, err := c.Cookie(SessionCookieName); err == nil && token != "" {
+				if !validCSRF(c) {
+					c.JSON(http.StatusForbidden, gin.H{"error": "CSRF 校验失败"})
+					c.Abort()
+					return
+				}
+				authHeader = "Bearer " + token
+			}
+		}
+
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "缺少认证令牌"})
 			c.Abort()
@@ -28,6 +43,24 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 个人访问令牌
+		if strings.HasPrefix(parts[1], models.APITokenPrefix) {
+			token, ok := lookupAPIToken(c, parts[1])
+			if !ok {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的认证令牌"})
+				c.Abort()
+				return
+			}
+
+			if !setActiveUser(c, token.UserID, nil) {
+				return
+			}
+			c.Set("auth_type", AuthTypeAPIToken)
+			c.Set("token_scopes", token.ScopeList())
+			c.Next()
+			return
+		}
+
 		// 解析 Token
 		claims, err := utils.ParseToken(parts[1])
 		if err != nil {
@@ -37,49 +70,109 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		// 将用户 ID 存入上下文
-		c.Set("user_id", claims.UserID)
+		if !setActiveUser(c, claims.UserID, claims.IssuedAt) {
+			return
+		}
+		c.Set("auth_type", AuthTypeSession)
 		c.Next()
 	}
 }
 
-// CORS 跨域中间件（支持环境变量配置）
-func CORS() gin.HandlerFunc {
-	// 从环境变量获取允许的域名
-	allowedOrigin := getAllowedOrigin()
-	log.Printf("CORS配置: 允许的域名 = %s", allowedOrigin)
+// setActiveUser 检查用户状态并把用户 ID、角色存入上下文，停用的账号立即失效
+// issuedAt 为登录令牌的签发时间，修改密码之前签发的令牌失效；个人访问令牌传 nil
+func setActiveUser(c *gin.Context, userID uint, issuedAt *jwt.NumericDate) bool {
+	var user models.User
+	if err := database.DB.Select("id", "role", "disabled", "password_changed_at").First(&user, userID).Error; err != nil || user.Disabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "账号不存在或已被停用"})
+		c.Abort()
+		return false
+	}
+	// 签发时间只精确到秒，修改密码的同一秒内签发的令牌也视为失效
+	if issuedAt != nil && user.PasswordChangedAt != nil && issuedAt.Before(*user.PasswordChangedAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "密码已修改，请重新登录"})
+		c.Abort()
+		return false
+	}
+
+	c.Set("user_id", user.ID)
+	c.Set("role", user.Role)
+	return true
+}
 
+// RequireRole 要求当前用户具备指定角色之一
+func RequireRole(roles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		origin := c.Request.Header.Get("Origin")
-
-		// 检查请求来源是否在允许列表中
-		if allowedOrigin == "*" || origin == allowedOrigin {
-			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
-		} else if allowedOrigin != "*" {
-			// 如果设置了特定域名但请求来源不匹配，只允许配置的域名
-			c.Writer.Header().Set("Access-Control-Allow-Origin", allowedOrigin)
+		role := c.GetString("role")
+		for _, r := range roles {
+			if r == role {
+				c.Next()
+				return
+			}
 		}
 
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.JSON(http.StatusForbidden, gin.H{"error": "没有权限执行该操作"})
+		c.Abort()
+	}
+}
+
+// 认证方式
+const (
+	AuthTypeSession  = "session"   // 登录获得的 JWT
+	AuthTypeAPIToken = "api_token" // 个人访问令牌
+)
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+// lookupAPIToken 校验个人访问令牌并更新最后使用时间
+func lookupAPIToken(c *gin.Context, raw string) (*models.APIToken, bool) {
+	var token models.APIToken
+	if err := database.DB.Where("token_hash = ?", utils.HashToken(raw)).First(&token).Error; err != nil {
+		return nil, false
+	}
+
+	now := time.Now()
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return nil, false
+	}
+
+	// 最多每分钟写一次，避免频繁请求时反复写库
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > time.Minute {
+		database.DB.Model(&token).Updates(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": c.ClientIP(),
+		})
+	}
+	return &token, true
+}
+
+// RequireScope 要求个人访问令牌具备指定权限范围，登录会话不受限制
+// xxx:write 权限同时满足 xxx:read
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("auth_type") != AuthTypeAPIToken {
+			c.Next()
 			return
 		}
 
-		c.Next()
+		resource := strings.SplitN(scope, ":", 2)[0]
+		for _, s := range c.GetStringSlice("token_scopes") {
+			if s == scope || (strings.HasSuffix(scope, ":read") && s == resource+":write") {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "令牌权限不足，需要 " + scope})
+		c.Abort()
 	}
 }
 
-// getAllowedOrigin 获取允许的跨域源
-func getAllowedOrigin() string {
-	origin := os.Getenv("ALLOWED_ORIGIN")
-	if origin == "" {
-		// 默认允许服务器IP（开发环境）
-		defaultOrigin := "http://124.220.224.91"
-		log.Println("警告: 未设置ALLOWED_ORIGIN环境变量，使用默认配置（仅用于开发）")
-		return defaultOrigin
+// RequireSession 要求使用登录会话访问（个人访问令牌不能管理令牌等敏感操作）
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("auth_type") != AuthTypeSession {
+			c.JSON(http.StatusForbidden, gin.H{"error": "该操作需要登录后进行"})
+			c.Abort()
+			return
+		}
+		c.Next()
 	}
-	return origin
 }
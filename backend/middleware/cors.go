@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"pomodoro-api/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	corsAllowMethods = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	corsAllowHeaders = "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With"
+	// 默认暴露给前端的响应头：限流信息
+	corsDefaultExposeHeaders = "RateLimit-Policy, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, Retry-After, X-Total-Count, X-Next-Cursor"
+)
+
+// originPattern 通配子域名规则，如 https://*.example.com
+type originPattern struct {
+	scheme string
+	suffix string // 以点开头的域名后缀，如 .example.com
+	port   string
+}
+
+// match 判断来源是否为规则域名的子域名（不含域名本身）
+func (p originPattern) match(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	return u.Scheme == p.scheme &&
+		u.Port() == p.port &&
+		len(host) > len(p.suffix) &&
+		strings.HasSuffix(host, p.suffix)
+}
+
+// CORSPolicy 跨域策略
+type CORSPolicy struct {
+	allowAll      bool
+	origins       map[string]bool
+	patterns      []originPattern
+	maxAge        string
+	exposeHeaders string
+}
+
+// NewCORSPolicy 根据允许的来源列表创建跨域策略
+// 来源可以是完整来源（https://example.com、chrome-extension://id、null）、
+// 通配子域名（https://*.example.com）或 *（允许任意来源，但不允许携带凭证）
+func NewCORSPolicy(origins []string, maxAge time.Duration, exposeHeaders string) *CORSPolicy {
+	policy := &CORSPolicy{
+		origins:       make(map[string]bool),
+		maxAge:        strconv.Itoa(int(maxAge.Seconds())),
+		exposeHeaders: exposeHeaders,
+	}
+
+	for _, origin := range origins {
+		origin = strings.TrimRight(origin, "/")
+		if origin == "*" {
+			policy.allowAll = true
+			continue
+		}
+
+		if scheme, rest, ok := strings.Cut(origin, "://*."); ok {
+			host, port, _ := strings.Cut(rest, ":")
+			policy.patterns = append(policy.patterns, originPattern{
+				scheme: strings.ToLower(scheme),
+				suffix: "." + strings.ToLower(host),
+				port:   port,
+			})
+			continue
+		}
+
+		policy.origins[strings.ToLower(origin)] = true
+	}
+
+	return policy
+}
+
+// Allowed 判断请求来源是否被允许
+func (p *CORSPolicy) Allowed(origin string) bool {
+	if p.allowAll || p.origins[strings.ToLower(origin)] {
+		return true
+	}
+	if len(p.patterns) == 0 {
+		return false
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	for _, pattern := range p.patterns {
+		if pattern.match(u) {
+			return true
+		}
+	}
+	return false
+}
+
+// CORS 跨域中间件（支持环境变量配置）
+func CORS() gin.HandlerFunc {
+	origins := getAllowedOrigins()
+	exposeHeaders := utils.GetEnv("CORS_EXPOSE_HEADERS", corsDefaultExposeHeaders)
+	policy := NewCORSPolicy(origins, utils.GetEnvDuration("CORS_MAX_AGE", 12*time.Hour), exposeHeaders)
+	log.Printf("CORS配置: 允许的域名 = %s", strings.Join(origins, ", "))
+
+	return policy.Handler()
+}
+
+// Handler 返回应用该策略的中间件
+func (p *CORSPolicy) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		// 响应内容随 Origin 变化，避免缓存把一个来源的响应返回给另一个来源
+		if !p.allowAll {
+			header.Add("Vary", "Origin")
+		}
+
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		// 非跨域请求直接放行；不允许的来源不返回任何 CORS 头，由浏览器拦截
+		if origin == "" || !p.Allowed(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if p.allowAll {
+			// 任意来源时不能携带凭证
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", corsAllowMethods)
+			header.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			header.Set("Access-Control-Max-Age", p.maxAge)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		if p.exposeHeaders != "" {
+			header.Set("Access-Control-Expose-Headers", p.exposeHeaders)
+		}
+
+		c.Next()
+	}
+}
+
+// getAllowedOrigins 获取允许的跨域源，兼容旧的 ALLOWED_ORIGIN 配置
+func getAllowedOrigins() []string {
+	origins := utils.GetEnvList("ALLOWED_ORIGINS")
+	origins = append(origins, utils.GetEnvList("ALLOWED_ORIGIN")...)
+	if len(origins) == 0 {
+		// 默认允许服务器IP（开发环境）
+		log.Println("警告: 未设置ALLOWED_ORIGINS环境变量，使用默认配置（仅用于开发）")
+		return []string{"http://124.220.224.91"}
+	}
+	return origins
+}
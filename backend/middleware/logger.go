@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redactedPathKey 访问日志中代替实际地址的路由模板
+const redactedPathKey = "log_redacted_path"
+
+// RedactLogPath 地址或查询参数中带有凭证的路由（如日历订阅密钥），
+// 访问日志只记录路由模板，不记录实际地址
+func RedactLogPath() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(redactedPathKey, c.FullPath())
+		c.Next()
+	}
+}
+
+// Logger 访问日志，格式与 gin 默认的相同，RedactLogPath 标记的路由只记录路由模板
+func Logger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		if path, ok := param.Keys[redactedPathKey].(string); ok {
+			param.Path = path
+		}
+
+		var statusColor, methodColor, resetColor string
+		if param.IsOutputColor() {
+			statusColor = param.StatusCodeColor()
+			methodColor = param.MethodColor()
+			resetColor = param.ResetColor()
+		}
+		if param.Latency > time.Minute {
+			param.Latency = param.Latency.Truncate(time.Second)
+		}
+		return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			statusColor, param.StatusCode, resetColor,
+			param.Latency,
+			param.ClientIP,
+			methodColor, param.Method, resetColor,
+			param.Path,
+			param.ErrorMessage,
+		)
+	})
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLoggerRedactsCredentialPaths(t *testing.T) {
+	var buf bytes.Buffer
+	previous := gin.DefaultWriter
+	gin.DefaultWriter = &buf
+	defer func() { gin.DefaultWriter = previous }()
+
+	r := gin.New()
+	r.Use(Logger())
+	r.GET("/api/calendar/:file", RedactLogPath(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/api/auth/unlock", RedactLogPath(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/api/pomodoros", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, path := range []string{"/api/calendar/cal_secret123.ics", "/api/auth/unlock?token=secret456", "/api/pomodoros?limit=10"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	logs := buf.String()
+	for _, secret := range []string{"secret123", "secret456"} {
+		if strings.Contains(logs, secret) {
+			t.Errorf("访问日志中包含凭证 %s:\n%s", secret, logs)
+		}
+	}
+	for _, want := range []string{`"/api/calendar/:file"`, `"/api/auth/unlock"`, `"/api/pomodoros?limit=10"`} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("访问日志中缺少 %s:\n%s", want, logs)
+		}
+	}
+}
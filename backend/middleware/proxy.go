@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"log"
+	"net"
+	"pomodoro-api/utils"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrustedProxies 可信代理列表（TRUSTED_PROXIES，IP 或 CIDR，逗号分隔）
+// 默认只信任本机的 nginx；设置为 none 表示不信任任何代理
+func TrustedProxies() []string {
+	proxies := utils.GetEnvList("TRUSTED_PROXIES")
+	if len(proxies) == 0 {
+		return []string{"127.0.0.1", "::1"}
+	}
+	if len(proxies) == 1 && strings.EqualFold(proxies[0], "none") {
+		return nil
+	}
+	return proxies
+}
+
+var (
+	trustedProxyOnce sync.Once
+	trustedProxyNets []*net.IPNet
+)
+
+// IsTrustedProxy 判断请求的连接地址（不是转发头中的地址）是否为可信代理
+func IsTrustedProxy(c *gin.Context) bool {
+	trustedProxyOnce.Do(func() {
+		nets, err := utils.ParseCIDRs(TrustedProxies())
+		if err != nil {
+			log.Printf("可信代理配置错误: %v", err)
+		}
+		trustedProxyNets = nets
+	})
+
+	ip := net.ParseIP(c.RemoteIP())
+	if ip == nil {
+		return false
+	}
+	for _, n := range trustedProxyNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigureClientIP 配置 c.ClientIP() 的解析方式
+// 只有来自可信代理的请求才会读取 REMOTE_IP_HEADERS 中的请求头，其他请求一律使用连接地址，
+// 限流、登录记录和审计日志因此拿到的是同一个真实客户端 IP
+func ConfigureClientIP(r *gin.Engine) ([]*net.IPNet, error) {
+	proxies := TrustedProxies()
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		return nil, err
+	}
+
+	headers := utils.GetEnvList("REMOTE_IP_HEADERS")
+	if len(headers) == 0 {
+		headers = []string{"X-Real-IP", "X-Forwarded-For"}
+	}
+	r.RemoteIPHeaders = headers
+	r.ForwardedByClientIP = true
+
+	log.Printf("可信代理: %v，客户端IP请求头: %v", proxies, headers)
+	return utils.ParseCIDRs(proxies)
+}
@@ -1,96 +1,280 @@
 package middleware
 
 import (
+	"fmt"
 	"log"
+	"math"
 	"net/http"
-	"sync"
+	"pomodoro-api/utils"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// visitor 记录访问者信息
-type visitor struct {
-	lastSeen time.Time
-	count    int
+// clock 限流使用的当前时间，测试中可替换
+var clock = time.Now
+
+// Result 一次限流判断的结果
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // 距离配额完全恢复的时间
+	RetryAfter time.Duration // 被拒绝时建议等待的时间
 }
 
-var (
-	visitors = make(map[string]*visitor)
-	mu       sync.RWMutex
-)
+// Limiter 限流器
+type Limiter interface {
+	Allow(key string) (Result, error)
+	// Policy 返回 RateLimit-Policy 响应头的值，如 5;w=60
+	Policy() string
+}
+
+// TokenBucket 令牌桶：以固定速率补充令牌，允许不超过 Burst 的突发请求
+type TokenBucket struct {
+	Rate  float64 // 每秒补充的令牌数
+	Burst int     // 桶容量
+	Store Store
+}
+
+// NewTokenBucket 创建令牌桶，每个 per 时长补充 limit 个令牌
+func NewTokenBucket(limit int, per time.Duration, store Store) *TokenBucket {
+	return &TokenBucket{
+		Rate:  float64(limit) / per.Seconds(),
+		Burst: limit,
+		Store: store,
+	}
+}
+
+// Allow 尝试取出一个令牌
+func (b *TokenBucket) Allow(key string) (Result, error) {
+	fill := time.Duration(float64(b.Burst) / b.Rate * float64(time.Second))
+
+	return b.Store.Update(key, fill, func(s *State) Result {
+		now := clock()
+		if s.Stamp.IsZero() {
+			s.Value = float64(b.Burst)
+		} else {
+			s.Value = math.Min(float64(b.Burst), s.Value+now.Sub(s.Stamp).Seconds()*b.Rate)
+		}
+		s.Stamp = now
+
+		r := Result{Limit: b.Burst}
+		if s.Value >= 1 {
+			s.Value--
+			r.Allowed = true
+		} else {
+			r.RetryAfter = secondsToDuration((1 - s.Value) / b.Rate)
+		}
+		r.Remaining = int(math.Floor(s.Value))
+		r.Reset = secondsToDuration((float64(b.Burst) - s.Value) / b.Rate)
+		return r
+	})
+}
+
+// Policy 返回限流策略描述
+func (b *TokenBucket) Policy() string {
+	return fmt.Sprintf("%d;w=%d", b.Burst, int(math.Ceil(float64(b.Burst)/b.Rate)))
+}
+
+// SlidingWindow 滑动窗口计数：用上一窗口计数按时间加权估算，避免固定窗口边界的突发
+type SlidingWindow struct {
+	Limit  int
+	Window time.Duration
+	Store  Store
+}
+
+// NewSlidingWindow 创建滑动窗口限流器
+func NewSlidingWindow(limit int, window time.Duration, store Store) *SlidingWindow {
+	return &SlidingWindow{Limit: limit, Window: window, Store: store}
+}
+
+// Allow 记录一次请求
+func (w *SlidingWindow) Allow(key string) (Result, error) {
+	return w.Store.Update(key, 2*w.Window, func(s *State) Result {
+		now := clock()
+		start := now.Truncate(w.Window)
+
+		// 进入新窗口时，当前计数变为上一窗口计数
+		if !s.Stamp.Equal(start) {
+			if s.Stamp.Equal(start.Add(-w.Window)) {
+				s.Prev = s.Value
+			} else {
+				s.Prev = 0
+			}
+			s.Value = 0
+			s.Stamp = start
+		}
+
+		elapsed := now.Sub(start)
+		weight := 1 - float64(elapsed)/float64(w.Window)
+		estimated := s.Prev*weight + s.Value
+		reset := start.Add(w.Window).Sub(now)
+
+		r := Result{Limit: w.Limit, Reset: reset}
+		if estimated+1 <= float64(w.Limit) {
+			s.Value++
+			r.Allowed = true
+			r.Remaining = int(math.Floor(float64(w.Limit) - estimated - 1))
+			return r
+		}
+
+		// 计算上一窗口的权重降到多少才能放行
+		if s.Value+1 <= float64(w.Limit) {
+			need := 1 - (float64(w.Limit)-s.Value-1)/s.Prev
+			r.RetryAfter = time.Duration(need*float64(w.Window)) - elapsed
+		} else {
+			// 当前窗口已满：下一窗口中当前计数成为上一窗口计数
+			need := math.Max(0, 1-(float64(w.Limit)-1)/s.Value)
+			r.RetryAfter = reset + time.Duration(need*float64(w.Window))
+		}
+		if r.RetryAfter < time.Second {
+			r.RetryAfter = time.Second
+		}
+		return r
+	})
+}
+
+// Policy 返回限流策略描述
+func (w *SlidingWindow) Policy() string {
+	return fmt.Sprintf("%d;w=%d", w.Limit, int(w.Window.Seconds()))
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// KeyFunc 从请求中提取限流键
+type KeyFunc func(c *gin.Context) string
 
-// RateLimit 限流中间件
+// KeyByIP 按客户端 IP 限流
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByUser 按登录用户限流，未登录时退回按 IP
+func KeyByUser(c *gin.Context) string {
+	if userID := c.GetUint("user_id"); userID != 0 {
+		return "user:" + strconv.FormatUint(uint64(userID), 10)
+	}
+	return KeyByIP(c)
+}
+
+// RateLimit 限流中间件（按路由 + IP 使用滑动窗口计数）
 // maxRequests: 时间窗口内最大请求数
 // windowSeconds: 时间窗口（秒）
 func RateLimit(maxRequests int, windowSeconds int) gin.HandlerFunc {
+	limiter := NewSlidingWindow(maxRequests, time.Duration(windowSeconds)*time.Second, DefaultStore())
+	return RateLimitWith("", limiter, KeyByIP)
+}
+
+// RateLimitWith 使用指定限流器和限流键的中间件
+// name 用于区分不同的限流规则，为空时使用请求方法 + 路由路径
+func RateLimitWith(name string, limiter Limiter, keyFunc KeyFunc) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-
-		mu.Lock()
-		v, exists := visitors[ip]
-		now := time.Now()
-		window := time.Duration(windowSeconds) * time.Second
-
-		if !exists || now.Sub(v.lastSeen) > window {
-			// 新访问者或时间窗口已过期
-			visitors[ip] = &visitor{
-				lastSeen: now,
-				count:    1,
-			}
-			mu.Unlock()
+		scope := name
+		if scope == "" {
+			scope = c.Request.Method + " " + c.FullPath()
+		}
+		key := scope + "|" + keyFunc(c)
+
+		result, err := limiter.Allow(key)
+		if err != nil {
+			// 存储异常时放行，避免限流故障导致服务不可用
+			log.Printf("限流存储错误: key=%s, err=%v", key, err)
 			c.Next()
 			return
 		}
 
-		if v.count >= maxRequests {
-			// 超过限制
-			mu.Unlock()
-			log.Printf("限流触发: IP=%s, 请求数=%d, 窗口=%ds", ip, v.count, windowSeconds)
+		header := c.Writer.Header()
+		header.Set("RateLimit-Policy", limiter.Policy())
+		header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			retryAfter := ceilSeconds(result.RetryAfter)
+			header.Set("Retry-After", strconv.Itoa(retryAfter))
+			log.Printf("限流触发: key=%s, 策略=%s", key, limiter.Policy())
 			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "请求过于频繁，请稍后再试",
+				"error":       "请求过于频繁，请稍后再试",
+				"retry_after": retryAfter,
 			})
 			c.Abort()
 			return
 		}
 
-		// 增加计数
-		v.count++
-		v.lastSeen = now
-		mu.Unlock()
 		c.Next()
 	}
 }
 
-// CleanupVisitors 定期清理过期的访问记录
-func CleanupVisitors() {
-	ticker := time.NewTicker(5 * time.Minute)
-	log.Println("启动访问记录清理任务（每5分钟）")
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
 
-	for range ticker.C {
-		mu.Lock()
-		cleaned := 0
-		for ip, v := range visitors {
-			if time.Since(v.lastSeen) > 10*time.Minute {
-				delete(visitors, ip)
-				cleaned++
-			}
-		}
-		mu.Unlock()
+// ParseRate 解析 "次数/时长" 形式的配额，如 60/1h、10/30s
+func ParseRate(spec string) (int, time.Duration, error) {
+	parts := strings.SplitN(strings.TrimSpace(spec), "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("无效的配额格式: %q", spec)
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || limit <= 0 {
+		return 0, 0, fmt.Errorf("无效的配额次数: %q", spec)
+	}
+	per, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil || per <= 0 {
+		return 0, 0, fmt.Errorf("无效的配额时长: %q", spec)
+	}
+	return limit, per, nil
+}
 
-		if cleaned > 0 {
-			log.Printf("清理了 %d 条过期的访问记录", cleaned)
+// UserQuota 按用户限制写操作频率（令牌桶），配额从环境变量 envKey 读取，格式见 ParseRate
+// 同名配额在多个路由间共享
+func UserQuota(name, envKey, defaultRate string) gin.HandlerFunc {
+	limit, per, err := ParseRate(utils.GetEnv(envKey, defaultRate))
+	if err != nil {
+		log.Printf("警告: %s 配置错误（%v），使用默认配额 %s", envKey, err, defaultRate)
+		limit, per, _ = ParseRate(defaultRate)
+	}
+	return RateLimitWith("quota:"+name, NewTokenBucket(limit, per, DefaultStore()), KeyByUser)
+}
+
+// BodyLimit 限制请求体大小
+// 先执行的 BodyLimit 会直接拒绝超出上限的请求，后面的无法放宽限制；
+// 需要更大上限的路由（例如文件上传）应注册在不使用默认限制的分组中
+func BodyLimit(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > maxBytes {
+			log.Printf("请求体过大: ip=%s, user_id=%d, path=%s, 大小=%d, 上限=%d", c.ClientIP(), c.GetUint("user_id"), c.FullPath(), c.Request.ContentLength, maxBytes)
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "请求内容过大"})
+			c.Abort()
+			return
 		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		c.Next()
 	}
 }
 
-// GetVisitorStats 获取当前访问统计（用于监控）
-func GetVisitorStats() map[string]interface{} {
-	mu.RLock()
-	defer mu.RUnlock()
+// CleanupRateLimits 定期清理过期的限流状态
+func CleanupRateLimits() {
+	ticker := time.NewTicker(5 * time.Minute)
+	log.Println("启动限流状态清理任务（每5分钟）")
 
-	return map[string]interface{}{
-		"total_visitors": len(visitors),
-		"timestamp":      time.Now(),
+	for range ticker.C {
+		cleaned, err := DefaultStore().Cleanup()
+		if err != nil {
+			log.Printf("清理限流状态失败: %v", err)
+			continue
+		}
+		if cleaned > 0 {
+			log.Printf("清理了 %d 条过期的限流状态", cleaned)
+		}
 	}
 }
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"log"
+	"pomodoro-api/database"
+	"pomodoro-api/models"
+	"pomodoro-api/utils"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// State 限流器保存在存储中的状态
+type State struct {
+	Value float64   // 令牌桶：剩余令牌；滑动窗口：当前窗口计数
+	Prev  float64   // 滑动窗口：上一窗口计数
+	Stamp time.Time // 令牌桶：上次补充时间；滑动窗口：当前窗口开始时间
+}
+
+// Store 限流状态存储
+type Store interface {
+	// Update 原子地读取、修改并保存 key 的状态，ttl 后状态可被清理
+	Update(key string, ttl time.Duration, fn func(s *State) Result) (Result, error)
+	// Cleanup 清理过期状态，返回清理条数
+	Cleanup() (int, error)
+}
+
+var (
+	defaultStore     Store
+	defaultStoreOnce sync.Once
+)
+
+// DefaultStore 返回全局限流存储，由 RATE_LIMIT_STORE 选择 memory（默认）或 sqlite
+func DefaultStore() Store {
+	defaultStoreOnce.Do(func() {
+		switch utils.GetEnv("RATE_LIMIT_STORE", "memory") {
+		case "sqlite":
+			defaultStore = NewSQLiteStore(database.DB)
+			log.Println("限流存储: SQLite")
+		default:
+			defaultStore = NewMemoryStore()
+			log.Println("限流存储: 内存")
+		}
+	})
+	return defaultStore
+}
+
+// MemoryStore 进程内存储，重启后计数清零
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+}
+
+type memoryEntry struct {
+	state     State
+	expiresAt time.Time
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*memoryEntry)}
+}
+
+// Update 原子地更新状态
+func (m *MemoryStore) Update(key string, ttl time.Duration, fn func(s *State) Result) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok || clock().After(e.expiresAt) {
+		e = &memoryEntry{}
+		m.entries[key] = e
+	}
+
+	result := fn(&e.state)
+	e.expiresAt = clock().Add(ttl)
+	return result, nil
+}
+
+// Cleanup 清理过期状态
+func (m *MemoryStore) Cleanup() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := clock()
+	cleaned := 0
+	for key, e := range m.entries {
+		if now.After(e.expiresAt) {
+			delete(m.entries, key)
+			cleaned++
+		}
+	}
+	return cleaned, nil
+}
+
+// SQLiteStore 数据库存储，重启后计数保留
+type SQLiteStore struct {
+	db *gorm.DB
+	// SQLite 写操作本身是串行的，这里再加一把锁保证同一进程内读改写的原子性
+	mu sync.Mutex
+}
+
+// NewSQLiteStore 创建数据库存储
+func NewSQLiteStore(db *gorm.DB) *SQLiteStore {
+	return &SQLiteStore{db: db}
+}
+
+// Update 原子地更新状态
+func (s *SQLiteStore) Update(key string, ttl time.Duration, fn func(s *State) Result) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result Result
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var bucket models.RateLimitBucket
+		err := tx.Where("`key` = ? AND expires_at > ?", key, clock()).Limit(1).Find(&bucket).Error
+		if err != nil {
+			return err
+		}
+
+		state := State{Value: bucket.Value, Prev: bucket.Prev, Stamp: bucket.Stamp}
+		result = fn(&state)
+
+		bucket = models.RateLimitBucket{
+			Key:       key,
+			Value:     state.Value,
+			Prev:      state.Prev,
+			Stamp:     state.Stamp,
+			ExpiresAt: clock().Add(ttl),
+		}
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&bucket).Error
+	})
+	return result, err
+}
+
+// Cleanup 清理过期状态
+func (s *SQLiteStore) Cleanup() (int, error) {
+	result := s.db.Where("expires_at <= ?", clock()).Delete(&models.RateLimitBucket{})
+	return int(result.RowsAffected), result.Error
+}
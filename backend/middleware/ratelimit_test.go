@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"pomodoro-api/models"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeClock 替换限流使用的当前时间，从整分钟开始
+type fakeClock struct {
+	now time.Time
+}
+
+func useFakeClock(t *testing.T) *fakeClock {
+	t.Helper()
+	f := &fakeClock{now: time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)}
+	previous := clock
+	clock = func() time.Time { return f.now }
+	t.Cleanup(func() { clock = previous })
+	return f
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.now = f.now.Add(d)
+}
+
+// newTestSQLiteStore 使用临时数据库的 SQLite 存储
+func newTestSQLiteStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "ratelimit.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.RateLimitBucket{}); err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return NewSQLiteStore(db)
+}
+
+// forEachStore 分别使用内存和 SQLite 存储运行测试
+func forEachStore(t *testing.T, fn func(t *testing.T, store Store)) {
+	t.Run("memory", func(t *testing.T) { fn(t, NewMemoryStore()) })
+	t.Run("sqlite", func(t *testing.T) { fn(t, newTestSQLiteStore(t)) })
+}
+
+// allow 调用限流器并检查是否放行
+func allow(t *testing.T, limiter Limiter, key string, want bool) Result {
+	t.Helper()
+	r, err := limiter.Allow(key)
+	if err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if r.Allowed != want {
+		t.Fatalf("Allowed = %v, want %v (%+v)", r.Allowed, want, r)
+	}
+	return r
+}
+
+func TestTokenBucket(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		clk := useFakeClock(t)
+		// 每 10 秒 5 个令牌，每 2 秒补充一个
+		bucket := NewTokenBucket(5, 10*time.Second, store)
+		if p := bucket.Policy(); p != "5;w=10" {
+			t.Errorf("Policy = %q", p)
+		}
+
+		for i := 4; i >= 0; i-- {
+			r := allow(t, bucket, "k", true)
+			if r.Remaining != i || r.Limit != 5 {
+				t.Errorf("Remaining = %d, Limit = %d, want %d, 5", r.Remaining, r.Limit, i)
+			}
+		}
+		r := allow(t, bucket, "k", false)
+		if r.RetryAfter != 2*time.Second || r.Reset != 10*time.Second {
+			t.Errorf("RetryAfter = %v, Reset = %v, want 2s, 10s", r.RetryAfter, r.Reset)
+		}
+
+		// 其他键不受影响
+		allow(t, bucket, "other", true)
+
+		clk.Advance(time.Second)
+		r = allow(t, bucket, "k", false)
+		if r.RetryAfter != time.Second {
+			t.Errorf("1 秒后 RetryAfter = %v, want 1s", r.RetryAfter)
+		}
+		clk.Advance(time.Second)
+		r = allow(t, bucket, "k", true)
+		if r.Remaining != 0 {
+			t.Errorf("补充一个令牌后 Remaining = %d, want 0", r.Remaining)
+		}
+
+		// 长时间不用时令牌数不超过桶容量
+		clk.Advance(time.Hour)
+		for i := 0; i < 5; i++ {
+			allow(t, bucket, "k", true)
+		}
+		allow(t, bucket, "k", false)
+	})
+}
+
+func TestSlidingWindow(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		clk := useFakeClock(t)
+		window := NewSlidingWindow(10, time.Minute, store)
+		if p := window.Policy(); p != "10;w=60" {
+			t.Errorf("Policy = %q", p)
+		}
+
+		for i := 9; i >= 0; i-- {
+			if r := allow(t, window, "k", true); r.Remaining != i {
+				t.Errorf("Remaining = %d, want %d", r.Remaining, i)
+			}
+		}
+		// 当前窗口已满：下一窗口开始后，上一窗口的权重降到 0.9 才能放行（浮点误差按整秒比较）
+		r := allow(t, window, "k", false)
+		if ceilSeconds(r.RetryAfter) != 66 || r.Reset != time.Minute {
+			t.Errorf("RetryAfter = %v, Reset = %v, want 66s, 60s", r.RetryAfter, r.Reset)
+		}
+
+		clk.Advance(time.Minute)
+		r = allow(t, window, "k", false)
+		if ceilSeconds(r.RetryAfter) != 6 {
+			t.Errorf("新窗口开始时 RetryAfter = %v, want 6s", r.RetryAfter)
+		}
+		clk.Advance(5 * time.Second)
+		r = allow(t, window, "k", false)
+		if r.RetryAfter != time.Second {
+			t.Errorf("RetryAfter 最少 1 秒, got %v", r.RetryAfter)
+		}
+		clk.Advance(time.Second)
+		allow(t, window, "k", true)
+
+		// 窗口过半时上一窗口计数按一半计算
+		clk.Advance(24 * time.Second)
+		for i := 0; i < 4; i++ {
+			allow(t, window, "k", true)
+		}
+		allow(t, window, "k", false)
+
+		// 间隔超过一个窗口后不再计入上一窗口
+		clk.Advance(2 * time.Minute)
+		for i := 0; i < 10; i++ {
+			allow(t, window, "k", true)
+		}
+	})
+}
+
+func TestStoreExpiry(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		clk := useFakeClock(t)
+		bucket := NewTokenBucket(1, time.Minute, store)
+		allow(t, bucket, "a", true)
+		allow(t, bucket, "a", false)
+		allow(t, bucket, "b", true)
+
+		if n, err := store.Cleanup(); err != nil || n != 0 {
+			t.Errorf("未过期时 Cleanup = %d, %v", n, err)
+		}
+		// 桶补满所需的时间过后状态可被清理
+		clk.Advance(time.Minute + time.Second)
+		if n, err := store.Cleanup(); err != nil || n != 2 {
+			t.Errorf("过期后 Cleanup = %d, %v, want 2", n, err)
+		}
+		allow(t, bucket, "a", true)
+	})
+}
+
+func TestRateLimitWithHeaders(t *testing.T) {
+	useFakeClock(t)
+	r := gin.New()
+	r.GET("/limited", RateLimitWith("", NewSlidingWindow(2, time.Minute, NewMemoryStore()), KeyByIP), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	request := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := request("203.0.113.5"); w.Code != http.StatusNoContent {
+			t.Fatalf("第 %d 次请求状态码 = %d", i+1, w.Code)
+		}
+	}
+	w := request("203.0.113.5")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("超出限制时状态码 = %d, want 429", w.Code)
+	}
+	headers := map[string]string{
+		"Retry-After":         "90",
+		"RateLimit-Policy":    "2;w=60",
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "60",
+	}
+	for name, want := range headers {
+		if got := w.Header().Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if w := request("203.0.113.6"); w.Code != http.StatusNoContent {
+		t.Errorf("其他 IP 状态码 = %d", w.Code)
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"pomodoro-api/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultContentSecurityPolicy 前端页面使用了内联事件和样式，以及 Google Fonts、cdnjs 上的字体图标
+const defaultContentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'self' 'unsafe-inline'; " +
+	"style-src 'self' 'unsafe-inline' https://fonts.googleapis.com https://cdnjs.cloudflare.com; " +
+	"font-src 'self' data: https://fonts.gstatic.com https://cdnjs.cloudflare.com; " +
+	"img-src 'self' data:; " +
+	"connect-src 'self'; " +
+	"object-src 'none'; " +
+	"base-uri 'self'; " +
+	"form-action 'self'; " +
+	"frame-ancestors 'none'"
+
+// SecurityHeaders 为页面添加安全响应头
+func SecurityHeaders() gin.HandlerFunc {
+	csp := utils.GetEnv("CONTENT_SECURITY_POLICY", defaultContentSecurityPolicy)
+	referrerPolicy := utils.GetEnv("REFERRER_POLICY", "strict-origin-when-cross-origin")
+	hsts := ""
+	if maxAge := utils.GetEnvDuration("HSTS_MAX_AGE", 180*24*time.Hour); maxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(maxAge.Seconds())) + "; includeSubDomains"
+	}
+
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Set("Content-Security-Policy", csp)
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Referrer-Policy", referrerPolicy)
+		header.Set("X-Frame-Options", "DENY")
+		// HSTS 只在 HTTPS 响应中有效
+		if hsts != "" && IsSecureRequest(c) {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+		c.Next()
+	}
+}
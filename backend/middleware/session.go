@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"pomodoro-api/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cookie 认证模式：登录令牌保存在 HttpOnly Cookie 中，前端脚本无法读取；
+// 另发一个前端可读的 CSRF Cookie，写请求需要在请求头中带上相同的值（双重提交）
+const (
+	SessionCookieName = "pomodoro_session"
+	CSRFCookieName    = "pomodoro_csrf"
+	CSRFHeaderName    = "X-CSRF-Token"
+)
+
+// CookieAuthEnabled 是否启用 Cookie 认证模式
+func CookieAuthEnabled() bool {
+	return utils.GetEnv("AUTH_COOKIE_MODE", "false") == "true"
+}
+
+// cookieSameSite 从环境变量读取 SameSite 策略，默认 Lax
+func cookieSameSite() http.SameSite {
+	switch strings.ToLower(utils.GetEnv("AUTH_COOKIE_SAMESITE", "lax")) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// cookieSecure 本地 HTTP 调试时可通过 AUTH_COOKIE_SECURE=false 关闭 Secure 标记
+func cookieSecure(c *gin.Context) bool {
+	return utils.GetEnv("AUTH_COOKIE_SECURE", "true") == "true" || IsSecureRequest(c)
+}
+
+// SetSessionCookies 写入登录 Cookie 和 CSRF Cookie，返回 CSRF 令牌
+func SetSessionCookies(c *gin.Context, token string) (string, error) {
+	csrfToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	maxAge := int(utils.TokenLifetime.Seconds())
+	secure := cookieSecure(c)
+	c.SetSameSite(cookieSameSite())
+	c.SetCookie(SessionCookieName, token, maxAge, "/", "", secure, true)
+	c.SetCookie(CSRFCookieName, csrfToken, maxAge, "/", "", secure, false)
+	return csrfToken, nil
+}
+
+// ClearSessionCookies 清除登录 Cookie 和 CSRF Cookie
+func ClearSessionCookies(c *gin.Context) {
+	secure := cookieSecure(c)
+	c.SetSameSite(cookieSameSite())
+	c.SetCookie(SessionCookieName, "", -1, "/", "", secure, true)
+	c.SetCookie(CSRFCookieName, "", -1, "/", "", secure, false)
+}
+
+// validCSRF 校验请求头中的 CSRF 令牌与 Cookie 一致，安全方法不需要校验
+func validCSRF(c *gin.Context) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	cookie, err := c.Cookie(CSRFCookieName)
+	header := c.GetHeader(CSRFHeaderName)
+	if err != nil || cookie == "" || header == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) == 1
+}
+
+// IsSecureRequest 判断请求是否通过 HTTPS 到达
+// X-Forwarded-Proto 只在连接来自可信代理时采信，防止客户端伪造
+func IsSecureRequest(c *gin.Context) bool {
+	if c.Request.TLS != nil {
+		return true
+	}
+	return c.GetHeader("X-Forwarded-Proto") == "https" && IsTrustedProxy(c)
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIsSecureRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		proto      string
+		tls        bool
+		want       bool
+	}{
+		{"直接 HTTPS 连接", "203.0.113.5:40000", "", true, true},
+		{"可信代理转发的 HTTPS", "127.0.0.1:40000", "https", false, true},
+		{"可信代理转发的 HTTP", "127.0.0.1:40000", "http", false, false},
+		{"客户端伪造的转发头", "203.0.113.5:40000", "https", false, false},
+		{"没有转发头", "127.0.0.1:40000", "", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/", nil)
+			c.Request.RemoteAddr = tt.remoteAddr
+			if tt.proto != "" {
+				c.Request.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			if tt.tls {
+				c.Request.TLS = &tls.ConnectionState{}
+			}
+			if got := IsSecureRequest(c); got != tt.want {
+				t.Errorf("IsSecureRequest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AccountTokenPurposeUnlock 账号解锁令牌
+const AccountTokenPurposeUnlock = "unlock"
+
+// AccountToken 通过邮件发送的一次性令牌（只保存摘要）
+type AccountToken struct {
+	gorm.Model
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	Purpose   string     `gorm:"index;not null" json:"purpose"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	User      User       `gorm:"foreignKey:UserID" json:"-"`
+}
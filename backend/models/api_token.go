@@ -0,0 +1,58 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APITokenPrefix 个人访问令牌前缀，用于和 JWT 区分
+const APITokenPrefix = "pt_"
+
+// APITokenScopes 个人访问令牌可申请的权限范围
+// xxx:write 同时包含 xxx:read
+var APITokenScopes = []string{
+	"profile:read",
+	"categories:read",
+	"categories:write",
+	"pomodoros:read",
+	"pomodoros:write",
+	"stats:read",
+	"settings:read",
+	"settings:write",
+	"words:read",
+	"words:write",
+	"tasks:read",
+	"tasks:write",
+	"export:read",
+}
+
+// APIToken 个人访问令牌（供脚本、插件等使用，只保存摘要）
+type APIToken struct {
+	gorm.Model
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	Name       string     `gorm:"not null" json:"name"`
+	Prefix     string     `gorm:"not null" json:"prefix"` // 令牌开头几位，便于识别
+	TokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	Scopes     string     `gorm:"not null" json:"scopes"` // 空格分隔
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	User       User       `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// ScopeList 返回令牌的权限范围列表
+func (t *APIToken) ScopeList() []string {
+	return strings.Fields(t.Scopes)
+}
+
+// IsValidAPITokenScope 检查权限范围是否合法
+func IsValidAPITokenScope(scope string) bool {
+	for _, s := range APITokenScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrAuditEventImmutable 审计事件只能追加，不能修改或删除
+var ErrAuditEventImmutable = errors.New("审计事件不可修改或删除")
+
+// AuditEvent 审计事件（只追加，不修改）
+type AuditEvent struct {
+	ID         uint            `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time       `gorm:"index" json:"created_at"`
+	ActorID    *uint           `gorm:"index" json:"actor_id,omitempty"`    // 操作者，系统触发时为空
+	OwnerID    *uint           `gorm:"index" json:"owner_id,omitempty"`    // 事件涉及的账号，用户可查看自己账号的事件
+	Action     string          `gorm:"index;not null" json:"action"`       // 如 login.lockout
+	TargetType string          `gorm:"index" json:"target_type,omitempty"` // 如 user、identifier
+	TargetID   string          `json:"target_id,omitempty"`
+	IP         string          `json:"ip,omitempty"`
+	Detail     string          `gorm:"type:text" json:"detail,omitempty"`
+	Before     json.RawMessage `gorm:"type:text" json:"before,omitempty"` // 变更前的数据快照
+	After      json.RawMessage `gorm:"type:text" json:"after,omitempty"`  // 变更后的数据快照
+}
+
+// BeforeUpdate 禁止修改审计事件
+func (e *AuditEvent) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditEventImmutable
+}
+
+// BeforeDelete 禁止删除审计事件
+func (e *AuditEvent) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditEventImmutable
+}
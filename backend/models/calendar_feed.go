@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CalendarFeedPrefix 日历订阅密钥的前缀
+const CalendarFeedPrefix = "cal_"
+
+// CalendarFeed 日历订阅地址中的密钥（每个用户一个，只保存摘要）
+type CalendarFeed struct {
+	gorm.Model
+	UserID     uint       `gorm:"uniqueIndex;not null" json:"user_id"`
+	Prefix     string     `gorm:"not null" json:"prefix"` // 密钥开头几位，便于识别
+	TokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	RotatedAt  time.Time  `json:"rotated_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	User       User       `gorm:"foreignKey:UserID" json:"-"`
+}
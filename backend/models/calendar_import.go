@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CalendarImportSource 用户配置的日历订阅地址，导入时未上传文件则从这里下载
+type CalendarImportSource struct {
+	gorm.Model
+	UserID         uint       `gorm:"uniqueIndex;not null" json:"user_id"`
+	URL            string     `gorm:"size:500" json:"url"`
+	LastImportedAt *time.Time `json:"last_imported_at,omitempty"`
+	User           User       `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// CalendarRule 日历导入时按关键字匹配分类的规则，按 Position 顺序匹配第一条
+type CalendarRule struct {
+	gorm.Model
+	UserID     uint     `gorm:"index;not null" json:"user_id"`
+	Keyword    string   `gorm:"size:50;not null" json:"keyword"` // 出现在事件标题或分类中（不区分大小写）
+	CategoryID uint     `gorm:"not null" json:"category_id"`
+	Position   int      `gorm:"default:0" json:"position"`
+	User       User     `gorm:"foreignKey:UserID" json:"-"`
+	Category   Category `gorm:"foreignKey:CategoryID" json:"-"`
+}
+
+// CalendarEventLink 已导入的日历事件，按事件 UID（重复事件含每次的开始时间）去重
+type CalendarEventLink struct {
+	gorm.Model
+	UserID   uint   `gorm:"uniqueIndex:idx_user_event_key;not null" json:"user_id"`
+	EventKey string `gorm:"uniqueIndex:idx_user_event_key;not null" json:"event_key"`
+	TaskID   uint   `gorm:"index;not null" json:"task_id"`
+	Date     string `gorm:"size:10" json:"date"` // 事件所在日期 YYYY-MM-DD
+	User     User   `gorm:"foreignKey:UserID" json:"-"`
+}
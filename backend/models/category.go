@@ -9,4 +9,9 @@ type Category struct {
 	Color  string `gorm:"default:#FF6B6B" json:"color"`
 	Icon   string `json:"icon,omitempty"`
 	User   User   `gorm:"foreignKey:UserID" json:"-"`
+
+	ParentID *uint      `gorm:"index" json:"parent_id"`        // 上级分类，顶级分类为空
+	Archived bool       `gorm:"default:false" json:"archived"` // 已归档：不再出现在选择列表中，但保留统计
+	Path     string     `gorm:"-" json:"path,omitempty"`       // 完整路径，如 学习 > 数学 > 线性代数
+	Children []Category `gorm:"-" json:"children,omitempty"`   // 子分类（树形返回时填充）
 }
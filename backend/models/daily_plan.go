@@ -0,0 +1,25 @@
+package models
+
+import "gorm.io/gorm"
+
+// DailyPlan 每日计划：当天要完成的任务和目标番茄数
+type DailyPlan struct {
+	gorm.Model
+	UserID          uint   `gorm:"not null;uniqueIndex:idx_user_plan_date" json:"user_id"`
+	Date            string `gorm:"size:10;not null;uniqueIndex:idx_user_plan_date" json:"date"` // YYYY-MM-DD 格式
+	TargetPomodoros int    `gorm:"default:0" json:"target_pomodoros"`
+	Note            string `gorm:"type:text" json:"note,omitempty"`
+	Tasks           []Task `gorm:"many2many:daily_plan_tasks;" json:"tasks"`
+	User            User   `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// DailyReview 每日回顾：完成情况、专注自评和总结
+type DailyReview struct {
+	gorm.Model
+	UserID         uint   `gorm:"not null;uniqueIndex:idx_user_review_date" json:"user_id"`
+	Date           string `gorm:"size:10;not null;uniqueIndex:idx_user_review_date" json:"date"` // YYYY-MM-DD 格式
+	FocusScore     int    `gorm:"not null" json:"focus_score"`                                   // 专注自评 1-5
+	Reflection     string `gorm:"type:text" json:"reflection,omitempty"`
+	CompletedTasks []Task `gorm:"many2many:daily_review_tasks;" json:"completed_tasks"`
+	User           User   `gorm:"foreignKey:UserID" json:"-"`
+}
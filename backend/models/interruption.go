@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 打断类型
+const (
+	InterruptionInternal = "internal" // 内部打断：走神、想起别的事
+	InterruptionExternal = "external" // 外部打断：消息、电话、被人打扰
+)
+
+// Interruption 番茄钟进行中的一次打断
+type Interruption struct {
+	gorm.Model
+	UserID     uint      `gorm:"index;not null" json:"user_id"`
+	PomodoroID uint      `gorm:"index;not null" json:"pomodoro_id"`
+	Type       string    `gorm:"not null" json:"type"`
+	OccurredAt time.Time `gorm:"not null" json:"occurred_at"`
+	Note       string    `json:"note,omitempty"`
+	User       User      `gorm:"foreignKey:UserID" json:"-"`
+	Pomodoro   Pomodoro  `gorm:"foreignKey:PomodoroID" json:"-"`
+}
+
+// IsValidInterruptionType 检查打断类型是否合法
+func IsValidInterruptionType(t string) bool {
+	return t == InterruptionInternal || t == InterruptionExternal
+}
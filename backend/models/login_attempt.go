@@ -0,0 +1,11 @@
+package models
+
+import "gorm.io/gorm"
+
+// LoginAttempt 登录失败记录（按账号统计，未知账号按登录标识统计）
+type LoginAttempt struct {
+	gorm.Model
+	UserID     *uint  `gorm:"index" json:"user_id,omitempty"`
+	Identifier string `gorm:"index;not null" json:"identifier"` // 登录时提交的用户名或邮箱
+	IP         string `json:"ip"`
+}
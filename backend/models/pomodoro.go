@@ -6,16 +6,29 @@ import (
 	"gorm.io/gorm"
 )
 
+// 番茄钟记录来源
+const (
+	PomodoroSourceTimer  = "timer"  // 计时器
+	PomodoroSourceManual = "manual" // 手动补录
+	PomodoroSourceImport = "import" // 导入
+)
+
 type Pomodoro struct {
 	gorm.Model
 	UserID          uint       `gorm:"not null" json:"user_id"`
 	CategoryID      uint       `gorm:"not null" json:"category_id"`
-	Duration        int        `gorm:"not null" json:"duration"`                   // 实际时长（秒）
-	PlannedDuration int        `gorm:"default:1500" json:"planned_duration"`       // 计划时长（默认25分钟）
+	TaskID          *uint      `gorm:"index" json:"task_id,omitempty"`       // 关联的任务
+	Duration        int        `gorm:"not null" json:"duration"`             // 实际时长（秒）
+	PlannedDuration int        `gorm:"default:1500" json:"planned_duration"` // 计划时长（默认25分钟）
 	Completed       bool       `gorm:"default:false" json:"completed"`
 	StartedAt       time.Time  `gorm:"not null" json:"started_at"`
 	CompletedAt     *time.Time `json:"completed_at,omitempty"`
 	Note            string     `json:"note,omitempty"`
+	Source          string     `gorm:"size:10;default:timer;index" json:"source"` // 记录来源
+	FocusScore      *int       `json:"focus_score,omitempty"`                     // 专注质量评分（1-5）
+	Energy          *int       `json:"energy,omitempty"`                          // 精力（1-5）
+	Mood            *int       `json:"mood,omitempty"`                            // 心情（1-5）
 	User            User       `gorm:"foreignKey:UserID" json:"-"`
 	Category        Category   `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
+	Tags            []Tag      `gorm:"many2many:pomodoro_tags;" json:"tags,omitempty"`
 }
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// RateLimitBucket 限流状态（使用 SQLite 存储时，重启后计数不丢失）
+type RateLimitBucket struct {
+	Key       string    `gorm:"primaryKey"`
+	Value     float64   `gorm:"not null;default:0"`
+	Prev      float64   `gorm:"not null;default:0"`
+	Stamp     time.Time `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+}
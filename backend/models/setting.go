@@ -12,6 +12,6 @@ type Setting struct {
 	NotificationEnabled bool    `gorm:"default:true" json:"notification_enabled"`
 	DailyGoal           int     `gorm:"default:7200" json:"daily_goal"`            // 每日目标（秒），默认2小时
 	ExamDate            *string `gorm:"type:date" json:"exam_date"`                // 考试日期 YYYY-MM-DD
-	ExamName            string  `gorm:"default:''' json:"exam_name"`               // 考试名称
+	ExamName            string  `gorm:"default:''" json:"exam_name"`               // 考试名称
 	User                User    `gorm:"foreignKey:UserID" json:"-"`
 }
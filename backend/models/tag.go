@@ -0,0 +1,12 @@
+package models
+
+import "gorm.io/gorm"
+
+// Tag 标签：可以给番茄钟打多个标签，与分类互不影响
+type Tag struct {
+	gorm.Model
+	UserID uint   `gorm:"not null;uniqueIndex:idx_user_tag_name" json:"user_id"`
+	Name   string `gorm:"not null;uniqueIndex:idx_user_tag_name" json:"name"`
+	Color  string `json:"color,omitempty"`
+	User   User   `gorm:"foreignKey:UserID" json:"-"`
+}
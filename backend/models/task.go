@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 任务状态
+const (
+	TaskStatusTodo       = "todo"
+	TaskStatusInProgress = "in_progress"
+	TaskStatusDone       = "done"
+	TaskStatusCancelled  = "cancelled"
+)
+
+// Task 待办任务，番茄钟可以关联到任务上
+type Task struct {
+	gorm.Model
+	UserID             uint       `gorm:"index;not null" json:"user_id"`
+	CategoryID         *uint      `gorm:"index" json:"category_id"`
+	Title              string     `gorm:"not null" json:"title"`
+	Note               string     `gorm:"type:text" json:"note,omitempty"`
+	EstimatedPomodoros int        `gorm:"not null" json:"estimated_pomodoros"` // 预估番茄数，0 表示不预估（默认值 1 由接口设置）
+	DueDate            *string    `gorm:"size:10" json:"due_date"`             // 截止日期 YYYY-MM-DD
+	Status             string     `gorm:"default:todo;index" json:"status"`
+	Position           int        `gorm:"default:0" json:"position"` // 排序，越小越靠前
+	CompletedAt        *time.Time `json:"completed_at,omitempty"`
+	User               User       `gorm:"foreignKey:UserID" json:"-"`
+
+	ActualPomodoros int64 `gorm:"-" json:"actual_pomodoros"` // 已完成的番茄数
+	ActualDuration  int   `gorm:"-" json:"actual_duration"`  // 实际专注时长（秒）
+}
+
+// IsValidTaskStatus 检查任务状态是否合法
+func IsValidTaskStatus(status string) bool {
+	switch status {
+	case TaskStatusTodo, TaskStatusInProgress, TaskStatusDone, TaskStatusCancelled:
+		return true
+	}
+	return false
+}
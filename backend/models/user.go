@@ -1,10 +1,31 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 用户角色
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator" // 可管理排行榜
+	RoleAdmin     = "admin"
+)
 
 type User struct {
 	gorm.Model
-	Username string `gorm:"unique;not null" json:"username"`
-	Email    string `gorm:"unique;not null" json:"email"`
-	Password string `gorm:"not null" json:"-"`
+	Username          string `gorm:"unique;not null" json:"username"`
+	Email             string `gorm:"unique;not null" json:"email"`
+	Password          string `gorm:"not null" json:"-"`
+	Role              string `gorm:"not null;default:user" json:"role"`
+	Disabled          bool   `gorm:"default:false" json:"disabled"`           // 停用后无法登录和访问接口
+	LeaderboardHidden bool   `gorm:"default:false" json:"leaderboard_hidden"` // 不在公开排行榜中显示
+	// 最近一次修改密码的时间，此前签发的登录令牌失效
+	PasswordChangedAt *time.Time `json:"-"`
+}
+
+// IsValidRole 检查角色是否合法
+func IsValidRole(role string) bool {
+	return role == RoleUser || role == RoleModerator || role == RoleAdmin
 }
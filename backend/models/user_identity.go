@@ -0,0 +1,13 @@
+package models
+
+import "gorm.io/gorm"
+
+// UserIdentity 用户与外部身份提供方账号的绑定关系
+type UserIdentity struct {
+	gorm.Model
+	UserID   uint   `gorm:"index;not null" json:"user_id"`
+	Provider string `gorm:"not null;uniqueIndex:idx_provider_subject" json:"provider"` // 发现文档中的 issuer
+	Subject  string `gorm:"not null;uniqueIndex:idx_provider_subject" json:"subject"`  // ID Token 中的 sub
+	Email    string `json:"email"`
+	User     User   `gorm:"foreignKey:UserID" json:"-"`
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetEnv 获取字符串环境变量，未设置时返回默认值
+func GetEnv(key, defaultValue string) string {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// GetEnvInt 获取整数环境变量，未设置或格式错误时返回默认值
+func GetEnvInt(key string, defaultValue int) int {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("警告: 环境变量 %s=%q 不是有效整数，使用默认值 %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// GetEnvDuration 获取时长环境变量（如 30s、15m、2h），未设置或格式错误时返回默认值
+func GetEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("警告: 环境变量 %s=%q 不是有效时长，使用默认值 %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+// GetEnvList 获取逗号分隔的列表环境变量，忽略空项
+func GetEnvList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,452 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ICalEvent 日历中的一个事件，重复事件会展开为多个
+type ICalEvent struct {
+	UID          string
+	RecurrenceID string // 重复事件中某一次的原始开始时间（UTC），非重复事件为空
+	Summary      string
+	Description  string
+	Categories   []string
+	Status       string
+	Start        time.Time
+	End          time.Time
+	AllDay       bool
+}
+
+// Key 事件的唯一标识，重复事件的每一次各不相同
+func (e ICalEvent) Key() string {
+	if e.RecurrenceID == "" {
+		return e.UID
+	}
+	return e.UID + "/" + e.RecurrenceID
+}
+
+// icalProperty 一行属性
+type icalProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// icalRawEvent 解析出的 VEVENT 属性
+type icalRawEvent map[string][]icalProperty
+
+func (e icalRawEvent) get(name string) (icalProperty, bool) {
+	props := e[name]
+	if len(props) == 0 {
+		return icalProperty{}, false
+	}
+	return props[0], true
+}
+
+func (e icalRawEvent) text(name string) string {
+	p, _ := e.get(name)
+	return unescapeICalText(p.value)
+}
+
+// maxICalPeriods 整个文件中重复事件最多展开的周期数，防止构造的文件消耗过多计算
+const maxICalPeriods = 100000
+
+// errICalTooManyPeriods 重复事件展开的周期数超过 maxICalPeriods
+var errICalTooManyPeriods = errors.New("日历中的重复事件过多")
+
+// ParseICalEvents 解析 iCalendar（RFC 5545）中的 VEVENT，返回开始时间在 [from, to) 内的事件
+// 支持 FREQ 为 DAILY、WEEKLY（含 BYDAY）、MONTHLY、YEARLY 的简单重复规则，以及 EXDATE 和 RECURRENCE-ID；
+// 含其他规则（如 BYMONTHDAY、BYSETPOS、按月的 BYDAY）的重复事件无法正确展开，直接跳过
+// 浮动时间和无法识别的 TZID 按 X-WR-TIMEZONE 或 loc 处理
+func ParseICalEvents(data []byte, from, to time.Time, loc *time.Location) ([]ICalEvent, error) {
+	lines := unfoldICalLines(string(data))
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return nil, errors.New("不是有效的 iCalendar 文件")
+	}
+
+	var raws []icalRawEvent
+	var current icalRawEvent
+	depth := 0 // VEVENT 内嵌套的组件（如 VALARM）层数
+	for _, line := range lines {
+		prop, err := parseICalLine(line)
+		if err != nil {
+			continue
+		}
+		switch {
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VEVENT") && current == nil:
+			current = icalRawEvent{}
+		case prop.name == "BEGIN" && current != nil:
+			depth++
+		case prop.name == "END" && current != nil && depth > 0:
+			depth--
+		case prop.name == "END" && strings.EqualFold(prop.value, "VEVENT") && current != nil:
+			raws = append(raws, current)
+			current = nil
+		case prop.name == "X-WR-TIMEZONE" && current == nil:
+			if l, err := time.LoadLocation(prop.value); err == nil {
+				loc = l
+			}
+		case current != nil && depth == 0:
+			current[prop.name] = append(current[prop.name], prop)
+		}
+	}
+
+	// 单独修改过的重复事件实例，按 UID 和原始开始时间记录
+	overridden := make(map[string]bool)
+	for _, raw := range raws {
+		if p, ok := raw.get("RECURRENCE-ID"); ok {
+			if t, _, err := parseICalTime(p, loc); err == nil {
+				overridden[raw.text("UID")+"/"+t.UTC().Format("20060102T150405Z")] = true
+			}
+		}
+	}
+
+	var events []ICalEvent
+	budget := maxICalPeriods
+	for _, raw := range raws {
+		expanded, err := expandICalEvent(raw, from, to, loc, overridden, &budget)
+		if errors.Is(err, errICalTooManyPeriods) {
+			return nil, err
+		}
+		if err != nil {
+			continue
+		}
+		events = append(events, expanded...)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	return events, nil
+}
+
+// unfoldICalLines 拆分行并合并折叠的续行
+func unfoldICalLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.TrimPrefix(s, "\ufeff")
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// parseICalLine 解析 NAME;PARAM=VALUE:VALUE 形式的一行
+func parseICalLine(line string) (icalProperty, error) {
+	// 找到不在引号内的第一个冒号
+	inQuote, colon := false, -1
+	for i, r := range line {
+		if r == '"' {
+			inQuote = !inQuote
+		} else if r == ':' && !inQuote {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return icalProperty{}, errors.New("格式错误")
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	prop := icalProperty{name: strings.ToUpper(parts[0]), params: map[string]string{}, value: line[colon+1:]}
+	for _, param := range parts[1:] {
+		if k, v, ok := strings.Cut(param, "="); ok {
+			prop.params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return prop, nil
+}
+
+// unescapeICalText 还原 TEXT 类型的转义
+func unescapeICalText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// parseICalTime 解析 DATE 或 DATE-TIME，返回是否为全天日期
+func parseICalTime(p icalProperty, loc *time.Location) (time.Time, bool, error) {
+	value := strings.TrimSpace(p.value)
+	if p.params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	if tzid := p.params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+var icalDurationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseICalDuration 解析 DURATION，如 PT1H30M、P1D
+func parseICalDuration(s string) (time.Duration, error) {
+	m := icalDurationPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("无法识别的时长: %s", s)
+	}
+	n := func(i int) time.Duration {
+		v, _ := strconv.Atoi(m[i])
+		return time.Duration(v)
+	}
+	d := n(2)*7*24*time.Hour + n(3)*24*time.Hour + n(4)*time.Hour + n(5)*time.Minute + n(6)*time.Second
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+// icalWeekdays BYDAY 中的星期
+var icalWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// expandICalEvent 把一个 VEVENT 展开为 [from, to) 内的事件，budget 为剩余可展开的周期数
+func expandICalEvent(raw icalRawEvent, from, to time.Time, loc *time.Location, overridden map[string]bool, budget *int) ([]ICalEvent, error) {
+	dtstart, ok := raw.get("DTSTART")
+	if !ok {
+		return nil, errors.New("缺少 DTSTART")
+	}
+	start, allDay, err := parseICalTime(dtstart, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	var length time.Duration
+	if p, ok := raw.get("DTEND"); ok {
+		end, _, err := parseICalTime(p, loc)
+		if err != nil {
+			return nil, err
+		}
+		length = end.Sub(start)
+	} else if p, ok := raw.get("DURATION"); ok {
+		if length, err = parseICalDuration(p.value); err != nil {
+			return nil, err
+		}
+	} else if allDay {
+		length = 24 * time.Hour
+	}
+	if length < 0 {
+		return nil, errors.New("结束时间早于开始时间")
+	}
+
+	var categories []string
+	for _, p := range raw["CATEGORIES"] {
+		for _, c := range strings.Split(p.value, ",") {
+			if c = strings.TrimSpace(unescapeICalText(c)); c != "" {
+				categories = append(categories, c)
+			}
+		}
+	}
+	base := ICalEvent{
+		UID:         raw.text("UID"),
+		Summary:     strings.TrimSpace(raw.text("SUMMARY")),
+		Description: strings.TrimSpace(raw.text("DESCRIPTION")),
+		Categories:  categories,
+		Status:      strings.ToUpper(raw.text("STATUS")),
+		AllDay:      allDay,
+	}
+	if base.UID == "" {
+		// UID 是必需的，缺少时用标题和开始时间代替，便于去重
+		base.UID = base.Summary + "@" + start.UTC().Format("20060102T150405Z")
+	}
+
+	emit := func(occurrence time.Time, recurrenceID string) []ICalEvent {
+		if occurrence.Before(from) || !occurrence.Before(to) {
+			return nil
+		}
+		e := base
+		e.Start, e.End, e.RecurrenceID = occurrence, occurrence.Add(length), recurrenceID
+		return []ICalEvent{e}
+	}
+
+	// 重复事件中单独修改过的一次
+	if p, ok := raw.get("RECURRENCE-ID"); ok {
+		t, _, err := parseICalTime(p, loc)
+		if err != nil {
+			return nil, err
+		}
+		return emit(start, t.UTC().Format("20060102T150405Z")), nil
+	}
+
+	rrule, ok := raw.get("RRULE")
+	if !ok {
+		return emit(start, ""), nil
+	}
+
+	excluded := make(map[int64]bool)
+	for _, p := range raw["EXDATE"] {
+		for _, v := range strings.Split(p.value, ",") {
+			if t, _, err := parseICalTime(icalProperty{params: p.params, value: v}, loc); err == nil {
+				excluded[t.Unix()] = true
+			}
+		}
+	}
+
+	var events []ICalEvent
+	err = expandRRule(rrule.value, start, from, to, loc, budget, func(occurrence time.Time) {
+		id := occurrence.UTC().Format("20060102T150405Z")
+		if excluded[occurrence.Unix()] || overridden[base.UID+"/"+id] {
+			return
+		}
+		events = append(events, emit(occurrence, id)...)
+	})
+	return events, err
+}
+
+// expandRRule 按重复规则依次生成开始时间（含 DTSTART 本身），直到 until 或次数用完
+// 没有 COUNT 时从 from 之前的最近一个周期开始，每展开一个周期 budget 减一
+func expandRRule(rule string, start, from, until time.Time, loc *time.Location, budget *int, fn func(time.Time)) error {
+	parts := make(map[string]string)
+	for _, part := range strings.Split(rule, ";") {
+		if k, v, ok := strings.Cut(part, "="); ok {
+			parts[strings.ToUpper(k)] = strings.ToUpper(v)
+		}
+	}
+
+	interval := 1
+	if v, err := strconv.Atoi(parts["INTERVAL"]); err == nil && v > 0 {
+		interval = v
+	}
+
+	// 不支持的规则部分会改变展开结果，整条规则视为不支持，避免导入到错误的日期
+	for k, v := range parts {
+		switch {
+		case k == "FREQ" || k == "INTERVAL" || k == "COUNT" || k == "UNTIL":
+		case k == "BYDAY" && parts["FREQ"] == "WEEKLY":
+		case k == "WKST" && (v == "MO" || interval == 1):
+			// 按周一为一周的开始展开，间隔为一周时 WKST 不影响结果
+		default:
+			return fmt.Errorf("不支持的重复规则: %s", rule)
+		}
+	}
+	count := -1 // 不限次数
+	if v, err := strconv.Atoi(parts["COUNT"]); err == nil && v > 0 {
+		count = v
+	}
+	if v := parts["UNTIL"]; v != "" {
+		if t, _, err := parseICalTime(icalProperty{params: map[string]string{}, value: v}, loc); err == nil {
+			// UNTIL 包含当天
+			if len(v) == 8 {
+				t = t.AddDate(0, 0, 1)
+			} else {
+				t = t.Add(time.Second)
+			}
+			if t.Before(until) {
+				until = t
+			}
+		}
+	}
+
+	// 依次返回第 n 个周期内的开始时间
+	var period func(n int) []time.Time
+	switch parts["FREQ"] {
+	case "DAILY":
+		period = func(n int) []time.Time { return []time.Time{start.AddDate(0, 0, n*interval)} }
+	case "WEEKLY":
+		var days []time.Weekday
+		if parts["BYDAY"] != "" {
+			for _, d := range strings.Split(parts["BYDAY"], ",") {
+				wd, ok := icalWeekdays[d]
+				if !ok {
+					return fmt.Errorf("不支持的重复规则: %s", rule)
+				}
+				days = append(days, wd)
+			}
+		} else {
+			days = []time.Weekday{start.Weekday()}
+		}
+		// 以周一为一周的开始
+		offset := (int(start.Weekday()) + 6) % 7
+		weekStart := start.AddDate(0, 0, -offset)
+		sort.Slice(days, func(i, j int) bool { return (days[i]+6)%7 < (days[j]+6)%7 })
+		period = func(n int) []time.Time {
+			week := weekStart.AddDate(0, 0, 7*n*interval)
+			times := make([]time.Time, 0, len(days))
+			for _, d := range days {
+				times = append(times, week.AddDate(0, 0, (int(d)+6)%7))
+			}
+			return times
+		}
+	case "MONTHLY", "YEARLY":
+		months := interval
+		if parts["FREQ"] == "YEARLY" {
+			months *= 12
+		}
+		period = func(n int) []time.Time {
+			t := start.AddDate(0, n*months, 0)
+			// 没有这一天的月份（如 2 月 30 日）跳过，已超出范围时仍返回以便结束展开
+			if t.Day() != start.Day() && t.Before(until) {
+				return nil
+			}
+			return []time.Time{t}
+		}
+	default:
+		return fmt.Errorf("不支持的重复规则: %s", parts["FREQ"])
+	}
+
+	// 没有 COUNT 时跳过 from 之前的周期，提前一个周期开始以免夏令时等误差漏掉
+	first := 0
+	if count < 0 && from.After(start) {
+		days := int(from.Sub(start).Hours() / 24)
+		months := (from.Year()-start.Year())*12 + int(from.Month()-start.Month())
+		switch parts["FREQ"] {
+		case "DAILY":
+			first = days/interval - 1
+		case "WEEKLY":
+			first = days/(7*interval) - 1
+		case "MONTHLY":
+			first = months/interval - 1
+		case "YEARLY":
+			first = months/(12*interval) - 1
+		}
+		first = max(first, 0)
+	}
+
+	emitted := 0
+	for n := first; ; n++ {
+		if *budget <= 0 {
+			return errICalTooManyPeriods
+		}
+		*budget--
+		for _, t := range period(n) {
+			if t.Before(start) {
+				continue
+			}
+			if !t.Before(until) || emitted == count {
+				return nil
+			}
+			emitted++
+			fn(t)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// icalFile 把事件行包装成日历文件
+func icalFile(lines ...string) []byte {
+	return []byte("BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" + strings.Join(lines, "\r\n") + "\r\nEND:VCALENDAR\r\n")
+}
+
+// eventStarts 返回事件开始时间（UTC），便于比较
+func eventStarts(events []ICalEvent) []string {
+	starts := make([]string, 0, len(events))
+	for _, e := range events {
+		starts = append(starts, e.Start.UTC().Format("2006-01-02 15:04"))
+	}
+	return starts
+}
+
+func TestParseICalEventsFoldingAndEscapes(t *testing.T) {
+	data := icalFile(
+		"BEGIN:VEVENT",
+		"UID:fold@test",
+		"DTSTART:20260310T010000Z",
+		"DTEND:20260310T020000Z",
+		"SUMMARY:线性代数",
+		" 复习\\, 第三章",
+		"DESCRIPTION:第一行\\n第二行",
+		"CATEGORIES:学习,数学",
+		"BEGIN:VALARM",
+		"TRIGGER:-PT10M",
+		"DESCRIPTION:提醒",
+		"END:VALARM",
+		"END:VEVENT",
+	)
+	events, err := ParseICalEvents(data, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("解析出 %d 个事件, want 1", len(events))
+	}
+	e := events[0]
+	if e.Summary != "线性代数复习, 第三章" {
+		t.Errorf("Summary = %q", e.Summary)
+	}
+	if e.Description != "第一行\n第二行" {
+		t.Errorf("Description = %q，VALARM 中的属性不应覆盖事件属性", e.Description)
+	}
+	if len(e.Categories) != 2 || e.Categories[1] != "数学" {
+		t.Errorf("Categories = %v", e.Categories)
+	}
+	if e.End.Sub(e.Start) != time.Hour {
+		t.Errorf("时长 = %v, want 1h", e.End.Sub(e.Start))
+	}
+
+	if _, err := ParseICalEvents([]byte("not a calendar"), time.Time{}, time.Now(), time.UTC); err == nil {
+		t.Error("非日历文件应返回错误")
+	}
+}
+
+func TestParseICalEventsTimeZones(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip("缺少时区数据")
+	}
+	data := icalFile(
+		"X-WR-TIMEZONE:Asia/Shanghai",
+		"BEGIN:VEVENT", "UID:tzid@test", "DTSTART;TZID=America/New_York:20260310T090000", "DURATION:PT30M", "END:VEVENT",
+		"BEGIN:VEVENT", "UID:floating@test", "DTSTART:20260310T090000", "DTEND:20260310T100000", "END:VEVENT",
+		"BEGIN:VEVENT", "UID:unknown-tz@test", "DTSTART;TZID=Custom/Zone:20260310T120000", "DTEND;TZID=Custom/Zone:20260310T130000", "END:VEVENT",
+		"BEGIN:VEVENT", "UID:allday@test", "DTSTART;VALUE=DATE:20260311", "END:VEVENT",
+	)
+	events, err := ParseICalEvents(data, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byUID := map[string]ICalEvent{}
+	for _, e := range events {
+		byUID[e.UID] = e
+	}
+
+	tests := []struct {
+		uid  string
+		want time.Time
+	}{
+		// 纽约 3 月 8 日已进入夏令时（UTC-4）
+		{"tzid@test", time.Date(2026, 3, 10, 13, 0, 0, 0, time.UTC)},
+		// 浮动时间和无法识别的 TZID 按 X-WR-TIMEZONE 处理
+		{"floating@test", time.Date(2026, 3, 10, 9, 0, 0, 0, shanghai)},
+		{"unknown-tz@test", time.Date(2026, 3, 10, 12, 0, 0, 0, shanghai)},
+		{"allday@test", time.Date(2026, 3, 11, 0, 0, 0, 0, shanghai)},
+	}
+	for _, tt := range tests {
+		e, ok := byUID[tt.uid]
+		if !ok {
+			t.Errorf("缺少事件 %s", tt.uid)
+			continue
+		}
+		if !e.Start.Equal(tt.want) {
+			t.Errorf("%s 开始时间 = %v, want %v", tt.uid, e.Start, tt.want)
+		}
+	}
+	if e := byUID["tzid@test"]; e.End.Sub(e.Start) != 30*time.Minute {
+		t.Errorf("DURATION 时长 = %v, want 30m", e.End.Sub(e.Start))
+	}
+	if e := byUID["allday@test"]; !e.AllDay || e.End.Sub(e.Start) != 24*time.Hour {
+		t.Errorf("全天事件 AllDay=%v 时长=%v", e.AllDay, e.End.Sub(e.Start))
+	}
+}
+
+func TestParseICalEventsRecurrence(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		lines []string
+		want  []string
+	}{
+		{
+			name:  "COUNT",
+			lines: []string{"DTSTART:20260302T080000Z", "RRULE:FREQ=DAILY;COUNT=3"},
+			want:  []string{"2026-03-02 08:00", "2026-03-03 08:00", "2026-03-04 08:00"},
+		},
+		{
+			name:  "COUNT 从范围之前开始计数",
+			lines: []string{"DTSTART:20260225T080000Z", "RRULE:FREQ=DAILY;INTERVAL=2;COUNT=4"},
+			want:  []string{"2026-03-01 08:00", "2026-03-03 08:00"},
+		},
+		{
+			name:  "UNTIL 包含当天",
+			lines: []string{"DTSTART:20260302T080000Z", "RRULE:FREQ=WEEKLY;UNTIL=20260316"},
+			want:  []string{"2026-03-02 08:00", "2026-03-09 08:00", "2026-03-16 08:00"},
+		},
+		{
+			name:  "WEEKLY BYDAY",
+			lines: []string{"DTSTART:20260304T080000Z", "RRULE:FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4"},
+			want:  []string{"2026-03-04 08:00", "2026-03-09 08:00", "2026-03-11 08:00", "2026-03-16 08:00"},
+		},
+		{
+			name:  "EXDATE",
+			lines: []string{"DTSTART:20260302T080000Z", "RRULE:FREQ=DAILY;COUNT=4", "EXDATE:20260303T080000Z,20260305T080000Z"},
+			want:  []string{"2026-03-02 08:00", "2026-03-04 08:00"},
+		},
+		{
+			name:  "MONTHLY 跳过没有这一天的月份",
+			lines: []string{"DTSTART:20260131T080000Z", "RRULE:FREQ=MONTHLY;COUNT=3"},
+			want:  []string{"2026-03-31 08:00"},
+		},
+		{
+			name:  "很早开始的重复事件",
+			lines: []string{"DTSTART:19700101T080000Z", "RRULE:FREQ=DAILY;INTERVAL=7"},
+			want:  []string{"2026-03-05 08:00", "2026-03-12 08:00", "2026-03-19 08:00", "2026-03-26 08:00"},
+		},
+		{
+			name:  "WKST 不影响每周重复",
+			lines: []string{"DTSTART:20260302T080000Z", "RRULE:FREQ=WEEKLY;WKST=SU;COUNT=2"},
+			want:  []string{"2026-03-02 08:00", "2026-03-09 08:00"},
+		},
+		// 无法正确展开的规则整条跳过，而不是导入到错误的日期
+		{name: "MONTHLY BYDAY", lines: []string{"DTSTART:20260310T080000Z", "RRULE:FREQ=MONTHLY;BYDAY=2TU"}},
+		{name: "BYMONTHDAY", lines: []string{"DTSTART:20260310T080000Z", "RRULE:FREQ=MONTHLY;BYMONTHDAY=15"}},
+		{name: "BYSETPOS", lines: []string{"DTSTART:20260310T080000Z", "RRULE:FREQ=MONTHLY;BYDAY=MO,TU;BYSETPOS=-1"}},
+		{name: "BYMONTH", lines: []string{"DTSTART:20260310T080000Z", "RRULE:FREQ=YEARLY;BYMONTH=3,4"}},
+		{name: "WEEKLY 带序号的 BYDAY", lines: []string{"DTSTART:20260310T080000Z", "RRULE:FREQ=WEEKLY;BYDAY=1TU"}},
+		{name: "隔周 WKST=SU", lines: []string{"DTSTART:20260310T080000Z", "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,SU;WKST=SU"}},
+		{name: "HOURLY", lines: []string{"DTSTART:20260310T080000Z", "RRULE:FREQ=HOURLY"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := append([]string{"BEGIN:VEVENT", "UID:rule@test", "DURATION:PT25M"}, tt.lines...)
+			events, err := ParseICalEvents(icalFile(append(lines, "END:VEVENT")...), from, to, time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := eventStarts(events)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("展开结果 = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseICalEventsRecurrenceOverride(t *testing.T) {
+	data := icalFile(
+		"BEGIN:VEVENT", "UID:standup@test", "SUMMARY:站会", "DTSTART:20260302T010000Z", "DURATION:PT15M",
+		"RRULE:FREQ=DAILY;COUNT=3", "END:VEVENT",
+		// 第二次改到下午
+		"BEGIN:VEVENT", "UID:standup@test", "SUMMARY:站会（改期）", "RECURRENCE-ID:20260303T010000Z",
+		"DTSTART:20260303T070000Z", "DURATION:PT15M", "END:VEVENT",
+	)
+	events, err := ParseICalEvents(data, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := eventStarts(events)
+	want := []string{"2026-03-02 01:00", "2026-03-03 07:00", "2026-03-04 01:00"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("展开结果 = %v, want %v", got, want)
+	}
+	moved := events[1]
+	if moved.Summary != "站会（改期）" || moved.Key() != "standup@test/20260303T010000Z" {
+		t.Errorf("改期的事件 Summary=%q Key=%q", moved.Summary, moved.Key())
+	}
+	if events[0].Key() == events[2].Key() {
+		t.Error("重复事件的每一次应有不同的 Key")
+	}
+}
+
+func TestParseICalEventsLimitsExpansion(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	// 大量从 1970 年开始、没有 COUNT 的事件只展开导入范围附近的周期
+	var lines []string
+	for i := 0; i < 2000; i++ {
+		lines = append(lines, "BEGIN:VEVENT", fmt.Sprintf("UID:daily-%d@test", i), "DTSTART:19700101T080000Z",
+			"DURATION:PT25M", "RRULE:FREQ=DAILY", "END:VEVENT")
+	}
+	started := time.Now()
+	events, err := ParseICalEvents(icalFile(lines...), from, to, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2000*31 {
+		t.Errorf("展开 %d 个事件, want %d", len(events), 2000*31)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("展开耗时 %v", elapsed)
+	}
+
+	// 带 COUNT 的事件必须从头计数，超过整个文件的展开上限时返回错误
+	lines = nil
+	for i := 0; i < 10; i++ {
+		lines = append(lines, "BEGIN:VEVENT", fmt.Sprintf("UID:count-%d@test", i), "DTSTART:19700101T080000Z",
+			"DURATION:PT25M", "RRULE:FREQ=DAILY;COUNT=1000000", "END:VEVENT")
+	}
+	if _, err := ParseICalEvents(icalFile(lines...), from, to, time.UTC); err == nil {
+		t.Error("超过展开上限时应返回错误")
+	}
+}
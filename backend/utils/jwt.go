@@ -1,30 +1,214 @@
 package utils
 
 import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// 从环境变量获取JWT密钥
-var jwtSecret = []byte(getJWTSecret())
+// jwtKey 一把签名/校验密钥
+type jwtKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	signKey   interface{} // 私钥或 HMAC 密钥，仅当前签名密钥需要
+	verifyKey interface{} // 公钥或 HMAC 密钥
+}
+
+// jwtKeySet 当前签名密钥 + 所有可用于校验的密钥
+type jwtKeySet struct {
+	active   *jwtKey
+	keys     map[string]*jwtKey // 按 kid 索引
+	legacy   *jwtKey            // 升级前签发的 HS256 令牌（无 kid、无 iss/aud）
+	cutoff   time.Time          // 升级时间，只接受此前签发的旧令牌
+	issuer   string
+	audience string
+}
+
+var (
+	jwtKeys     *jwtKeySet
+	jwtKeysErr  error
+	jwtKeysOnce sync.Once
+)
 
-// getJWTSecret 获取JWT密钥，优先使用环境变量
-func getJWTSecret() string {
+// LoadJWTKeys 加载 JWT 密钥配置，启动时调用以便配置错误尽早暴露
+//
+// 环境变量：
+//
+//	JWT_ALG              签名算法 HS256（默认）、RS256 或 EdDSA
+//	JWT_SECRET           HS256 密钥；使用非对称算法时用于校验升级前的旧令牌
+//	JWT_PRIVATE_KEY_FILE RS256/EdDSA 私钥（PEM）
+//	JWT_KEY_ID           当前密钥的 kid，默认取公钥指纹（RFC 7638）
+//	JWT_VERIFY_KEY_FILES 轮换前的公钥（PEM），逗号分隔，可写成 kid=路径
+//	JWT_PREVIOUS_SECRETS 轮换前的 HS256 密钥，逗号分隔
+//	JWT_ISSUER / JWT_AUDIENCE 签发方和受众，默认均为 pomodoro-api
+//	JWT_LEGACY_CUTOFF    升级时间（RFC 3339），设置后接受此前签发的无 kid 旧令牌
+func LoadJWTKeys() error {
+	jwtKeysOnce.Do(func() {
+		jwtKeys, jwtKeysErr = loadJWTKeySet()
+	})
+	return jwtKeysErr
+}
+
+func getJWTKeys() (*jwtKeySet, error) {
+	if err := LoadJWTKeys(); err != nil {
+		return nil, err
+	}
+	return jwtKeys, nil
+}
+
+func loadJWTKeySet() (*jwtKeySet, error) {
+	set := &jwtKeySet{
+		keys:     make(map[string]*jwtKey),
+		issuer:   GetEnv("JWT_ISSUER", "pomodoro-api"),
+		audience: GetEnv("JWT_AUDIENCE", "pomodoro-api"),
+	}
+
+	alg := strings.ToUpper(GetEnv("JWT_ALG", "HS256"))
 	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		// 开发环境使用默认密钥（生产环境必须设置环境变量）
-		defaultSecret := "pomodoro-dev-secret-key-please-change-in-production-2024"
-		log.Println("警告: 未设置JWT_SECRET环境变量，使用默认密钥（仅用于开发）")
-		return defaultSecret
+
+	switch alg {
+	case "HS256":
+		if secret == "" {
+			// 开发环境使用默认密钥（生产环境必须设置环境变量）
+			secret = "pomodoro-dev-secret-key-please-change-in-production-2024"
+			log.Println("警告: 未设置JWT_SECRET环境变量，使用默认密钥（仅用于开发）")
+		} else {
+			log.Println("已加载JWT_SECRET环境变量")
+		}
+		set.active = newHMACKey(secret)
+	case "RS256", "EDDSA":
+		path := GetEnv("JWT_PRIVATE_KEY_FILE", "")
+		if path == "" {
+			return nil, fmt.Errorf("JWT_ALG=%s 需要设置 JWT_PRIVATE_KEY_FILE", alg)
+		}
+		key, err := loadPrivateKey(path, alg)
+		if err != nil {
+			return nil, err
+		}
+		if kid := GetEnv("JWT_KEY_ID", ""); kid != "" {
+			key.kid = kid
+		}
+		set.active = key
+		log.Printf("已加载JWT私钥: alg=%s kid=%s", key.method.Alg(), key.kid)
+	default:
+		return nil, fmt.Errorf("不支持的 JWT_ALG: %s", alg)
+	}
+	set.keys[set.active.kid] = set.active
+
+	// 轮换前的公钥，继续用于校验尚未过期的令牌
+	for _, entry := range GetEnvList("JWT_VERIFY_KEY_FILES") {
+		kid, path := "", entry
+		if i := strings.Index(entry, "="); i > 0 {
+			kid, path = entry[:i], entry[i+1:]
+		}
+		key, err := loadPublicKey(path)
+		if err != nil {
+			return nil, err
+		}
+		if kid != "" {
+			key.kid = kid
+		}
+		set.keys[key.kid] = key
+	}
+
+	for _, previous := range GetEnvList("JWT_PREVIOUS_SECRETS") {
+		key := newHMACKey(previous)
+		set.keys[key.kid] = key
+	}
+
+	// 兼容升级前签发的令牌：只接受升级前签发的，旧令牌有效期 7 天，过后自然全部失效
+	if raw := GetEnv("JWT_LEGACY_CUTOFF", ""); raw != "" && secret != "" {
+		cutoff, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_LEGACY_CUTOFF 格式错误: %w", err)
+		}
+		set.legacy = newHMACKey(secret)
+		set.cutoff = cutoff
+	}
+
+	return set, nil
+}
+
+// newHMACKey HS256 密钥的 kid 取密钥摘要的前几位，不泄露密钥本身
+func newHMACKey(secret string) *jwtKey {
+	sum := sha256.Sum256([]byte("kid:" + secret))
+	return &jwtKey{
+		kid:       "hs-" + base64.RawURLEncoding.EncodeToString(sum[:6]),
+		method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
 	}
-	log.Println("已加载JWT_SECRET环境变量")
-	return secret
 }
 
+func loadPrivateKey(path, alg string) (*jwtKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取JWT私钥失败: %w", err)
+	}
+
+	switch alg {
+	case "RS256":
+		priv, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("解析RSA私钥失败: %w", err)
+		}
+		return newAsymmetricKey(priv, &priv.PublicKey)
+	default:
+		priv, err := jwt.ParseEdPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("解析Ed25519私钥失败: %w", err)
+		}
+		return newAsymmetricKey(priv, priv.(ed25519.PrivateKey).Public())
+	}
+}
+
+func loadPublicKey(path string) (*jwtKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取JWT公钥失败: %w", err)
+	}
+	if pub, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return newAsymmetricKey(nil, pub)
+	}
+	if pub, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		return newAsymmetricKey(nil, pub)
+	}
+	return nil, fmt.Errorf("无法解析JWT公钥: %s", path)
+}
+
+func newAsymmetricKey(priv crypto.PrivateKey, pub crypto.PublicKey) (*jwtKey, error) {
+	key := &jwtKey{signKey: priv, verifyKey: pub}
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		key.method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, errors.New("不支持的公钥类型")
+	}
+	jwk, err := NewJWK(pub, "", key.method.Alg())
+	if err != nil {
+		return nil, err
+	}
+	key.kid = jwk.Thumbprint()
+	return key, nil
+}
+
+// TokenLifetime 登录令牌有效期
+const TokenLifetime = 24 * time.Hour * 7
+
 type Claims struct {
 	UserID uint `json:"user_id"`
 	jwt.RegisteredClaims
@@ -32,31 +216,111 @@ type Claims struct {
 
 // GenerateToken 生成 JWT Token
 func GenerateToken(userID uint) (string, error) {
+	set, err := getJWTKeys()
+	if err != nil {
+		return "", err
+	}
+
 	claims := Claims{
 		UserID: userID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour * 7)), // 7天过期
+			Issuer:    set.issuer,
+			Audience:  jwt.ClaimStrings{set.audience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenLifetime)), // 7天过期
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	token := jwt.NewWithClaims(set.active.method, claims)
+	token.Header["kid"] = set.active.kid
+	return token.SignedString(set.active.signKey)
 }
 
-// ParseToken 解析 JWT Token
+// ParseToken 解析 JWT Token，校验 kid 对应密钥的算法、签发方和受众
 func ParseToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
-
+	set, err := getJWTKeys()
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+	claims := &Claims{}
+	_, err = jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := set.keys[kid]
+		if !ok {
+			return nil, errors.New("unknown key id")
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		return key.verifyKey, nil
+	},
+		jwt.WithValidMethods([]string{"HS256", "RS256", "EdDSA"}),
+		jwt.WithIssuer(set.issuer),
+		jwt.WithAudience(set.audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err == nil {
 		return claims, nil
 	}
 
-	return nil, errors.New("invalid token")
+	// 升级前签发的旧令牌：没有 kid，只能用原 HS256 密钥校验
+	if set.legacy != nil && errors.Is(err, jwt.ErrTokenUnverifiable) {
+		return parseLegacyToken(set.legacy, set.cutoff, tokenString)
+	}
+	return nil, err
+}
+
+func parseLegacyToken(key *jwtKey, cutoff time.Time, tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, hasKid := token.Header["kid"]; hasKid {
+			return nil, errors.New("unknown key id")
+		}
+		return key.verifyKey, nil
+	},
+		jwt.WithValidMethods([]string{"HS256"}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || claims.Issuer != "" {
+		return nil, errors.New("invalid token")
+	}
+	// 升级后不再签发旧格式令牌，升级后的签发时间或超过 7 天的有效期说明令牌是伪造的
+	iat := claims.IssuedAt
+	if iat == nil || !iat.Before(cutoff) || claims.ExpiresAt.After(iat.Add(TokenLifetime)) {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// PublicJWKS 返回可供其他服务校验令牌的公钥集合（HS256 密钥不公开）
+func PublicJWKS() (JWKSet, error) {
+	set, err := getJWTKeys()
+	if err != nil {
+		return JWKSet{}, err
+	}
+
+	jwks := JWKSet{Keys: []JWK{}}
+	for kid, key := range set.keys {
+		if key.method == jwt.SigningMethodHS256 {
+			continue
+		}
+		jwk, err := NewJWK(key.verifyKey, kid, key.method.Alg())
+		if err != nil {
+			return JWKSet{}, err
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	// 当前签名密钥排在最前，其余按 kid 排序
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		if (jwks.Keys[i].Kid == set.active.kid) != (jwks.Keys[j].Kid == set.active.kid) {
+			return jwks.Keys[i].Kid == set.active.kid
+		}
+		return jwks.Keys[i].Kid < jwks.Keys[j].Kid
+	})
+	return jwks, nil
 }
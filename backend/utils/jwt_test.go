@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// useJWTKeys 按给定环境变量重新加载密钥，测试结束后恢复
+func useJWTKeys(t *testing.T, env map[string]string) *jwtKeySet {
+	t.Helper()
+	for _, key := range []string{"JWT_ALG", "JWT_SECRET", "JWT_PRIVATE_KEY_FILE", "JWT_KEY_ID",
+		"JWT_VERIFY_KEY_FILES", "JWT_PREVIOUS_SECRETS", "JWT_ISSUER", "JWT_AUDIENCE", "JWT_LEGACY_CUTOFF"} {
+		t.Setenv(key, env[key])
+	}
+	set, err := loadJWTKeySet()
+	if err != nil {
+		t.Fatalf("加载密钥失败: %v", err)
+	}
+
+	jwtKeysOnce.Do(func() {})
+	previous := jwtKeys
+	jwtKeys = set
+	t.Cleanup(func() { jwtKeys = previous })
+	return set
+}
+
+// writePEM 把密钥写入临时 PEM 文件
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newRSAKeyFiles 生成 RSA 密钥，返回私钥和公钥文件路径
+func newRSAKeyFiles(t *testing.T) (privPath, pubPath string) {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	return writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(priv)), writePEM(t, "PUBLIC KEY", pub)
+}
+
+// newEd25519KeyFiles 生成 Ed25519 密钥，返回私钥和公钥文件路径
+func newEd25519KeyFiles(t *testing.T) (privPath, pubPath string) {
+	t.Helper()
+	pubKey, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(priv)
+	pub, _ := x509.MarshalPKIXPublicKey(pubKey)
+	return writePEM(t, "PRIVATE KEY", der), writePEM(t, "PUBLIC KEY", pub)
+}
+
+// signClaims 用指定密钥签名，kid 为空时不写入 kid 头
+func signClaims(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// validClaims 与 GenerateToken 相同的声明
+func validClaims(userID uint) Claims {
+	now := time.Now()
+	return Claims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "pomodoro-api",
+			Audience:  jwt.ClaimStrings{"pomodoro-api"},
+			ExpiresAt: jwt.NewNumericDate(now.Add(TokenLifetime)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+}
+
+func TestGenerateAndParseToken(t *testing.T) {
+	rsaPriv, _ := newRSAKeyFiles(t)
+	edPriv, _ := newEd25519KeyFiles(t)
+
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"HS256", map[string]string{"JWT_SECRET": "test-secret"}},
+		{"RS256", map[string]string{"JWT_ALG": "RS256", "JWT_PRIVATE_KEY_FILE": rsaPriv}},
+		{"EdDSA", map[string]string{"JWT_ALG": "EdDSA", "JWT_PRIVATE_KEY_FILE": edPriv}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := useJWTKeys(t, tt.env)
+			token, err := GenerateToken(42)
+			if err != nil {
+				t.Fatalf("GenerateToken: %v", err)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if alg := parsed.Method.Alg(); alg != tt.name {
+				t.Errorf("alg = %s, want %s", alg, tt.name)
+			}
+			if kid := parsed.Header["kid"]; kid != set.active.kid {
+				t.Errorf("kid = %v, want %s", kid, set.active.kid)
+			}
+
+			claims, err := ParseToken(token)
+			if err != nil {
+				t.Fatalf("ParseToken: %v", err)
+			}
+			if claims.UserID != 42 {
+				t.Errorf("UserID = %d, want 42", claims.UserID)
+			}
+		})
+	}
+}
+
+func TestParseTokenRejectsForgedTokens(t *testing.T) {
+	rsaPriv, rsaPub := newRSAKeyFiles(t)
+	set := useJWTKeys(t, map[string]string{"JWT_ALG": "RS256", "JWT_PRIVATE_KEY_FILE": rsaPriv})
+
+	pubPEM, err := os.ReadFile(rsaPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPriv, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	expired := validClaims(1)
+	expired.IssuedAt = jwt.NewNumericDate(time.Now().Add(-2 * TokenLifetime))
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	noExpiry := validClaims(1)
+	noExpiry.ExpiresAt = nil
+	wrongIssuer := validClaims(1)
+	wrongIssuer.Issuer = "other-service"
+	wrongAudience := validClaims(1)
+	wrongAudience.Audience = jwt.ClaimStrings{"other-service"}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		// 用公钥作为 HMAC 密钥签名，冒充当前 RS256 密钥
+		{"算法混淆", signClaims(t, jwt.SigningMethodHS256, pubPEM, set.active.kid, validClaims(1))},
+		{"未知 kid", signClaims(t, jwt.SigningMethodRS256, otherPriv, "unknown", validClaims(1))},
+		{"kid 正确但密钥不同", signClaims(t, jwt.SigningMethodRS256, otherPriv, set.active.kid, validClaims(1))},
+		{"没有 kid", signClaims(t, jwt.SigningMethodRS256, otherPriv, "", validClaims(1))},
+		{"已过期", signClaims(t, set.active.method, set.active.signKey, set.active.kid, expired)},
+		{"没有过期时间", signClaims(t, set.active.method, set.active.signKey, set.active.kid, noExpiry)},
+		{"签发方错误", signClaims(t, set.active.method, set.active.signKey, set.active.kid, wrongIssuer)},
+		{"受众错误", signClaims(t, set.active.method, set.active.signKey, set.active.kid, wrongAudience)},
+		{"alg none", signClaims(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, set.active.kid, validClaims(1))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseToken(tt.token); err == nil {
+				t.Error("ParseToken 应当拒绝该令牌")
+			}
+		})
+	}
+}
+
+func TestParseTokenAfterKeyRotation(t *testing.T) {
+	oldPriv, oldPub := newRSAKeyFiles(t)
+	newPriv, _ := newEd25519KeyFiles(t)
+
+	useJWTKeys(t, map[string]string{"JWT_ALG": "RS256", "JWT_PRIVATE_KEY_FILE": oldPriv})
+	oldToken, err := GenerateToken(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 轮换后旧公钥仍在校验列表中
+	useJWTKeys(t, map[string]string{"JWT_ALG": "EdDSA", "JWT_PRIVATE_KEY_FILE": newPriv, "JWT_VERIFY_KEY_FILES": oldPub})
+	if claims, err := ParseToken(oldToken); err != nil || claims.UserID != 7 {
+		t.Errorf("轮换后旧令牌应继续有效: %v", err)
+	}
+	newToken, err := GenerateToken(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, err := ParseToken(newToken); err != nil || claims.UserID != 8 {
+		t.Errorf("新令牌应有效: %v", err)
+	}
+	jwks, err := PublicJWKS()
+	if err != nil || len(jwks.Keys) != 2 {
+		t.Errorf("JWKS 应包含新旧两把公钥: %+v, %v", jwks, err)
+	}
+
+	// 移除旧公钥后旧令牌失效
+	useJWTKeys(t, map[string]string{"JWT_ALG": "EdDSA", "JWT_PRIVATE_KEY_FILE": newPriv})
+	if _, err := ParseToken(oldToken); err == nil {
+		t.Error("移除旧公钥后旧令牌应失效")
+	}
+
+	// HS256 密钥轮换
+	useJWTKeys(t, map[string]string{"JWT_SECRET": "old-secret"})
+	oldHMAC, _ := GenerateToken(9)
+	useJWTKeys(t, map[string]string{"JWT_SECRET": "new-secret", "JWT_PREVIOUS_SECRETS": "old-secret"})
+	if claims, err := ParseToken(oldHMAC); err != nil || claims.UserID != 9 {
+		t.Errorf("JWT_PREVIOUS_SECRETS 中的密钥签发的令牌应有效: %v", err)
+	}
+	useJWTKeys(t, map[string]string{"JWT_SECRET": "new-secret"})
+	if _, err := ParseToken(oldHMAC); err == nil {
+		t.Error("旧密钥移除后令牌应失效")
+	}
+}
+
+func TestParseLegacyToken(t *testing.T) {
+	const secret = "legacy-secret"
+	cutoff := time.Now().Add(-time.Hour)
+
+	// 升级前的令牌：没有 kid、iss 和 aud
+	legacyClaims := func(issuedAt time.Time) Claims {
+		return Claims{UserID: 3, RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(TokenLifetime)),
+		}}
+	}
+	before := signClaims(t, jwt.SigningMethodHS256, []byte(secret), "", legacyClaims(cutoff.Add(-time.Hour)))
+	after := signClaims(t, jwt.SigningMethodHS256, []byte(secret), "", legacyClaims(cutoff.Add(time.Minute)))
+	longLived := legacyClaims(cutoff.Add(-time.Hour))
+	longLived.ExpiresAt = jwt.NewNumericDate(time.Now().Add(365 * 24 * time.Hour))
+	forever := signClaims(t, jwt.SigningMethodHS256, []byte(secret), "", longLived)
+
+	useJWTKeys(t, map[string]string{"JWT_SECRET": secret})
+	if _, err := ParseToken(before); err == nil {
+		t.Error("未设置 JWT_LEGACY_CUTOFF 时不应接受旧令牌")
+	}
+
+	useJWTKeys(t, map[string]string{"JWT_SECRET": secret, "JWT_LEGACY_CUTOFF": cutoff.Format(time.RFC3339)})
+	if claims, err := ParseToken(before); err != nil || claims.UserID != 3 {
+		t.Errorf("升级前签发的旧令牌应有效: %v", err)
+	}
+	if _, err := ParseToken(after); err == nil {
+		t.Error("升级后签发的旧格式令牌应被拒绝")
+	}
+	if _, err := ParseToken(forever); err == nil {
+		t.Error("有效期超过 7 天的旧格式令牌应被拒绝")
+	}
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net/smtp"
+	"strings"
+)
+
+// SendMail 通过 SMTP 发送纯文本邮件
+// 未设置 SMTP_HOST 时只把邮件内容写入日志（仅用于开发）
+func SendMail(to, subject, body string) error {
+	host := GetEnv("SMTP_HOST", "")
+	if host == "" {
+		log.Printf("警告: 未设置SMTP_HOST环境变量，邮件未发送（仅用于开发）\n收件人: %s\n主题: %s\n%s", to, subject, body)
+		return nil
+	}
+
+	port := GetEnv("SMTP_PORT", "587")
+	username := GetEnv("SMTP_USERNAME", "")
+	password := GetEnv("SMTP_PASSWORD", "")
+	from := GetEnv("SMTP_FROM", username)
+
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	msg := strings.Join([]string{
+		"From: " + from,
+		"To: " + to,
+		// 非 ASCII 的主题需要按 RFC 2047 编码
+		"Subject: " + mime.BEncoding.Encode("UTF-8", subject),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"Content-Transfer-Encoding: 8bit",
+		"",
+		body,
+	}, "\r\n")
+
+	if err := smtp.SendMail(host+":"+port, auth, from, []string{to}, []byte(msg)); err != nil {
+		return fmt.Errorf("发送邮件失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ParseCIDRs 解析 IP 或 CIDR 列表（单个 IP 视为 /32 或 /128）
+func ParseCIDRs(items []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, item := range items {
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("无效的IP地址: %s", item)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			item = fmt.Sprintf("%s/%d", item, bits)
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("无效的CIDR: %s", item)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// ipInNets 判断 IP 是否属于任一网段
+func ipInNets(ip net.IP, nets []*net.IPNet) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProxyProtocolListener 支持 PROXY protocol（v1/v2）的监听器
+// 只有来自可信代理的连接才会解析 PROXY 头，其他连接原样处理，防止伪造来源地址
+type ProxyProtocolListener struct {
+	net.Listener
+	Trusted []*net.IPNet
+	// 读取 PROXY 头的超时时间
+	HeaderTimeout time.Duration
+}
+
+// NewProxyProtocolListener 包装监听器
+func NewProxyProtocolListener(ln net.Listener, trusted []*net.IPNet) *ProxyProtocolListener {
+	return &ProxyProtocolListener{Listener: ln, Trusted: trusted, HeaderTimeout: 5 * time.Second}
+}
+
+// Accept 接受连接，PROXY 头在第一次读取或获取地址时再解析，避免阻塞监听循环
+func (l *ProxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr); !ok || !ipInNets(tcpAddr.IP, l.Trusted) {
+		return conn, nil
+	}
+	return &proxyConn{Conn: conn, reader: bufio.NewReader(conn), timeout: l.HeaderTimeout}, nil
+}
+
+type proxyConn struct {
+	net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+
+	once       sync.Once
+	err        error
+	remoteAddr net.Addr
+}
+
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+		c.remoteAddr, c.err = readProxyHeader(c.reader)
+		c.Conn.SetReadDeadline(time.Time{})
+	})
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+var (
+	proxyV1Prefix = []byte("PROXY ")
+	proxyV2Sig    = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// readProxyHeader 读取 PROXY 头，没有头时返回 nil 地址（直接连接的可信来源）
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	peek, err := r.Peek(len(proxyV1Prefix))
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if bytes.Equal(peek, proxyV1Prefix) {
+		return readProxyV1(r)
+	}
+	if peek12, err := r.Peek(len(proxyV2Sig)); err == nil && bytes.Equal(peek12, proxyV2Sig) {
+		return readProxyV2(r)
+	}
+	return nil, nil
+}
+
+// readProxyV1 解析文本格式：PROXY TCP4 源地址 目标地址 源端口 目标端口\r\n
+func readProxyV1(r *bufio.Reader) (net.Addr, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) > 107 || !strings.HasSuffix(line, "\r\n") {
+		return nil, errors.New("无效的PROXY头")
+	}
+
+	fields := strings.Fields(strings.TrimSpace(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, errors.New("无效的PROXY头")
+	}
+
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.Atoi(fields[4])
+	if ip == nil || err != nil {
+		return nil, errors.New("无效的PROXY头地址")
+	}
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+// readProxyV2 解析二进制格式
+func readProxyV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	command := header[12] & 0x0F
+	family := header[13] >> 4
+	length := int(binary.BigEndian.Uint16(header[14:16]))
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	// LOCAL 命令（代理自身的健康检查）或非 TCP/UDP 地址：使用原始连接地址
+	if command == 0x0 {
+		return nil, nil
+	}
+
+	switch family {
+	case 0x1: // IPv4
+		if len(payload) < 12 {
+			return nil, errors.New("无效的PROXY头地址")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case 0x2: // IPv6
+		if len(payload) < 36 {
+			return nil, errors.New("无效的PROXY头地址")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// 除 net.IP 自带判断外，不允许访问的保留地址段
+var reservedNets, _ = ParseCIDRs([]string{
+	"0.0.0.0/8",       // 本网络
+	"100.64.0.0/10",   // 运营商级 NAT
+	"192.0.0.0/24",    // IETF 协议分配
+	"192.0.2.0/24",    // 文档示例
+	"198.18.0.0/15",   // 基准测试
+	"198.51.100.0/24", // 文档示例
+	"203.0.113.0/24",  // 文档示例
+	"240.0.0.0/4",     // 保留
+	"64:ff9b::/96",    // NAT64，可能映射到内网 IPv4
+	"2001:db8::/32",   // 文档示例
+})
+
+// IsPublicIP 判断是否为可从公网访问的地址
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range reservedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// allowDialIP 判断是否允许连接该地址，测试中可替换
+var allowDialIP = IsPublicIP
+
+// ErrForbiddenAddress 目标地址不允许访问
+var ErrForbiddenAddress = errors.New("不允许访问内网地址")
+
+// SafeFetcher 下载用户提供的地址，防止 SSRF
+// 在建立连接时检查实际连接的 IP（而不是只检查域名解析结果），避免 DNS 重绑定绕过
+type SafeFetcher struct {
+	AllowPrivate bool          // 允许访问内网地址（仅用于自托管环境）
+	MaxBytes     int64         // 响应体大小上限
+	Timeout      time.Duration // 整个请求的超时时间
+	MaxRedirects int
+}
+
+// NewSafeFetcher 创建下载器
+func NewSafeFetcher(maxBytes int64) *SafeFetcher {
+	return &SafeFetcher{MaxBytes: maxBytes, Timeout: 15 * time.Second, MaxRedirects: 3}
+}
+
+func (f *SafeFetcher) client() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if f.AllowPrivate {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !allowDialIP(ip) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		},
+	}
+
+	transport := &http.Transport{
+		// 不使用环境变量中的代理，否则检查的是代理的地址
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+		MaxIdleConns:          1,
+		DisableKeepAlives:     true,
+	}
+
+	return &http.Client{
+		Timeout:   f.Timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > f.MaxRedirects {
+				return errors.New("重定向次数过多")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return errors.New("不支持的地址协议")
+			}
+			return nil
+		},
+	}
+}
+
+// ParseFetchURL 校验下载地址，webcal:// 视为 https://
+func ParseFetchURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return nil, errors.New("地址格式错误")
+	}
+	if u.Scheme == "webcal" {
+		u.Scheme = "https"
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New("不支持的地址协议")
+	}
+	return u, nil
+}
+
+// Fetch 下载地址的内容
+func (f *SafeFetcher) Fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	u, err := ParseFetchURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client().Do(req)
+	if err != nil {
+		if errors.Is(err, ErrForbiddenAddress) {
+			return nil, ErrForbiddenAddress
+		}
+		return nil, fmt.Errorf("下载失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("下载失败: HTTP %d", resp.StatusCode)
+	}
+	if resp.ContentLength > f.MaxBytes {
+		return nil, errors.New("文件过大")
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, f.MaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("下载失败: %w", err)
+	}
+	if int64(len(data)) > f.MaxBytes {
+		return nil, errors.New("文件过大")
+	}
+	return data, nil
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"127.0.0.2", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"172.31.255.255", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false}, // 云服务器元数据地址
+		{"0.0.0.0", false},
+		{"100.64.0.1", false},
+		{"fc00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false}, // IPv4 映射的 IPv6 地址
+		{"::ffff:10.0.0.1", false},
+		{"::ffff:8.8.8.8", true},
+		{"64:ff9b::a00:1", false}, // NAT64 映射的 10.0.0.1
+		{"224.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := IsPublicIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("IsPublicIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestParseFetchURL(t *testing.T) {
+	u, err := ParseFetchURL(" webcal://calendar.example.com/feed.ics ")
+	if err != nil || u.String() != "https://calendar.example.com/feed.ics" {
+		t.Errorf("webcal 地址 = %v, %v", u, err)
+	}
+	for _, raw := range []string{"file:///etc/passwd", "gopher://example.com/", "ftp://example.com/a.ics", "/relative.ics", "http://"} {
+		if _, err := ParseFetchURL(raw); err == nil {
+			t.Errorf("ParseFetchURL(%q) 应返回错误", raw)
+		}
+	}
+}
+
+func TestSafeFetcherBlocksPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "BEGIN:VCALENDAR")
+	}))
+	defer server.Close()
+
+	f := NewSafeFetcher(1024)
+	if _, err := f.Fetch(context.Background(), server.URL); !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("访问回环地址的错误 = %v, want ErrForbiddenAddress", err)
+	}
+	// 域名解析到回环地址同样拒绝
+	localhost := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	if _, err := f.Fetch(context.Background(), localhost); !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("访问 localhost 的错误 = %v, want ErrForbiddenAddress", err)
+	}
+
+	f.AllowPrivate = true
+	data, err := f.Fetch(context.Background(), server.URL)
+	if err != nil || string(data) != "BEGIN:VCALENDAR" {
+		t.Errorf("AllowPrivate 时下载结果 = %q, %v", data, err)
+	}
+}
+
+func TestSafeFetcherChecksRedirectTargets(t *testing.T) {
+	var internalHits int
+	internal := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		internalHits++
+		fmt.Fprint(w, "secret")
+	}))
+	listener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("无法监听 127.0.0.2: %v", err)
+	}
+	internal.Listener.Close()
+	internal.Listener = listener
+	internal.Start()
+	defer internal.Close()
+
+	// 把 127.0.0.1 当作公网地址，重定向到 127.0.0.2 上的“内网”服务
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/internal":
+			http.Redirect(w, r, internal.URL+"/", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/file":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		case "/large":
+			fmt.Fprint(w, strings.Repeat("x", 2048))
+		default:
+			fmt.Fprint(w, "ok")
+		}
+	}))
+	defer public.Close()
+
+	previous := allowDialIP
+	allowDialIP = func(ip net.IP) bool { return ip.Equal(net.IPv4(127, 0, 0, 1)) }
+	defer func() { allowDialIP = previous }()
+
+	f := NewSafeFetcher(1024)
+	if data, err := f.Fetch(context.Background(), public.URL+"/"); err != nil || string(data) != "ok" {
+		t.Fatalf("允许的地址下载结果 = %q, %v", data, err)
+	}
+	if _, err := f.Fetch(context.Background(), public.URL+"/internal"); !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("重定向到内网地址的错误 = %v, want ErrForbiddenAddress", err)
+	}
+	if internalHits != 0 {
+		t.Errorf("内网服务被访问了 %d 次", internalHits)
+	}
+	if _, err := f.Fetch(context.Background(), public.URL+"/loop"); err == nil {
+		t.Error("重定向次数过多时应返回错误")
+	}
+	if _, err := f.Fetch(context.Background(), public.URL+"/file"); err == nil {
+		t.Error("重定向到 file:// 时应返回错误")
+	}
+	if _, err := f.Fetch(context.Background(), public.URL+"/large"); err == nil {
+		t.Error("超过 MaxBytes 时应返回错误")
+	}
+}